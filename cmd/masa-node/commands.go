@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/masa-finance/masa-oracle/pkg/auth"
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/pubsub"
+	"github.com/masa-finance/masa-oracle/pkg/snapshot"
+	"github.com/masa-finance/masa-oracle/pkg/storage"
+)
+
+// runCommand executes a one-off masa-node subcommand given as positional arguments,
+// e.g. "masa-node psk generate". Flags are parsed by the config package beforehand.
+func runCommand(args []string) error {
+	switch args[0] {
+	case "psk":
+		return handlePSKCommand(args[1:])
+	case "cache":
+		return handleCacheCommand(args[1:])
+	case "snapshot":
+		return handleSnapshotCommand(args[1:])
+	case "token":
+		return handleTokenCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s", strings.Join(args, " "))
+	}
+}
+
+func handlePSKCommand(args []string) error {
+	if len(args) == 0 || args[0] != "generate" {
+		return fmt.Errorf("usage: masa-node psk generate [--swarmKeyFile=<path>]")
+	}
+	keyFile := config.GetInstance().SwarmKeyFile
+	if err := network.GenerateSwarmKey(keyFile); err != nil {
+		return err
+	}
+	color.Green("Private network key written to %s", keyFile)
+	fmt.Println("Copy this file to every node of the private network and start them with --privateNetwork=true --tcp=true")
+	return nil
+}
+
+// handleCacheCommand copies the resolver cache between storage backends, e.g.
+// "masa-node cache migrate leveldb:CACHE badger:CACHE-badger". The node must be stopped.
+func handleCacheCommand(args []string) error {
+	usage := fmt.Errorf("usage: masa-node cache migrate <backend>:<path> <backend>:<path>, backends: %s", strings.Join(storage.Backends(), ", "))
+	if len(args) != 3 || args[0] != "migrate" {
+		return usage
+	}
+	fromBackend, fromPath, ok := strings.Cut(args[1], ":")
+	if !ok {
+		return usage
+	}
+	toBackend, toPath, ok := strings.Cut(args[2], ":")
+	if !ok {
+		return usage
+	}
+
+	from, err := storage.Open(fromBackend, fromPath)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+	to, err := storage.Open(toBackend, toPath)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	count, err := storage.Migrate(context.Background(), from, to)
+	if err != nil {
+		return fmt.Errorf("migration failed after %d entries: %w", count, err)
+	}
+	color.Green("Copied %d entries from %s to %s", count, args[1], args[2])
+	fmt.Printf("Start the node with --cacheBackend=%s --cachePath=%s to use the new cache\n", toBackend, toPath)
+	return nil
+}
+
+// handleTokenCommand prints an API token signed by the node key, e.g.
+// "masa-node token write 1h dashboard". Operators whose key is in apiOperatorKeys
+// issue tokens the same way with their own key file.
+func handleTokenCommand(args []string) error {
+	usage := fmt.Errorf("usage: masa-node token <read|write|admin> [ttl] [subject], the ttl is at most %s", auth.MaxTokenTTL)
+	if len(args) == 0 || len(args) > 3 {
+		return usage
+	}
+	scope, err := auth.ParseScope(args[0])
+	if err != nil {
+		return err
+	}
+	ttl := time.Hour
+	if len(args) > 1 {
+		if ttl, err = time.ParseDuration(args[1]); err != nil {
+			return usage
+		}
+	}
+	var subject string
+	if len(args) > 2 {
+		subject = args[2]
+	}
+	token, err := auth.NewToken(masacrypto.KeyManagerInstance().Libp2pPrivKey, scope, subject, time.Now(), ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
+
+// handleSnapshotCommand exports the resolver cache and the node registry to a snapshot
+// file or imports one, e.g. "masa-node snapshot export backup.jsonl sign" and
+// "masa-node snapshot import backup.jsonl merge". The node must be stopped.
+func handleSnapshotCommand(args []string) error {
+	usage := fmt.Errorf("usage: masa-node snapshot export <file> [sign] | import <file> [merge|replace] [signed] | verify <file>")
+	if len(args) < 2 {
+		return usage
+	}
+	file := args[1]
+	switch args[0] {
+	case "export":
+		sign := false
+		for _, arg := range args[2:] {
+			if arg != "sign" {
+				return usage
+			}
+			sign = true
+		}
+		return exportSnapshot(file, sign)
+	case "import":
+		opts := snapshot.ImportOptions{Mode: snapshot.ModeMerge}
+		for _, arg := range args[2:] {
+			switch arg {
+			case string(snapshot.ModeMerge), string(snapshot.ModeReplace):
+				opts.Mode = snapshot.Mode(arg)
+			case "signed":
+				opts.RequireSigned = true
+			default:
+				return usage
+			}
+		}
+		return importSnapshot(file, opts)
+	case "verify":
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		header, footer, err := snapshot.Verify(f)
+		if err != nil {
+			return err
+		}
+		signer, err := footer.Signer()
+		if err != nil {
+			return err
+		}
+		color.Green("Snapshot %s is valid", file)
+		fmt.Printf("Created %s by %s (%s), %d records, %d nodes\n", header.Created, header.NodeVersion, header.Environment, footer.Records, footer.Nodes)
+		if signer != "" {
+			fmt.Printf("Signed by %s\n", signer)
+		}
+		return nil
+	default:
+		return usage
+	}
+}
+
+func exportSnapshot(file string, sign bool) error {
+	cfg := config.GetInstance()
+	store, err := storage.Open(cfg.CacheBackend, cfg.CachePath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	nodes, err := snapshot.LoadRegistry(registryPath(cfg))
+	if err != nil {
+		return err
+	}
+
+	opts := snapshot.ExportOptions{NodeVersion: config.Version, Environment: cfg.Environment, Clock: clock.Wall()}
+	if sign {
+		opts.Signer = masacrypto.KeyManagerInstance().Libp2pPrivKey
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	footer, err := snapshot.Export(context.Background(), f, store, nodes, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(file)
+		return fmt.Errorf("export failed: %w", err)
+	}
+	color.Green("Exported %d records and %d nodes to %s", footer.Records, footer.Nodes, file)
+	return nil
+}
+
+func importSnapshot(file string, opts snapshot.ImportOptions) error {
+	cfg := config.GetInstance()
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cache, err := db.OpenResolverCache(cfg.CacheBackend, cfg.CachePath, clock.Wall())
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+	path := registryPath(cfg)
+	nodes, err := snapshot.LoadRegistry(path)
+	if err != nil {
+		return err
+	}
+
+	report, err := snapshot.Import(context.Background(), f, cache, nodes, opts)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+	if err := snapshot.SaveRegistry(path, nodes); err != nil {
+		return err
+	}
+
+	if report.Signer != "" {
+		fmt.Printf("Snapshot signed by %s\n", report.Signer)
+	} else {
+		color.Yellow("Snapshot is not signed")
+	}
+	color.Green("Imported %s snapshot %s", opts.Mode, file)
+	fmt.Printf("Records: %d added, %d updated, %d unchanged, %d removed\n",
+		report.Records.Added, report.Records.Updated, report.Records.Unchanged, report.Records.Removed)
+	fmt.Printf("Nodes: %d added, %d updated, %d unchanged, %d removed\n",
+		report.Nodes.Added, report.Nodes.Updated, report.Nodes.Unchanged, report.Nodes.Removed)
+	for _, c := range report.Conflicts {
+		color.Yellow("Conflict %s: %s", c.Key, c.Reason)
+	}
+	for _, c := range report.Invalid {
+		color.Red("Skipped %s: %s", c.Key, c.Reason)
+	}
+	return nil
+}
+
+func registryPath(cfg *config.AppConfig) string {
+	return filepath.Join(cfg.MasaDir, pubsub.NodeDataFileName(config.Version, cfg.Environment))
+}
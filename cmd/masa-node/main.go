@@ -2,24 +2,41 @@ package main
 
 import (
 	"context"
+	"github.com/libp2p/go-libp2p/p2p/discovery/backoff"
 	"github.com/masa-finance/masa-oracle/pkg/db"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"math/rand"
+	"net"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	masa "github.com/masa-finance/masa-oracle/pkg"
 	"github.com/masa-finance/masa-oracle/pkg/api"
+	"github.com/masa-finance/masa-oracle/pkg/clock"
 	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/health"
 	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
 	"github.com/masa-finance/masa-oracle/pkg/staking"
+	"github.com/masa-finance/masa-oracle/pkg/twitter"
 )
 
 func main() {
 	cfg := config.GetInstance()
 	cfg.LogConfig()
 	cfg.SetupLogging()
+
+	if args := pflag.Args(); len(args) > 0 {
+		// Run the subcommand and exit, do not proceed to start the node
+		if err := runCommand(args); err != nil {
+			logrus.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
 	keyManager := masacrypto.KeyManagerInstance()
 
 	// Create a cancellable context
@@ -36,9 +53,15 @@ func main() {
 
 	var isStaked bool
 	// Verify the staking event
+	stakingStatus := health.NewStatus("verifying the staking event")
 	isStaked, err := staking.VerifyStakingEvent(keyManager.EthAddress)
 	if err != nil {
 		logrus.Error(err)
+		stakingStatus.Set("", err)
+	} else if isStaked {
+		stakingStatus.Set("staked", nil)
+	} else {
+		stakingStatus.Set("not staked", nil)
 	}
 	if !isStaked {
 		logrus.Warn("No staking event found for this address")
@@ -52,6 +75,12 @@ func main() {
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	node.Health.Register("staking", stakingStatus.Check, health.Startup)
+	if cfg.TwitterUsername != "" {
+		twitterStatus := health.NewStatus("logging in to Twitter")
+		node.Health.Register("twitter", twitterStatus.Check, health.Readiness)
+		go loginToTwitter(ctx, node.Clock, node.Health, twitterStatus)
+	}
 	err = node.Start()
 	if err != nil {
 		logrus.Fatal(err)
@@ -65,7 +94,13 @@ func main() {
 		logrus.Info("This node is not set as the allowed peer")
 	}
 
-	go db.InitResolverCache(node, keyManager)
+	cache, err := db.OpenResolverCache(cfg.CacheBackend, cfg.CachePath, node.Clock)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	node.Health.Register("cache", cache.Check, health.Liveness, health.Readiness, health.Startup)
+	database := db.New(node, cache)
+	go database.Start(ctx)
 
 	// Listen for SIGINT (CTRL+C)
 	c := make(chan os.Signal, 1)
@@ -76,20 +111,46 @@ func main() {
 		<-c
 		nodeData := node.NodeTracker.GetNodeData(node.Host.ID().String())
 		if nodeData != nil {
-			nodeData.Left()
+			nodeData.Left(node.Clock.Now())
 		}
 		node.NodeTracker.DumpNodeData()
+		if err := cache.Close(); err != nil {
+			logrus.Errorf("Failed to close the resolver cache: %v", err)
+		}
+		// closing the host also flushes and closes the peerstore
+		if err := node.Host.Close(); err != nil {
+			logrus.Errorf("Failed to close the host: %v", err)
+		}
 		cancel()
 	}()
 
-	router := api.SetupRoutes(node)
+	// the REST and gRPC servers draw from the same rate limits
+	limiter := api.NewRateLimiter(cfg.RateLimits, cache, node.Clock)
+	router := api.SetupRoutes(node, database, limiter)
 	go func() {
-		err := router.Run()
+		err := router.Run(cfg.ApiListen)
 		if err != nil {
 			logrus.Fatal(err)
 		}
 	}()
 
+	if cfg.GrpcListen != "" {
+		grpcServer, err := api.NewGRPCServer(node, database, cfg, limiter)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		listener, err := net.Listen("tcp", cfg.GrpcListen)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				logrus.Fatal(err)
+			}
+		}()
+		defer grpcServer.GracefulStop()
+	}
+
 	// Get the multiaddress and IP address of the node
 	multiAddr := node.GetMultiAddrs().String() // Get the multiaddress
 	ipAddr := node.Host.Addrs()[0].String()    // Get the IP address
@@ -98,3 +159,26 @@ func main() {
 
 	<-ctx.Done()
 }
+
+// loginToTwitter logs the scraper in, retrying with exponential backoff, and replaces
+// the pending status with the health check of the scraper. The node is not ready until
+// the login succeeds, it is started regardless so a failing login does not restart it.
+func loginToTwitter(ctx context.Context, clk clock.Clock, registry *health.Registry, status *health.Status) {
+	retry := backoff.NewExponentialBackoff(twitter.LoginMinBackoff, twitter.LoginMaxBackoff, backoff.FullJitter,
+		twitter.LoginMinBackoff, 2.0, 0, rand.NewSource(time.Now().UnixNano()))()
+	for {
+		scraper, err := twitter.NewScraper()
+		if err == nil {
+			registry.Register("twitter", twitter.HealthCheck(scraper, clk), health.Readiness)
+			return
+		}
+		delay := retry.Delay()
+		logrus.Errorf("Failed to log in to Twitter, retrying in %s: %v", delay, err)
+		status.Set("", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,495 @@
+// Package acl maintains the replicated list of peers allowed to use the DHT database.
+//
+// Every entry gives a peer a role. Entries are created and revoked by signed changes
+// that admins publish on the ACL topic; each node verifies and applies the changes it
+// receives and persists the result, so the list survives restarts and converges on
+// all nodes. The root admins come from configuration and cannot be revoked.
+package acl
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/consensus"
+)
+
+// RepublishInterval is how often admins rebroadcast the whole ACL.
+const RepublishInterval = time.Minute * 5
+
+// Role is the access level of a peer. There is no reader role: the DHT serves records
+// to any peer, private records are protected by encryption instead.
+type Role string
+
+const (
+	RoleNone   Role = ""
+	RoleWriter Role = "writer"
+	RoleAdmin  Role = "admin"
+)
+
+// Valid reports whether r is a role that can be granted.
+func (r Role) Valid() bool {
+	return r == RoleWriter || r == RoleAdmin
+}
+
+// CanWrite reports whether the role may write to the database.
+func (r Role) CanWrite() bool {
+	return r == RoleWriter || r == RoleAdmin
+}
+
+// Op is the kind of change made to the ACL.
+type Op string
+
+const (
+	OpGrant  Op = "grant"
+	OpRevoke Op = "revoke"
+)
+
+var (
+	ErrInvalidChange   = errors.New("invalid acl change")
+	ErrInvalidSig      = errors.New("acl change signature is invalid")
+	ErrNotAdmin        = errors.New("acl change was not issued by an admin")
+	ErrRootImmutable   = errors.New("the role of a root admin cannot be changed")
+	ErrKnownChange     = errors.New("acl change is already recorded")
+	ErrFutureChange    = errors.New("acl change is issued too far in the future")
+	ErrPrivKeyRequired = errors.New("a private key is required to sign acl changes")
+)
+
+// Change grants a role to a peer or revokes it. The latest change for a peer wins,
+// ordered by issue time, provided its issuer was an admin at that time.
+type Change struct {
+	Op        Op        `json:"op"`
+	PeerID    string    `json:"peerId"`
+	Role      Role      `json:"role,omitempty"`
+	Issued    time.Time `json:"issued"`
+	PublicKey []byte    `json:"publicKey"`
+	Signature []byte    `json:"signature,omitempty"`
+}
+
+// NewGrant creates a change issued at the given time giving peerID the role, signed
+// with the admin's key.
+func NewGrant(privKey crypto.PrivKey, peerID peer.ID, role Role, issued time.Time) (*Change, error) {
+	return newChange(privKey, OpGrant, peerID, role, issued.UTC())
+}
+
+// NewRevoke creates a change issued at the given time removing any role of peerID,
+// signed with the admin's key.
+func NewRevoke(privKey crypto.PrivKey, peerID peer.ID, issued time.Time) (*Change, error) {
+	return newChange(privKey, OpRevoke, peerID, RoleNone, issued.UTC())
+}
+
+func newChange(privKey crypto.PrivKey, op Op, peerID peer.ID, role Role, issued time.Time) (*Change, error) {
+	if privKey == nil {
+		return nil, ErrPrivKeyRequired
+	}
+	pubKey, err := crypto.MarshalPublicKey(privKey.GetPublic())
+	if err != nil {
+		return nil, err
+	}
+	change := &Change{Op: op, PeerID: peerID.String(), Role: role, Issued: issued, PublicKey: pubKey}
+	if err := change.validate(); err != nil {
+		return nil, err
+	}
+	data, err := change.signedBytes()
+	if err != nil {
+		return nil, err
+	}
+	change.Signature, err = consensus.SignData(privKey, data)
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (c *Change) signedBytes() ([]byte, error) {
+	unsigned := *c
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
+func (c *Change) validate() error {
+	if _, err := peer.Decode(c.PeerID); err != nil {
+		return fmt.Errorf("%w: peer id: %v", ErrInvalidChange, err)
+	}
+	switch c.Op {
+	case OpGrant:
+		if !c.Role.Valid() {
+			return fmt.Errorf("%w: unknown role %q", ErrInvalidChange, c.Role)
+		}
+	case OpRevoke:
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrInvalidChange, c.Op)
+	}
+	return nil
+}
+
+// Issuer verifies the signature and returns the peer that issued the change.
+func (c *Change) Issuer() (peer.ID, error) {
+	if err := c.validate(); err != nil {
+		return "", err
+	}
+	pubKey, err := crypto.UnmarshalPublicKey(c.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: public key: %v", ErrInvalidChange, err)
+	}
+	issuer, err := peer.IDFromPublicKey(pubKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := c.signedBytes()
+	if err != nil {
+		return "", err
+	}
+	valid, err := consensus.VerifySignature(pubKey, data, hex.EncodeToString(c.Signature))
+	if err != nil || !valid {
+		return "", ErrInvalidSig
+	}
+	return issuer, nil
+}
+
+// newerThan orders changes to the same peer, ties are broken by signature so that all
+// nodes agree on the winner.
+func (c *Change) newerThan(other *Change) bool {
+	if !c.Issued.Equal(other.Issued) {
+		return c.Issued.After(other.Issued)
+	}
+	return bytes.Compare(c.Signature, other.Signature) > 0
+}
+
+// Entry is the current role of a peer together with the change that set it.
+type Entry struct {
+	PeerID string  `json:"peerId"`
+	Role   Role    `json:"role"`
+	Change *Change `json:"change"`
+}
+
+// recorded is a change together with its verified issuer.
+type recorded struct {
+	change *Change
+	issuer peer.ID
+}
+
+// Registry is the local replica of the ACL. It keeps every change it accepted, so
+// the role of an issuer can be judged as of the time a change was issued and all
+// nodes reach the same roles whatever order the changes arrive in.
+type Registry struct {
+	mu sync.RWMutex
+	// history holds the changes of every peer, oldest first
+	history map[string][]recorded
+	// current holds the change that sets the role of every peer, derived from history
+	current    map[string]*Change
+	authorized map[*Change]bool
+	path       string
+	clock      clock.Clock
+	// IsRoot reports whether a peer is a configured root admin.
+	IsRoot func(peer.ID) bool
+	// Publish distributes changes to the other nodes, it may be nil.
+	Publish func(data []byte) error
+}
+
+// NewRegistry creates a registry persisted at path and loads the changes stored there.
+// isRoot identifies the configured root admins, clk is the clock changes are issued by.
+func NewRegistry(path string, isRoot func(peer.ID) bool, clk clock.Clock) (*Registry, error) {
+	r := &Registry{history: make(map[string][]recorded), path: path, clock: clk, IsRoot: isRoot}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Registry) isRoot(p peer.ID) bool {
+	return r.IsRoot != nil && r.IsRoot(p)
+}
+
+// Role returns the role of the peer, root admins are always admins.
+func (r *Registry) Role(p peer.ID) Role {
+	if r.isRoot(p) {
+		return RoleAdmin
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.roleLocked(p)
+}
+
+func (r *Registry) roleLocked(p peer.ID) Role {
+	return roleOf(r.current[p.String()])
+}
+
+func roleOf(change *Change) Role {
+	if change == nil || change.Op == OpRevoke {
+		return RoleNone
+	}
+	return change.Role
+}
+
+// CanWrite reports whether the peer may write to the database.
+func (r *Registry) CanWrite(p peer.ID) bool {
+	return r.Role(p).CanWrite()
+}
+
+// IsAdmin reports whether the peer may grant and revoke roles.
+func (r *Registry) IsAdmin(p peer.ID) bool {
+	return r.Role(p) == RoleAdmin
+}
+
+// Entries returns the peers that currently hold a role, sorted by peer ID.
+func (r *Registry) Entries() []Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := make([]Entry, 0, len(r.current))
+	for id, change := range r.current {
+		if change.Op == OpGrant {
+			entries = append(entries, Entry{PeerID: id, Role: change.Role, Change: change})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].PeerID < entries[j].PeerID })
+	return entries
+}
+
+// Apply verifies a change and records it. Changes must be signed by a peer that was an
+// admin when the change was issued and may not be issued more than clock.MaxHLCOffset
+// ahead of the local clock. The latest change of a peer sets its role, a change issued
+// by an admin that is later revoked as of an earlier time stops counting.
+func (r *Registry) Apply(change *Change) error {
+	issuer, err := change.Issuer()
+	if err != nil {
+		return err
+	}
+	subject, _ := peer.Decode(change.PeerID)
+
+	if r.isRoot(subject) {
+		return ErrRootImmutable
+	}
+	if ahead := change.Issued.Sub(r.clock.Now()); ahead > clock.MaxHLCOffset {
+		return fmt.Errorf("%w: %s", ErrFutureChange, ahead)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, known := range r.history[change.PeerID] {
+		if bytes.Equal(known.change.Signature, change.Signature) {
+			return ErrKnownChange
+		}
+	}
+	if !r.isRoot(issuer) && r.roleAtLocked(issuer, change.Issued) != RoleAdmin {
+		return fmt.Errorf("%w: %s", ErrNotAdmin, issuer)
+	}
+	r.recordLocked(change, issuer)
+	r.resolveLocked()
+	logrus.Infof("ACL %s %s %s by %s", change.Op, change.PeerID, change.Role, issuer)
+	return r.saveLocked()
+}
+
+// recordLocked adds a change to the history of its peer, keeping it ordered.
+func (r *Registry) recordLocked(change *Change, issuer peer.ID) {
+	history := append(r.history[change.PeerID], recorded{change: change, issuer: issuer})
+	sort.Slice(history, func(i, j int) bool { return history[j].change.newerThan(history[i].change) })
+	r.history[change.PeerID] = history
+}
+
+// resolveLocked derives the current change of every peer from the history.
+func (r *Registry) resolveLocked() {
+	r.authorized = make(map[*Change]bool)
+	r.current = make(map[string]*Change, len(r.history))
+	for id := range r.history {
+		if change := r.latestLocked(id, time.Time{}); change != nil {
+			r.current[id] = change
+		}
+	}
+}
+
+// roleAtLocked returns the role the peer held just before the given time.
+func (r *Registry) roleAtLocked(p peer.ID, at time.Time) Role {
+	if r.isRoot(p) {
+		return RoleAdmin
+	}
+	return roleOf(r.latestLocked(p.String(), at))
+}
+
+// latestLocked returns the latest authorized change of the peer issued before the
+// given time, or the latest one overall when the time is zero.
+func (r *Registry) latestLocked(id string, before time.Time) *Change {
+	history := r.history[id]
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		if !before.IsZero() && !entry.change.Issued.Before(before) {
+			continue
+		}
+		if r.authorizedLocked(entry) {
+			return entry.change
+		}
+	}
+	return nil
+}
+
+// authorizedLocked reports whether the issuer of the change was an admin when it was
+// issued. Only earlier changes are consulted, so the recursion always ends.
+func (r *Registry) authorizedLocked(entry recorded) bool {
+	if ok, known := r.authorized[entry.change]; known {
+		return ok
+	}
+	ok := r.roleAtLocked(entry.issuer, entry.change.Issued) == RoleAdmin
+	r.authorized[entry.change] = ok
+	return ok
+}
+
+// Grant signs, applies and publishes a change giving the peer the role.
+func (r *Registry) Grant(privKey crypto.PrivKey, p peer.ID, role Role) (*Change, error) {
+	change, err := newChange(privKey, OpGrant, p, role, r.nextIssueTime(p))
+	if err != nil {
+		return nil, err
+	}
+	return change, r.applyAndPublish(change)
+}
+
+// Revoke signs, applies and publishes a change removing the role of the peer.
+func (r *Registry) Revoke(privKey crypto.PrivKey, p peer.ID) (*Change, error) {
+	change, err := newChange(privKey, OpRevoke, p, RoleNone, r.nextIssueTime(p))
+	if err != nil {
+		return nil, err
+	}
+	return change, r.applyAndPublish(change)
+}
+
+// nextIssueTime returns the current time, or just after the peer's current change if
+// the clock has not moved past it, so a local change always supersedes the previous one.
+func (r *Registry) nextIssueTime(p peer.ID) time.Time {
+	now := r.clock.Now().UTC()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if history := r.history[p.String()]; len(history) > 0 {
+		if last := history[len(history)-1].change; !now.After(last.Issued) {
+			return last.Issued.Add(time.Nanosecond)
+		}
+	}
+	return now
+}
+
+func (r *Registry) applyAndPublish(change *Change) error {
+	if err := r.Apply(change); err != nil {
+		return err
+	}
+	return r.publish([]*Change{change})
+}
+
+func (r *Registry) publish(changes []*Change) error {
+	if r.Publish == nil || len(changes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	return r.Publish(data)
+}
+
+// RepublishLoop republishes the ACL every interval for as long as self is an admin.
+func (r *Registry) RepublishLoop(ctx context.Context, self peer.ID, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !r.IsAdmin(self) {
+				continue
+			}
+			if err := r.Republish(); err != nil {
+				logrus.Warnf("Failed to republish the acl: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Republish sends every recorded change, including revocations, so nodes that missed
+// them or joined later catch up.
+func (r *Registry) Republish() error {
+	r.mu.RLock()
+	changes := r.changesLocked()
+	r.mu.RUnlock()
+	return r.publish(changes)
+}
+
+// changesLocked returns every recorded change, oldest first.
+func (r *Registry) changesLocked() []*Change {
+	var changes []*Change
+	for _, history := range r.history {
+		for _, entry := range history {
+			changes = append(changes, entry.change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[j].newerThan(changes[i]) })
+	return changes
+}
+
+// HandleMessage implements pubsub.SubscriptionHandler for the ACL topic.
+func (r *Registry) HandleMessage(msg *pubsub.Message) {
+	var changes []*Change
+	if err := json.Unmarshal(msg.Data, &changes); err != nil {
+		logrus.Errorf("Failed to unmarshal acl changes: %v", err)
+		return
+	}
+	// admins may be granted and then act within the same batch
+	sort.Slice(changes, func(i, j int) bool { return changes[j].newerThan(changes[i]) })
+	for _, change := range changes {
+		if err := r.Apply(change); err != nil && !errors.Is(err, ErrKnownChange) {
+			logrus.Warnf("Rejected acl change for %s from %s: %v", change.PeerID, msg.ReceivedFrom, err)
+		}
+	}
+}
+
+func (r *Registry) load() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var changes []*Change
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return fmt.Errorf("invalid acl file %s: %w", r.path, err)
+	}
+	for _, change := range changes {
+		// the roles of the issuers are judged again when the history is resolved
+		issuer, err := change.Issuer()
+		if err != nil {
+			logrus.Warnf("Dropping acl change for %s from %s: %v", change.PeerID, r.path, err)
+			continue
+		}
+		r.recordLocked(change, issuer)
+	}
+	r.resolveLocked()
+	return nil
+}
+
+func (r *Registry) saveLocked() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.changesLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
@@ -0,0 +1,151 @@
+package acl
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+type testPeer struct {
+	key crypto.PrivKey
+	id  peer.ID
+}
+
+func newTestPeer(t *testing.T) testPeer {
+	key, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+	return testPeer{key: key, id: id}
+}
+
+func TestRegistryRoles(t *testing.T) {
+	root, admin, writer, outsider := newTestPeer(t), newTestPeer(t), newTestPeer(t), newTestPeer(t)
+	registry, err := NewRegistry("", func(p peer.ID) bool { return p == root.id }, clock.Wall())
+	require.NoError(t, err)
+
+	assert.Equal(t, RoleAdmin, registry.Role(root.id))
+	assert.False(t, registry.CanWrite(writer.id))
+
+	_, err = registry.Grant(outsider.key, writer.id, RoleWriter)
+	assert.ErrorIs(t, err, ErrNotAdmin)
+
+	_, err = registry.Grant(root.key, admin.id, RoleAdmin)
+	require.NoError(t, err)
+	_, err = registry.Grant(admin.key, writer.id, RoleWriter)
+	require.NoError(t, err)
+	assert.True(t, registry.CanWrite(writer.id))
+	assert.False(t, registry.IsAdmin(writer.id))
+
+	// writers cannot hand out roles
+	_, err = registry.Grant(writer.key, outsider.id, RoleWriter)
+	assert.ErrorIs(t, err, ErrNotAdmin)
+
+	_, err = registry.Revoke(admin.key, writer.id)
+	require.NoError(t, err)
+	assert.Equal(t, RoleNone, registry.Role(writer.id))
+
+	_, err = registry.Revoke(admin.key, root.id)
+	assert.ErrorIs(t, err, ErrRootImmutable)
+
+	_, err = registry.Grant(root.key, writer.id, Role("owner"))
+	assert.ErrorIs(t, err, ErrInvalidChange)
+
+	assert.Len(t, registry.Entries(), 1)
+}
+
+func TestRegistryLatestChangeWins(t *testing.T) {
+	mock := clock.NewMock()
+	root, writer := newTestPeer(t), newTestPeer(t)
+	registry, err := NewRegistry("", func(p peer.ID) bool { return p == root.id }, mock)
+	require.NoError(t, err)
+
+	grant, err := NewGrant(root.key, writer.id, RoleWriter, mock.Now())
+	require.NoError(t, err)
+	mock.Add(time.Second)
+	revoke, err := NewRevoke(root.key, writer.id, mock.Now())
+	require.NoError(t, err)
+
+	// changes may arrive out of order, the revocation still wins
+	require.NoError(t, registry.Apply(revoke))
+	require.NoError(t, registry.Apply(grant))
+	assert.False(t, registry.CanWrite(writer.id))
+	assert.ErrorIs(t, registry.Apply(grant), ErrKnownChange)
+
+	revoke.PeerID = root.id.String()
+	assert.ErrorIs(t, registry.Apply(revoke), ErrInvalidSig)
+}
+
+func TestRegistryJudgesIssuersAtIssueTime(t *testing.T) {
+	mock := clock.NewMock()
+	root, admin, writer := newTestPeer(t), newTestPeer(t), newTestPeer(t)
+	isRoot := func(p peer.ID) bool { return p == root.id }
+
+	appoint, err := NewGrant(root.key, admin.id, RoleAdmin, mock.Now())
+	require.NoError(t, err)
+	mock.Add(time.Second)
+	before, err := NewGrant(admin.key, writer.id, RoleWriter, mock.Now())
+	require.NoError(t, err)
+	mock.Add(time.Second)
+	dismiss, err := NewRevoke(root.key, admin.id, mock.Now())
+	require.NoError(t, err)
+	mock.Add(time.Second)
+	after, err := NewGrant(admin.key, writer.id, RoleAdmin, mock.Now())
+	require.NoError(t, err)
+
+	// every delivery order ends with the writer role granted while the admin still was one
+	orders := [][]*Change{
+		{appoint, before, after, dismiss},
+		{appoint, before, dismiss, after},
+		{dismiss, appoint, after, before},
+	}
+	for _, order := range orders {
+		registry, err := NewRegistry("", isRoot, mock)
+		require.NoError(t, err)
+		for _, change := range order {
+			_ = registry.Apply(change)
+		}
+		assert.Equal(t, RoleWriter, registry.Role(writer.id))
+		assert.Equal(t, RoleNone, registry.Role(admin.id))
+	}
+}
+
+func TestRegistryRejectsFutureChanges(t *testing.T) {
+	mock := clock.NewMock()
+	root, writer := newTestPeer(t), newTestPeer(t)
+	registry, err := NewRegistry("", func(p peer.ID) bool { return p == root.id }, mock)
+	require.NoError(t, err)
+
+	grant, err := NewGrant(root.key, writer.id, RoleWriter, mock.Now().Add(time.Hour*24*365))
+	require.NoError(t, err)
+	assert.ErrorIs(t, registry.Apply(grant), ErrFutureChange)
+	assert.False(t, registry.CanWrite(writer.id))
+}
+
+func TestRegistryPersistence(t *testing.T) {
+	root, writer := newTestPeer(t), newTestPeer(t)
+	isRoot := func(p peer.ID) bool { return p == root.id }
+	path := filepath.Join(t.TempDir(), "acl.json")
+
+	registry, err := NewRegistry(path, isRoot, clock.Wall())
+	require.NoError(t, err)
+	var published [][]byte
+	registry.Publish = func(data []byte) error {
+		published = append(published, data)
+		return nil
+	}
+	_, err = registry.Grant(root.key, writer.id, RoleWriter)
+	require.NoError(t, err)
+	assert.Len(t, published, 1)
+
+	reloaded, err := NewRegistry(path, isRoot, clock.Wall())
+	require.NoError(t, err)
+	assert.True(t, reloaded.CanWrite(writer.id))
+}
@@ -0,0 +1,42 @@
+package acl_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/acl"
+	"github.com/masa-finance/masa-oracle/pkg/testharness"
+)
+
+func TestACLChangesReplicate(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 3})
+	for _, node := range h.Nodes {
+		node.Config.AclAdmins = []string{h.ID(0).String()}
+	}
+	h.WaitForRoutingTables()
+
+	// the ACL topic mesh forms asynchronously, republish until the grant arrives
+	_, err := h.Node(0).ACL.Grant(h.Node(0).KeyManager.Libp2pPrivKey, h.ID(1), acl.RoleWriter)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_ = h.Node(0).ACL.Republish()
+		return h.Node(2).ACL.CanWrite(h.ID(1))
+	}, testharness.ConvergeTimeout, time.Second, "the grant should reach node 2")
+
+	key := "/db/shared"
+	assert.True(t, h.Node(2).IsDBWriter(h.ID(1), key))
+	assert.False(t, h.Node(2).IsDBWriter(h.ID(2), key))
+	// outside of the namespaces every node writes only the key named after itself
+	assert.True(t, h.Node(2).IsDBWriter(h.ID(2), "/db/"+h.ID(2).String()))
+	assert.False(t, h.Node(2).IsDBWriter(h.ID(2), "/db/shared/"+h.ID(2).String()))
+
+	_, err = h.Node(0).ACL.Revoke(h.Node(0).KeyManager.Libp2pPrivKey, h.ID(1))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_ = h.Node(0).ACL.Republish()
+		return !h.Node(2).IsDBWriter(h.ID(1), key)
+	}, testharness.ConvergeTimeout, time.Second, "the revocation should reach node 2")
+}
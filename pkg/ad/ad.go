@@ -22,6 +22,13 @@ type SubscriptionHandler struct {
 	mu      sync.Mutex
 }
 
+// List returns a copy of the collected ads.
+func (handler *SubscriptionHandler) List() []Ad {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	return append([]Ad{}, handler.Ads...)
+}
+
 // HandleMessage implement subscription handler here
 func (handler *SubscriptionHandler) HandleMessage(message *pubsub.Message) {
 	logrus.Info("Received a message")
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/masa-finance/masa-oracle/pkg/acl"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+)
+
+type aclChangeRequest struct {
+	PeerID string   `json:"peerId"`
+	Role   acl.Role `json:"role"`
+}
+
+// GetACLHandler lists the peers holding a role in the database ACL.
+func (api *API) GetACLHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if api.Node == nil || api.Node.ACL == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "An unexpected error occurred.",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    api.Node.ACL.Entries(),
+			"role":    api.Node.ACL.Role(api.Node.Host.ID()),
+		})
+	}
+}
+
+// GrantACLRoleHandler grants a role to a peer. This node must be an ACL admin.
+func (api *API) GrantACLRoleHandler() gin.HandlerFunc {
+	return api.aclChangeHandler(func(p peer.ID, role acl.Role) (*acl.Change, error) {
+		return db.GrantRole(api.Node, p, role)
+	})
+}
+
+// RevokeACLRoleHandler revokes the role of a peer. This node must be an ACL admin.
+func (api *API) RevokeACLRoleHandler() gin.HandlerFunc {
+	return api.aclChangeHandler(func(p peer.ID, _ acl.Role) (*acl.Change, error) {
+		return db.RevokeRole(api.Node, p)
+	})
+}
+
+func (api *API) aclChangeHandler(apply func(peer.ID, acl.Role) (*acl.Change, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req aclChangeRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "invalid request",
+			})
+			return
+		}
+		peerID, err := peer.Decode(req.PeerID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "invalid peerId",
+			})
+			return
+		}
+		change, err := apply(peerID, req.Role)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, acl.ErrNotAdmin) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    change,
+		})
+	}
+}
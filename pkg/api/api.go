@@ -8,14 +8,22 @@ import (
 	"github.com/gin-gonic/gin"
 
 	masa "github.com/masa-finance/masa-oracle/pkg"
+	"github.com/masa-finance/masa-oracle/pkg/auth"
+	"github.com/masa-finance/masa-oracle/pkg/db"
 )
 
 type API struct {
 	Node *masa.OracleNode
+	// DB is the record store of the node.
+	DB *db.Database
+	// Auth authenticates the clients of the routes that require a scope.
+	Auth *auth.Authenticator
+	// Limiter applies the rate limits and quotas of the route groups, nil for none.
+	Limiter *RateLimiter
 }
 
-func NewAPI(node *masa.OracleNode) *API {
-	return &API{Node: node}
+func NewAPI(node *masa.OracleNode, database *db.Database) *API {
+	return &API{Node: node, DB: database}
 }
 
 func GetPathInt(ctx *gin.Context, name string) (int, error) {
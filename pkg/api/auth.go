@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/auth"
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+)
+
+// principalKey is the context key of the authenticated client.
+const principalKey = "principal"
+
+// NewAuthenticator trusts the tokens signed by the node key and the configured
+// operator keys, and accepts the configured API keys. Invalid entries are logged and
+// skipped.
+func (api *API) NewAuthenticator(cfg *config.AppConfig) *auth.Authenticator {
+	clk := clock.Wall()
+	if api.Node != nil {
+		clk = api.Node.Clock
+	}
+	authenticator, _ := auth.NewAuthenticator(clk)
+	if api.Node != nil && api.Node.KeyManager != nil {
+		if err := authenticator.TrustIssuer(api.Node.KeyManager.Libp2pPubKey); err != nil {
+			logrus.Errorf("Failed to trust the node key for API tokens: %v", err)
+		}
+	}
+	for _, key := range cfg.ApiOperatorKeys {
+		if err := authenticator.TrustIssuerHex(key); err != nil {
+			logrus.Errorf("Skipping API operator key %s: %v", key, err)
+		}
+	}
+	for i, key := range cfg.ApiKeys {
+		if err := authenticator.AddAPIKey(key); err != nil {
+			logrus.Errorf("Skipping API key %d: %v", i+1, err)
+		}
+	}
+	return authenticator
+}
+
+// Require rejects requests without credentials for the scope. Credentials are taken
+// from a bearer Authorization header, the X-API-Key header or, for clients such as
+// EventSource that cannot set headers, the access_token query parameter.
+func (api *API) Require(scope auth.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := api.authorize(c, scope); err != nil {
+			c.AbortWithStatusJSON(err.Status, gin.H{
+				"success": false,
+				"message": err.Message,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorize authenticates the client of the request and checks its scope. Public
+// routes, with an empty scope, are open to everybody.
+func (api *API) authorize(c *gin.Context, scope auth.Scope) *APIError {
+	if scope == "" {
+		return nil
+	}
+	credential := credential(c)
+	principal, err := api.authenticate(credential, scope)
+	if err != nil {
+		if err.Status == http.StatusUnauthorized {
+			if credential != "" {
+				logrus.Debugf("Rejected API request to %s from %s: %v", c.FullPath(), c.ClientIP(), err)
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="masa-node"`)
+		}
+		return err
+	}
+	c.Set(principalKey, principal)
+	return nil
+}
+
+// authenticate returns the client holding the credential when it has the scope.
+func (api *API) authenticate(credential string, scope auth.Scope) (*auth.Principal, *APIError) {
+	if api.Auth == nil {
+		return nil, newAPIError(http.StatusServiceUnavailable, "authentication is not configured")
+	}
+	principal, err := api.Auth.Authenticate(credential)
+	if err != nil {
+		return nil, newAPIError(http.StatusUnauthorized, "%s", err.Error())
+	}
+	if !principal.Scope.Allows(scope) {
+		return nil, newAPIError(http.StatusForbidden, "the %s scope is required", scope)
+	}
+	return principal, nil
+}
+
+func credential(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		if scheme, value, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(value)
+		}
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	return c.Query("access_token")
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/masa-finance/masa-oracle/pkg/db"
+)
+
+// BatchGetHandler reads many keys in one request. Every key gets its own result with
+// the HTTP status GET /dht would have answered, the response fails as a whole only for
+// malformed requests.
+func (api *API) BatchGetHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request BatchGetRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			request.Keys = nil
+		}
+		items, failed, err := api.batchGet(c, request)
+		renderBatch(c, items, failed, err)
+	}
+}
+
+// BatchPutHandler writes many keys in one request, with the same options per item as
+// POST /dht and a result per item.
+func (api *API) BatchPutHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request BatchPutRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			request.Items = nil
+		}
+		items, failed, err := api.batchPut(c, request)
+		renderBatch(c, items, failed, err)
+	}
+}
+
+// batchGet runs a batch read and returns a result per key and the number of failed
+// keys.
+func (api *API) batchGet(c *gin.Context, request BatchGetRequest) ([]BatchItem, int, error) {
+	if len(request.Keys) == 0 {
+		return nil, 0, newAPIError(http.StatusBadRequest, "expected a non-empty list of keys")
+	}
+	ctx, cancel := batchContext(c, request.Timeout)
+	defer cancel()
+	results, err := api.DB.BatchGet(ctx, request.Keys)
+	if err != nil {
+		return nil, 0, err
+	}
+	items, failed := batchItems(results)
+	for i, result := range results {
+		if result.Record == nil {
+			continue
+		}
+		items[i].Version = result.Record.Seq
+		items[i].Private = result.Record.Private
+		items[i].Expires = expiresAt(result.Record.Expires)
+		if result.Err == nil {
+			items[i].Value = jsonValue(result.Value)
+		}
+	}
+	return items, failed, nil
+}
+
+// batchPut runs a batch write and returns a result per item and the number of failed
+// items. Malformed items fail the whole batch.
+func (api *API) batchPut(c *gin.Context, request BatchPutRequest) ([]BatchItem, int, error) {
+	if len(request.Items) == 0 {
+		return nil, 0, newAPIError(http.StatusBadRequest, "expected a non-empty list of items")
+	}
+	puts := make([]db.BatchPut, len(request.Items))
+	for i, item := range request.Items {
+		if item.Key == "" || len(item.Value) == 0 {
+			return nil, 0, newAPIError(http.StatusBadRequest, "item %d needs a key and a value", i)
+		}
+		put, err := toBatchPut(item)
+		if err != nil {
+			return nil, 0, err
+		}
+		puts[i] = put
+	}
+	ctx, cancel := batchContext(c, request.Timeout)
+	defer cancel()
+	results, err := api.DB.BatchWrite(ctx, puts)
+	if err != nil {
+		return nil, 0, err
+	}
+	items, failed := batchItems(results)
+	for i, result := range results {
+		if result.Err == nil {
+			items[i].Version = result.Record.Seq
+		}
+	}
+	return items, failed, nil
+}
+
+// batchContext bounds a batch by the request and the optional timeout in seconds.
+func batchContext(c *gin.Context, seconds float64) (ctx context.Context, cancel context.CancelFunc) {
+	timeout := db.BatchTimeout
+	if seconds > 0 && time.Duration(seconds*float64(time.Second)) < timeout {
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	return context.WithTimeout(c.Request.Context(), timeout)
+}
+
+func batchItems(results []db.BatchResult) ([]BatchItem, int) {
+	items := make([]BatchItem, len(results))
+	failed := 0
+	for i, result := range results {
+		items[i] = BatchItem{
+			Key:     result.Key,
+			Success: result.Err == nil,
+			Status:  errorStatus(result.Err),
+		}
+		if result.Err != nil {
+			failed++
+			items[i].Message = result.Err.Error()
+		}
+	}
+	return items, failed
+}
+
+func renderBatch(c *gin.Context, items []BatchItem, failed int, err error) {
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    items,
+		"total":   len(items),
+		"failed":  failed,
+	})
+}
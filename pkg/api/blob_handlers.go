@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ipfs/go-cid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/db"
+)
+
+// PostBlobHandler stores the request body as a blob and returns its CID.
+func (api *API) PostBlobHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		blobs := api.DB.BlobStore()
+		if blobs == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"success": false,
+				"message": "blob store is not initialized",
+			})
+			return
+		}
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, db.MaxBlobSize)
+		root, size, err := blobs.Put(c.Request.Context(), body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) || errors.Is(err, db.ErrBlobTooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"success": false,
+					"message": db.ErrBlobTooLarge.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data": gin.H{
+				"cid":  root.String(),
+				"size": size,
+			},
+		})
+	}
+}
+
+// GetBlobHandler streams the blob with the given CID, fetching it from the nodes
+// providing it when it is not stored locally.
+func (api *API) GetBlobHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		blobs := api.DB.BlobStore()
+		if blobs == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"success": false,
+				"message": "blob store is not initialized",
+			})
+			return
+		}
+		root, err := cid.Decode(c.Param("cid"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "invalid cid",
+			})
+			return
+		}
+		manifest, err := blobs.Stat(c.Request.Context(), root)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, db.ErrBlobNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+
+		sendBlob(c, blobs, root, manifest.Size)
+	}
+}
+
+// sendBlob streams a blob whose size is known.
+func sendBlob(c *gin.Context, blobs *db.BlobStore, root cid.Cid, size int64) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Length", strconv.FormatInt(size, 10))
+	c.Header("ETag", `"`+root.String()+`"`)
+	c.Status(http.StatusOK)
+	if _, err := blobs.Get(c.Request.Context(), root, c.Writer); err != nil {
+		// the headers are sent already, the client sees a truncated body
+		logrus.Errorf("Failed to send blob %s: %v", root, err)
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/masa-finance/masa-oracle/pkg/network"
+)
+
+// GetDiagnosticsHandler reports the DHT mode, reachability and a connectivity summary of the node.
+func (api *API) GetDiagnosticsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if api.Node == nil || api.Node.DHT == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "An unexpected error occurred.",
+			})
+			return
+		}
+
+		listenAddrs := make([]string, 0)
+		for _, addr := range api.Node.Host.Addrs() {
+			listenAddrs = append(listenAddrs, addr.String())
+		}
+		monitor := api.Node.Connectivity
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data": gin.H{
+				"peerId":           api.Node.Host.ID().String(),
+				"dhtMode":          network.DHTMode(api.Node.DHT),
+				"reachability":     monitor.Reachability().String(),
+				"routingTableSize": api.Node.DHT.RoutingTable().Size(),
+				"connectedPeers":   len(api.Node.Host.Network().Peers()),
+				"listenAddrs":      listenAddrs,
+				"dialFailures":     len(monitor.DialFailures()),
+			},
+		})
+	}
+}
+
+// GetRoutingTableHandler lists the DHT routing table contents per bucket.
+func (api *API) GetRoutingTableHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if api.Node == nil || api.Node.DHT == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "An unexpected error occurred.",
+			})
+			return
+		}
+
+		buckets := network.GetRoutingTableBuckets(api.Node.DHT)
+		c.JSON(http.StatusOK, gin.H{
+			"success":    true,
+			"data":       buckets,
+			"totalCount": api.Node.DHT.RoutingTable().Size(),
+		})
+	}
+}
+
+// GetPeerDiagnosticsHandler lists protocols, agent version, latency and connections of every connected peer.
+func (api *API) GetPeerDiagnosticsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if api.Node == nil || api.Node.Host == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "An unexpected error occurred.",
+			})
+			return
+		}
+
+		peers := network.GetPeerDiagnostics(api.Node.Host, api.Node.DHT)
+		c.JSON(http.StatusOK, gin.H{
+			"success":    true,
+			"data":       peers,
+			"totalCount": len(peers),
+		})
+	}
+}
+
+// GetDialFailuresHandler lists the most recent failed outbound dials with their reasons.
+func (api *API) GetDialFailuresHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if api.Node == nil || api.Node.Host == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "An unexpected error occurred.",
+			})
+			return
+		}
+
+		failures := api.Node.Connectivity.DialFailures()
+		c.JSON(http.StatusOK, gin.H{
+			"success":    true,
+			"data":       failures,
+			"totalCount": len(failures),
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	masa "github.com/masa-finance/masa-oracle/pkg"
+	"github.com/masa-finance/masa-oracle/pkg/api/pb"
+	"github.com/masa-finance/masa-oracle/pkg/auth"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+)
+
+// grpcMethod is the scope and the route group of a gRPC method, they match the ones
+// of the /api/v1 route serving the same operation.
+type grpcMethod struct {
+	Scope auth.Scope
+	Group string
+}
+
+var grpcMethods = map[string]grpcMethod{
+	pb.NodeService_ListNodes_FullMethodName:         {Scope: auth.ScopeRead, Group: GroupRead},
+	pb.NodeService_GetNode_FullMethodName:           {Scope: auth.ScopeRead, Group: GroupRead},
+	pb.NodeService_ListPeers_FullMethodName:         {Scope: auth.ScopeRead, Group: GroupRead},
+	pb.NodeService_GetRecord_FullMethodName:         {Scope: auth.ScopeRead, Group: GroupRead},
+	pb.NodeService_PutRecord_FullMethodName:         {Scope: auth.ScopeWrite, Group: GroupWrite},
+	pb.NodeService_Watch_FullMethodName:             {Scope: auth.ScopeRead, Group: GroupRead},
+	pb.NodeService_CreateTopic_FullMethodName:       {Scope: auth.ScopeWrite, Group: GroupWrite},
+	pb.NodeService_Publish_FullMethodName:           {Scope: auth.ScopeWrite, Group: GroupPublish},
+	pb.NodeService_Subscribe_FullMethodName:         {Scope: auth.ScopeRead, Group: GroupRead},
+	pb.NodeService_PublishNodeStatus_FullMethodName: {Scope: auth.ScopeWrite, Group: GroupPublish},
+}
+
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusRequestEntityTooLarge: codes.InvalidArgument,
+	http.StatusUnprocessableEntity:   codes.InvalidArgument,
+	http.StatusPreconditionRequired:  codes.FailedPrecondition,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// NewGRPCServer creates the gRPC server of the node API. Its clients present the same
+// credentials as the REST clients and, given the limiter of the REST API, share its
+// rate limits and daily quotas. The server uses TLS when the certificate and key files
+// are configured.
+func NewGRPCServer(node *masa.OracleNode, database *db.Database, cfg *config.AppConfig, limiter *RateLimiter) (*grpc.Server, error) {
+	api := NewAPI(node, database)
+	api.Auth = api.NewAuthenticator(cfg)
+	api.Limiter = limiter
+
+	var options []grpc.ServerOption
+	switch {
+	case cfg.GrpcTlsCert != "" && cfg.GrpcTlsKey != "":
+		creds, err := credentials.NewServerTLSFromFile(cfg.GrpcTlsCert, cfg.GrpcTlsKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the gRPC TLS certificate: %w", err)
+		}
+		options = append(options, grpc.Creds(creds))
+	case cfg.GrpcTlsCert != "" || cfg.GrpcTlsKey != "":
+		return nil, errors.New("the gRPC TLS certificate and key must be set together")
+	default:
+		logrus.Warn("The gRPC API is served without TLS")
+	}
+	return api.newGRPCServer(options...), nil
+}
+
+func (api *API) newGRPCServer(options ...grpc.ServerOption) *grpc.Server {
+	options = append(options,
+		grpc.ChainUnaryInterceptor(api.unaryInterceptor),
+		grpc.ChainStreamInterceptor(api.streamInterceptor),
+	)
+	server := grpc.NewServer(options...)
+	pb.RegisterNodeServiceServer(server, &grpcService{api: api})
+	return server
+}
+
+func (api *API) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := api.admit(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (api *API) streamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := api.admit(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// admit authenticates the client of a call and counts the call against the limits of
+// its method. Rejected calls get the seconds to wait in the retry-after header.
+func (api *API) admit(ctx context.Context, fullMethod string) error {
+	method, ok := grpcMethods[fullMethod]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+	}
+	credential := grpcCredential(ctx)
+	principal, err := api.authenticate(credential, method.Scope)
+	if err != nil {
+		if err.Status == http.StatusUnauthorized && credential != "" {
+			client := "unknown"
+			if p, ok := peer.FromContext(ctx); ok {
+				client = p.Addr.String()
+			}
+			logrus.Debugf("Rejected gRPC call to %s from %s: %v", fullMethod, client, err)
+		}
+		return grpcError(err)
+	}
+	seconds, err := api.allow(ctx, method.Group, principal.Name)
+	if err != nil {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(seconds)))
+		return grpcError(err)
+	}
+	return nil
+}
+
+// grpcCredential takes the credential of a call from a bearer authorization header or
+// the x-api-key header.
+func grpcCredential(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, header := range md.Get("authorization") {
+		if scheme, value, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(value)
+		}
+	}
+	if keys := md.Get("x-api-key"); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// grpcError maps the errors of the node to gRPC status errors, with the code matching
+// the HTTP status of the REST API.
+func grpcError(err error) error {
+	apiErr := toAPIError(err)
+	code, ok := grpcCodes[apiErr.Status]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, apiErr.Message)
+}
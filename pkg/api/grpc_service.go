@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/masa-finance/masa-oracle/pkg/api/pb"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/nodestatus"
+	"github.com/masa-finance/masa-oracle/pkg/pubsub"
+)
+
+// grpcService implements pb.NodeServiceServer on top of the operations of the REST
+// API.
+type grpcService struct {
+	pb.UnimplementedNodeServiceServer
+	api *API
+}
+
+func (s *grpcService) ListNodes(_ context.Context, request *pb.ListNodesRequest) (*pb.ListNodesResponse, error) {
+	query := NodePageQuery{PageNbr: int(request.PageNbr), PageSize: int(request.PageSize)}
+	if query.PageSize == 0 {
+		query.PageSize = config.PageSize
+	}
+	nodes, total, err := s.api.listNodes(query)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response := &pb.ListNodesResponse{Nodes: make([]*pb.Node, len(nodes)), Total: int32(total)}
+	for i := range nodes {
+		response.Nodes[i] = toPBNode(nodes[i])
+	}
+	return response, nil
+}
+
+func (s *grpcService) GetNode(_ context.Context, request *pb.GetNodeRequest) (*pb.Node, error) {
+	node, err := s.api.getNode(request.PeerId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toPBNode(node), nil
+}
+
+func (s *grpcService) ListPeers(_ context.Context, request *pb.ListPeersRequest) (*pb.ListPeersResponse, error) {
+	peers, err := s.api.listPeers(request.Addresses)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response := &pb.ListPeersResponse{Peers: make([]*pb.Peer, len(peers))}
+	for i, p := range peers {
+		response.Peers[i] = &pb.Peer{PeerId: p.PeerID, Addresses: p.Addresses}
+	}
+	return response, nil
+}
+
+func (s *grpcService) GetRecord(_ context.Context, request *pb.GetRecordRequest) (*pb.Record, error) {
+	if request.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "a key is required")
+	}
+	record, value, err := s.api.readRecord(request.Key)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Record{
+		Key:     request.Key,
+		Version: record.Seq,
+		Value:   value,
+		Private: record.Private,
+		Expires: timestamp(expiresAt(record.Expires)),
+	}, nil
+}
+
+func (s *grpcService) PutRecord(ctx context.Context, request *pb.PutRecordRequest) (*pb.WriteResult, error) {
+	put := PutRequest{
+		Key:     request.Key,
+		Value:   request.Value,
+		Private: request.Private,
+		Readers: request.Readers,
+	}
+	if request.Ttl != nil {
+		put.TTL = request.Ttl.AsDuration().Seconds()
+	}
+	result, err := s.api.putRecord(ctx, put)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.WriteResult{Key: result.Key, Version: result.Version}, nil
+}
+
+func (s *grpcService) Watch(request *pb.WatchRequest, stream pb.NodeService_WatchServer) error {
+	watch, err := s.api.watch(request.Prefix)
+	if err != nil {
+		return grpcError(err)
+	}
+	defer watch.Close()
+	for {
+		select {
+		case update, ok := <-watch.Updates():
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(toPBUpdate(update, watch.Dropped())); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *grpcService) CreateTopic(_ context.Context, request *pb.CreateTopicRequest) (*pb.Ack, error) {
+	if request.Topic == "" {
+		return nil, status.Error(codes.InvalidArgument, "a topic is required")
+	}
+	if err := s.api.createTopic(request.Topic); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Ack{Message: "New topic created and subscribed successfully"}, nil
+}
+
+func (s *grpcService) Publish(_ context.Context, request *pb.PublishRequest) (*pb.Ack, error) {
+	if request.Topic == "" || request.Message == "" {
+		return nil, status.Error(codes.InvalidArgument, "a topic and a message are required")
+	}
+	if err := s.api.publishMessage(request.Topic, request.Message); err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Ack{Message: "Message posted to topic successfully"}, nil
+}
+
+func (s *grpcService) Subscribe(request *pb.SubscribeRequest, stream pb.NodeService_SubscribeServer) error {
+	messages, err := s.api.streamTopic(request.Topic)
+	if err != nil {
+		return grpcError(err)
+	}
+	defer messages.Close()
+	for {
+		select {
+		case message, ok := <-messages.Messages():
+			if !ok {
+				if err := messages.Err(); err != nil {
+					return grpcError(newAPIError(http.StatusTooManyRequests, "%s", err.Error()))
+				}
+				return nil
+			}
+			if err := stream.Send(toPBTopicMessage(request.Topic, message)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *grpcService) PublishNodeStatus(_ context.Context, request *pb.NodeStatus) (*pb.Ack, error) {
+	err := s.api.publishNodeStatus(nodestatus.NodeStatus{
+		PeerID:            request.PeerId,
+		IsActive:          request.IsActive,
+		IsStaked:          request.IsStaked,
+		IsWriterNode:      request.IsWriterNode,
+		AccumulatedUptime: request.AccumulatedUptime.AsDuration(),
+		CurrentUptime:     request.CurrentUptime.AsDuration(),
+		FirstJoined:       asTime(request.FirstJoined),
+		LastJoined:        asTime(request.LastJoined),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Ack{Message: "Message posted to topic successfully"}, nil
+}
+
+func toPBNode(node pubsub.NodeData) *pb.Node {
+	multiaddrs := make([]string, len(node.Multiaddrs))
+	for i, addr := range node.Multiaddrs {
+		multiaddrs[i] = addr.String()
+	}
+	return &pb.Node{
+		PeerId:            node.PeerId.String(),
+		Multiaddrs:        multiaddrs,
+		FirstJoined:       timestamp(&node.FirstJoined),
+		LastJoined:        timestamp(&node.LastJoined),
+		LastLeft:          timestamp(&node.LastLeft),
+		LastUpdated:       timestamp(&node.LastUpdated),
+		CurrentUptime:     durationpb.New(node.CurrentUptime),
+		AccumulatedUptime: durationpb.New(node.AccumulatedUptime),
+		EthAddress:        node.EthAddress,
+		Activity:          int32(node.Activity),
+		IsActive:          node.IsActive,
+		IsStaked:          node.IsStaked,
+		IsWriterNode:      node.IsWriterNode,
+	}
+}
+
+func toPBUpdate(update network.DBUpdate, dropped uint64) *pb.Update {
+	version := newVersion(update.Record)
+	item := &pb.Update{
+		Key:     update.Key,
+		Version: update.Record.Seq,
+		Hlc:     version.HLC,
+		Time:    timestamp(&version.Time),
+		Writer:  version.Writer,
+		Deleted: version.Deleted,
+		Private: version.Private,
+		Expires: timestamp(version.Expires),
+		Dropped: dropped,
+	}
+	if !update.Record.Deleted && !update.Record.Private {
+		item.Value = update.Record.Value
+	}
+	return item
+}
+
+func toPBTopicMessage(topic string, message pubsub.TopicMessage) *pb.TopicMessage {
+	return &pb.TopicMessage{
+		Topic:    topic,
+		From:     message.From.String(),
+		Data:     message.Data,
+		Received: timestamppb.New(message.Received),
+	}
+}
+
+// timestamp converts a time, nil and zero times are left unset.
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func asTime(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
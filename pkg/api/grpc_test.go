@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/masa-finance/masa-oracle/pkg/api/pb"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+)
+
+func newTestGRPCClient(t *testing.T, api *API) pb.NodeServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := api.newGRPCServer()
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewNodeServiceClient(conn)
+}
+
+func TestGRPCService(t *testing.T) {
+	client := newTestGRPCClient(t, newTestAPI(t, map[string]config.RateLimit{GroupRead: {Rate: 1, Burst: 3}}))
+	ctx := context.Background()
+	reader := metadata.AppendToOutgoingContext(ctx, "x-api-key", "reader")
+
+	_, err := client.GetNode(ctx, &pb.GetNodeRequest{PeerId: "abc"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.PutRecord(reader, &pb.PutRecordRequest{Key: "k", Value: []byte("{}")})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// the errors of the REST API map to status codes
+	_, err = client.GetNode(reader, &pb.GetNodeRequest{PeerId: "abc"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, "the node registry is not available", status.Convert(err).Message())
+	_, err = client.ListNodes(reader, &pb.ListNodesRequest{PageNbr: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// streams are authorized and limited before they start
+	watch, err := client.Watch(reader, &pb.WatchRequest{})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	var header metadata.MD
+	_, err = client.ListPeers(reader, &pb.ListPeersRequest{}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"1"}, header.Get("retry-after"))
+}
+
+func TestGRPCSharesRESTRateLimits(t *testing.T) {
+	api := newTestAPI(t, map[string]config.RateLimit{GroupRead: {Rate: 1, Burst: 2}})
+	router := newTestRouter(api)
+	client := newTestGRPCClient(t, api)
+	reader := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "reader")
+
+	// a client splitting its calls between the two APIs draws from one bucket
+	require.Equal(t, http.StatusOK, serve(router, http.MethodGet, V1Prefix+"/ads", "reader").Code)
+	_, err := client.GetNode(reader, &pb.GetNodeRequest{PeerId: "abc"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	_, err = client.GetNode(reader, &pb.GetNodeRequest{PeerId: "abc"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, http.StatusTooManyRequests, serve(router, http.MethodGet, V1Prefix+"/ads", "reader").Code)
+}
@@ -0,0 +1,252 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/masa-finance/masa-oracle/pkg/api"
+	"github.com/masa-finance/masa-oracle/pkg/api/pb"
+	"github.com/masa-finance/masa-oracle/pkg/auth"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/health"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/pubsub"
+	"github.com/masa-finance/masa-oracle/pkg/testharness"
+)
+
+func TestTopicStream(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 2})
+	topic := config.TopicWithVersion("chat")
+	for _, node := range h.Nodes {
+		require.NoError(t, node.PubSubManager.AddSubscription(topic, pubsub.NewTopicHandler(node.Clock)))
+	}
+	gin.SetMode(gin.TestMode)
+	server := httptest.NewServer(api.SetupRoutes(h.Node(1), nil, api.NewRateLimiter(nil, nil, h.Clock)))
+	defer server.Close()
+
+	// nodes accept the tokens signed by their own key
+	token, err := auth.NewToken(h.Node(1).KeyManager.Libp2pPrivKey, auth.ScopeRead, "", h.Clock.Now(), time.Hour)
+	require.NoError(t, err)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + api.V1Prefix + "/topics/chat/stream?access_token=" + token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	messages := make(chan api.TopicMessage, 16)
+	go func() {
+		for {
+			var message api.TopicMessage
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			select {
+			case messages <- message:
+			default:
+			}
+		}
+	}()
+
+	// the message is published again until the gossip mesh of the topic has formed
+	var message api.TopicMessage
+	require.Eventually(t, func() bool {
+		select {
+		case message = <-messages:
+			return true
+		default:
+			_ = h.Node(0).PubSubManager.PublishMessage(topic, "hello")
+			return false
+		}
+	}, testharness.ConvergeTimeout, testharness.PollInterval, "the stream of node 1 should deliver the message of node 0")
+	assert.Equal(t, "chat", message.Topic)
+	assert.Equal(t, h.ID(0).String(), message.From)
+	assert.Equal(t, "hello", message.Message)
+	assert.False(t, message.Received.IsZero())
+}
+
+func TestGRPCService(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 2, MemoryCache: true})
+	h.WaitForRoutingTables()
+	topic := config.TopicWithVersion("chat")
+	require.NoError(t, h.Node(0).PubSubManager.AddSubscription(topic, pubsub.NewTopicHandler(h.Node(0).Clock)))
+
+	server, err := api.NewGRPCServer(h.Node(1), h.DB(1), h.Node(1).Config, api.NewRateLimiter(nil, nil, h.Clock))
+	require.NoError(t, err)
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewNodeServiceClient(conn)
+	token, err := auth.NewToken(h.Node(1).KeyManager.Libp2pPrivKey, auth.ScopeWrite, "", h.Clock.Now(), time.Hour)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token))
+	defer cancel()
+
+	key := "profiles/" + h.ID(1).String()
+	written, err := client.PutRecord(ctx, &pb.PutRecordRequest{Key: key, Value: []byte(`{"name":"operator"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), written.Version)
+	record, err := client.GetRecord(ctx, &pb.GetRecordRequest{Key: key})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"operator"}`, string(record.Value))
+
+	node, err := client.GetNode(ctx, &pb.GetNodeRequest{PeerId: h.ID(1).String()})
+	require.NoError(t, err)
+	assert.Equal(t, h.ID(1).String(), node.PeerId)
+
+	_, err = client.CreateTopic(ctx, &pb.CreateTopicRequest{Topic: "chat"})
+	require.NoError(t, err)
+	stream, err := client.Subscribe(ctx, &pb.SubscribeRequest{Topic: "chat"})
+	require.NoError(t, err)
+	messages := make(chan *pb.TopicMessage, 16)
+	go func() {
+		for {
+			message, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case messages <- message:
+			default:
+			}
+		}
+	}()
+
+	// the message is published again until the gossip mesh of the topic has formed
+	var message *pb.TopicMessage
+	require.Eventually(t, func() bool {
+		select {
+		case message = <-messages:
+			return true
+		default:
+			_ = h.Node(0).PubSubManager.PublishMessage(topic, "hello")
+			return false
+		}
+	}, testharness.ConvergeTimeout, testharness.PollInterval, "the stream of node 1 should deliver the message of node 0")
+	assert.Equal(t, "chat", message.Topic)
+	assert.Equal(t, h.ID(0).String(), message.From)
+	assert.Equal(t, []byte("hello"), message.Data)
+}
+
+func TestHealthProbes(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 2, Configure: func(_ int, cfg *config.AppConfig) {
+		cfg.MinPeers = 1
+	}})
+	gin.SetMode(gin.TestMode)
+	server := httptest.NewServer(api.SetupRoutes(h.Node(1), nil, api.NewRateLimiter(nil, nil, h.Clock)))
+	defer server.Close()
+	probe := func(path string) (int, health.Report) {
+		response, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		var report health.Report
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&report))
+		return response.StatusCode, report
+	}
+
+	// the probes need no credentials, the node is ready once node 0 is in its routing table
+	require.Eventually(t, func() bool {
+		status, _ := probe("/readyz")
+		return status == http.StatusOK
+	}, testharness.ConvergeTimeout, testharness.PollInterval, "node 1 should become ready")
+	status, report := probe("/startupz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, health.Startup, report.Probe)
+	status, report = probe("/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "pubsub", report.Checks[0].Name)
+
+	// a stopped node fails its liveness probe
+	h.StopNode(1)
+	status, report = probe("/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, health.StatusFail, report.Checks[0].Status)
+}
+
+func TestDiagnosticsEndpoints(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 2})
+	h.WaitForRoutingTables()
+	gin.SetMode(gin.TestMode)
+	server := httptest.NewServer(api.SetupRoutes(h.Node(1), nil, api.NewRateLimiter(nil, nil, h.Clock)))
+	defer server.Close()
+	token, err := auth.NewToken(h.Node(1).KeyManager.Libp2pPrivKey, auth.ScopeRead, "", h.Clock.Now(), time.Hour)
+	require.NoError(t, err)
+	get := func(path string, data any) api.Envelope {
+		request, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		request.Header.Set("Authorization", "Bearer "+token)
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		envelope := api.Envelope{Data: data}
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&envelope))
+		return envelope
+	}
+
+	// a dial to a peer the node can no longer reach is listed with its reason
+	h.Partition([]int{0}, []int{1})
+	require.Eventually(t, func() bool {
+		return len(h.Node(1).Host.Network().Peers()) == 0
+	}, testharness.ConvergeTimeout, testharness.PollInterval, "the partition should close the connections")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	require.Error(t, h.Node(1).Host.Connect(ctx, peer.AddrInfo{ID: h.ID(0)}))
+
+	var failures []network.DialFailure
+	envelope := get(api.V1Prefix+"/diagnostics/dialFailures", &failures)
+	require.NotEmpty(t, failures)
+	assert.Equal(t, len(failures), envelope.Meta.Total)
+	assert.Equal(t, h.ID(0).String(), failures[0].PeerID)
+	assert.NotEmpty(t, failures[0].Reason)
+
+	var diagnostics api.Diagnostics
+	get(api.V1Prefix+"/diagnostics", &diagnostics)
+	assert.Equal(t, h.ID(1).String(), diagnostics.PeerID)
+	assert.GreaterOrEqual(t, diagnostics.DialFailures, len(failures))
+	assert.Equal(t, 0, diagnostics.ConnectedPeers)
+}
+
+func TestPublishPublicKeyUsesNodeKey(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 2})
+	gin.SetMode(gin.TestMode)
+	server := httptest.NewServer(api.SetupRoutes(h.Node(1), nil, api.NewRateLimiter(nil, nil, h.Clock)))
+	defer server.Close()
+	token, err := auth.NewToken(h.Node(1).KeyManager.Libp2pPrivKey, auth.ScopeAdmin, "", h.Clock.Now(), time.Hour)
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+api.V1Prefix+"/publicKeys", nil)
+	require.NoError(t, err)
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	var message pubsub.PublicKeyMessage
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&api.Envelope{Data: &message}))
+
+	// every node of the process publishes its own key
+	assert.Equal(t, h.Node(1).KeyManager.HexPubKey, message.PublicKey)
+	assert.Equal(t, h.ID(1).String(), message.Data)
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/masa-finance/masa-oracle/pkg/health"
+)
+
+// HealthHandler runs the checks of the probe. It answers 200 when every check passes and
+// 503 otherwise, with the result of each check.
+func (api *API) HealthHandler(probe health.Probe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if api.Node == nil || api.Node.Health == nil {
+			c.JSON(http.StatusServiceUnavailable, health.Report{
+				Probe:  probe,
+				Status: health.StatusFail,
+				Checks: []health.Result{},
+			})
+			return
+		}
+		report := api.Node.Health.Run(c.Request.Context(), probe)
+		status := http.StatusOK
+		if !report.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
+	"github.com/masa-finance/masa-oracle/pkg/namespace"
+)
+
+// GetNamespacesHandler lists the registered namespaces with their policies and schemas.
+func (api *API) GetNamespacesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if api.Node == nil || api.Node.Namespaces == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "An unexpected error occurred.",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    api.Node.Namespaces.List(),
+		})
+	}
+}
+
+// writeErrorResponse maps database write errors to HTTP status codes. Schema violations
+// are reported with the details of every failed constraint.
+func writeErrorResponse(c *gin.Context, key string, err error) {
+	var schemaErr *namespace.SchemaError
+	switch {
+	case errors.As(err, &schemaErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"success":   false,
+			"message":   err.Error(),
+			"key":       key,
+			"namespace": schemaErr.Namespace,
+			"details":   schemaErr.Details,
+		})
+	case errors.Is(err, namespace.ErrUnknownNamespace):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"key":     key,
+		})
+	case errors.Is(err, db.ErrInvalidReader), errors.Is(err, masacrypto.ErrUnsupportedKey):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"key":     key,
+		})
+	case errors.Is(err, db.ErrUnauthorized):
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"key":     key,
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": key,
+		})
+	}
+}
@@ -2,11 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/masa-finance/masa-oracle/pkg/db"
 	"math"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/libp2p/go-libp2p/core/peer"
 
 	masa "github.com/masa-finance/masa-oracle/pkg"
 	"github.com/masa-finance/masa-oracle/pkg/config"
@@ -75,8 +79,9 @@ func (api *API) GetNodeHandler() gin.HandlerFunc {
 			return
 		}
 		nd := *nodeData
-		nd.CurrentUptime = nodeData.GetCurrentUptime()
-		nd.AccumulatedUptime = nodeData.GetAccumulatedUptime()
+		now := api.Node.NodeTracker.Clock.Now()
+		nd.CurrentUptime = nodeData.GetCurrentUptime(now)
+		nd.AccumulatedUptime = nodeData.GetAccumulatedUptime(now)
 		nd.CurrentUptimeStr = pubsub.PrettyDuration(nd.CurrentUptime)
 		nd.AccumulatedUptimeStr = pubsub.PrettyDuration(nd.AccumulatedUptime)
 
@@ -166,13 +171,74 @@ func (api *API) GetFromDHT() gin.HandlerFunc {
 			})
 			return
 		}
+		record, err := api.DB.ReadRecord(keyStr)
+		if err != nil {
+			status, message := http.StatusInternalServerError, err.Error()
+			switch {
+			case errors.Is(err, db.ErrRecordNotFound):
+				status, message = http.StatusNotFound, "key not found"
+			case errors.Is(err, db.ErrReadTimeout):
+				status = http.StatusGatewayTimeout
+			}
+			c.JSON(status, gin.H{
+				"success": false,
+				"message": message,
+			})
+			return
+		}
+		value, err := api.DB.OpenRecord(record)
+		if err != nil {
+			// nodes that may not read a private record get the ciphertext only
+			status := http.StatusInternalServerError
+			if errors.Is(err, db.ErrAccessDenied) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{
+				"success":    false,
+				"message":    err.Error(),
+				"private":    true,
+				"ciphertext": json.RawMessage(record.Value),
+				"version":    record.Seq,
+			})
+			return
+		}
 		sharedData := db.SharedData{}
-		nodeVal := db.ReadData(api.Node, "/db/"+keyStr)
-		_ = json.Unmarshal(nodeVal, &sharedData)
+		_ = json.Unmarshal(value, &sharedData)
 
-		c.JSON(http.StatusOK, gin.H{
+		response := gin.H{
 			"success": true,
 			"message": sharedData,
+			"version": record.Seq,
+		}
+		if record.Private {
+			response["private"] = true
+		}
+		if record.Expires != 0 {
+			response["expires"] = time.Unix(0, record.Expires).UTC()
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// DeleteFromDHT deletes a key by writing a tombstone as its next version.
+func (api *API) DeleteFromDHT() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyStr := c.Query("key")
+		if len(keyStr) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "missing key param",
+			})
+			return
+		}
+		success, err := api.DB.DeleteData(keyStr)
+		if err != nil {
+			writeErrorResponse(c, keyStr, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": success,
+			"message": keyStr,
 		})
 	}
 }
@@ -189,7 +255,14 @@ func (api *API) PostToDHT() gin.HandlerFunc {
 			return
 		}
 
-		var keyStr = sharedData["key"].(string)
+		keyStr, ok := sharedData["key"].(string)
+		if !ok || keyStr == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "key must be a non-empty string",
+			})
+			return
+		}
 		jsonData, err := json.Marshal(sharedData["value"])
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -198,12 +271,24 @@ func (api *API) PostToDHT() gin.HandlerFunc {
 			})
 			return
 		}
-		success, err := db.WriteData(api.Node, "/db/"+keyStr, jsonData)
+		// an optional ttl in seconds lets the value expire
+		var ttl time.Duration
+		if seconds, ok := sharedData["ttl"].(float64); ok && seconds > 0 {
+			ttl = time.Duration(seconds * float64(time.Second))
+		}
+		var success bool
+		// private values are encrypted for this node and the optional list of reader peer IDs
+		if private, _ := sharedData["private"].(bool); private {
+			var readers []peer.ID
+			readers, err = parseReaders(sharedData["readers"])
+			if err == nil {
+				success, err = api.DB.WritePrivateData(keyStr, jsonData, readers, ttl)
+			}
+		} else {
+			success, err = api.DB.WriteDataWithTTL(keyStr, jsonData, ttl)
+		}
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"success": success,
-				"message": keyStr,
-			})
+			writeErrorResponse(c, keyStr, err)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
@@ -212,3 +297,35 @@ func (api *API) PostToDHT() gin.HandlerFunc {
 		})
 	}
 }
+
+// parseReaders decodes the reader peer IDs of a private value.
+func parseReaders(value interface{}) ([]peer.ID, error) {
+	if value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: readers must be a list of peer IDs", db.ErrInvalidReader)
+	}
+	ids := make([]string, len(list))
+	for i, item := range list {
+		ids[i], _ = item.(string)
+	}
+	return decodeReaders(ids)
+}
+
+// decodeReaders decodes a list of reader peer IDs.
+func decodeReaders(ids []string) ([]peer.ID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	readers := make([]peer.ID, 0, len(ids))
+	for _, str := range ids {
+		id, err := peer.Decode(str)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", db.ErrInvalidReader, str)
+		}
+		readers = append(readers, id)
+	}
+	return readers, nil
+}
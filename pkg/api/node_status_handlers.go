@@ -55,8 +55,9 @@ func (api *API) NodeStatusPageHandler() gin.HandlerFunc {
 			})
 			return
 		}
-		nodeData.CurrentUptime = nodeData.GetCurrentUptime()
-		nodeData.AccumulatedUptime = nodeData.GetAccumulatedUptime()
+		now := api.Node.NodeTracker.Clock.Now()
+		nodeData.CurrentUptime = nodeData.GetCurrentUptime(now)
+		nodeData.AccumulatedUptime = nodeData.GetAccumulatedUptime(now)
 		nodeData.AccumulatedUptimeStr = pubsub.PrettyDuration(nodeData.AccumulatedUptime)
 		c.HTML(http.StatusOK, "index.html", gin.H{
 			"Name":          "Masa Status Page",
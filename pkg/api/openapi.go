@@ -0,0 +1,312 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/masa-finance/masa-oracle/pkg/config"
+)
+
+// newOpenAPI generates the OpenAPI 3 document of the versioned API from its route
+// table. Schemas are derived from the json, form, binding and doc tags of the types.
+func newOpenAPI(endpoints []endpoint) map[string]any {
+	schemas := newSchemaRegistry()
+	paths := map[string]map[string]any{}
+	for _, e := range endpoints {
+		route := openAPIPath(e.Path)
+		if paths[route] == nil {
+			paths[route] = map[string]any{}
+		}
+		paths[route][strings.ToLower(e.Method)] = schemas.operation(e)
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Masa Oracle Node API",
+			"version": config.Version,
+		},
+		"servers": []any{map[string]any{"url": V1Prefix}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		"security": []any{
+			map[string]any{"bearer": []string{}},
+			map[string]any{"apiKey": []string{}},
+		},
+	}
+}
+
+// openAPIPath converts the gin parameters of a path, :name, to {name}.
+func openAPIPath(route string) string {
+	parts := strings.Split(route, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+type schemaRegistry struct {
+	components map[string]any
+	names      map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	r := &schemaRegistry{components: map[string]any{}, names: map[reflect.Type]string{}}
+	r.component(reflect.TypeOf(Meta{}))
+	r.component(reflect.TypeOf(APIError{}))
+	return r
+}
+
+func (r *schemaRegistry) operation(e endpoint) map[string]any {
+	op := map[string]any{
+		"summary":     e.Summary,
+		"operationId": operationID(e),
+		"x-scope":     string(e.Scope),
+		"x-rateLimit": e.group(),
+	}
+	var params []any
+	for _, segment := range strings.Split(e.Path, "/") {
+		if strings.HasPrefix(segment, ":") {
+			params = append(params, map[string]any{
+				"name": segment[1:], "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+	}
+	if e.Query != nil {
+		params = append(params, r.queryParameters(reflect.TypeOf(e.Query))...)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	switch {
+	case e.Body != nil:
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": r.schema(reflect.TypeOf(e.Body))}},
+		}
+	case e.Consumes != "":
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{e.Consumes: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}},
+		}
+	}
+
+	var ok map[string]any
+	switch {
+	case e.Produces == "text/event-stream":
+		ok = map[string]any{
+			"description": "A stream of server-sent events, the data of every event is described by the schema",
+			"content":     map[string]any{e.Produces: map[string]any{"schema": r.schema(reflect.TypeOf(e.Data))}},
+		}
+	case e.Produces != "":
+		ok = map[string]any{
+			"description": "The raw content",
+			"content":     map[string]any{e.Produces: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}},
+		}
+	default:
+		data := r.schema(reflect.TypeOf(e.Data))
+		envelope := map[string]any{
+			"success": map[string]any{"type": "boolean"},
+			"data":    data,
+		}
+		if e.List {
+			envelope["data"] = map[string]any{"type": "array", "items": data}
+			envelope["meta"] = ref("Meta")
+		}
+		ok = map[string]any{
+			"description": "Success",
+			"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{
+				"type":       "object",
+				"properties": envelope,
+				"required":   []string{"success", "data"},
+			}}},
+		}
+	}
+	op["responses"] = map[string]any{
+		"200": ok,
+		"default": map[string]any{
+			"description": "Failure, the status and error code tell why",
+			"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"success": map[string]any{"type": "boolean"},
+					"error":   ref("APIError"),
+				},
+				"required": []string{"success", "error"},
+			}}},
+		},
+	}
+	return op
+}
+
+// operationID derives an identifier such as getDhtVersions or getNodesByPeerID from
+// the method and path.
+func operationID(e endpoint) string {
+	id := strings.ToLower(e.Method)
+	for _, segment := range strings.Split(e.Path, "/") {
+		if segment == "" {
+			continue
+		}
+		if strings.HasPrefix(segment, ":") {
+			segment = "by" + strings.ToUpper(segment[1:2]) + segment[2:]
+		}
+		id += strings.ToUpper(segment[:1]) + segment[1:]
+	}
+	return id
+}
+
+func (r *schemaRegistry) queryParameters(t reflect.Type) []any {
+	var params []any
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("form")
+		if name == "" || name == "-" {
+			continue
+		}
+		param := map[string]any{
+			"name":     name,
+			"in":       "query",
+			"required": strings.Contains(field.Tag.Get("binding"), "required"),
+			"schema":   r.schema(field.Type),
+		}
+		if doc := field.Tag.Get("doc"); doc != "" {
+			param["description"] = doc
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schema returns the schema of a type. Named structs are added to the components and
+// referenced.
+func (r *schemaRegistry) schema(t reflect.Type) map[string]any {
+	if t == nil || t == rawMessageType {
+		// any JSON value
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]any{"type": "integer", "format": "int64", "description": "Nanoseconds"}
+	case t.Kind() == reflect.Struct && (t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)):
+		// types such as multiaddrs encode themselves as strings
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": r.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": r.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.object(t)
+		}
+		return ref(r.component(t))
+	default:
+		return map[string]any{}
+	}
+}
+
+// component registers a named struct, qualifying its name with the package when two
+// packages use the same name.
+func (r *schemaRegistry) component(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := r.components[name]; taken {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	r.names[t] = name
+	// reserve the name first, the struct may refer to itself
+	r.components[name] = map[string]any{}
+	r.components[name] = r.object(t)
+	return name
+}
+
+func (r *schemaRegistry) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	r.fields(t, properties, &required)
+	object := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+func (r *schemaRegistry) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			r.fields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := r.schema(field.Type)
+		if doc := field.Tag.Get("doc"); doc != "" {
+			if _, isRef := schema["$ref"]; isRef {
+				// siblings of $ref are ignored, wrap it to keep the description
+				schema = map[string]any{"allOf": []any{schema}}
+			}
+			schema["description"] = doc
+		}
+		properties[name] = schema
+		if strings.Contains(field.Tag.Get("binding"), "required") ||
+			(!strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer) {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -10,75 +11,27 @@ import (
 
 	"github.com/masa-finance/masa-oracle/pkg/config"
 	"github.com/masa-finance/masa-oracle/pkg/consensus"
-	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
 	"github.com/masa-finance/masa-oracle/pkg/pubsub"
 )
 
 func (api *API) PublishPublicKeyHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if api.Node == nil || api.Node.PubSubManager == nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Node or PubSubManager is not initialized"})
-			return
-		}
-
-		keyManager := masacrypto.KeyManagerInstance()
-
-		// Set the data to be signed as the signer's Peer ID
-		data := []byte(api.Node.Host.ID().String())
-
-		// Sign the data using the private key
-		signature, err := consensus.SignData(keyManager.Libp2pPrivKey, data)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to sign data: %v", err)})
-			return
-		}
-
-		// Serialize the public key message
-		msg := pubsub.PublicKeyMessage{
-			PublicKey: keyManager.HexPubKey,
-			Signature: hex.EncodeToString(signature),
-			Data:      string(data),
-		}
-		msgBytes, err := json.Marshal(msg)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to marshal public key message"})
-			return
-		}
-
-		// Publish the public key using its string representation, data, and signature
-		publicKeyTopic := config.TopicWithVersion(config.PublicKeyTopic)
-		if err := api.Node.PubSubManager.Publish(publicKeyTopic, msgBytes); err != nil {
+		if _, err := api.publishPublicKey(); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-
 		c.JSON(http.StatusOK, gin.H{"status": "Public key published successfully"})
 	}
 }
 
 func (api *API) GetPublicKeysHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if api.Node == nil || api.Node.PubSubManager == nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"message": "An unexpected error occurred.",
-			})
-			return
-		}
-
-		// Use the PublicKeyTopic constant from the masa package
-		handler, err := api.Node.PubSubManager.GetHandler(string(config.ProtocolWithVersion(config.PublicKeyTopic)))
+		publicKeyHandler, err := api.publicKeyHandler()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		publicKeyHandler, ok := handler.(*pubsub.PublicKeySubscriptionHandler)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "handler is not of type PublicKeySubscriptionHandler"})
-			return
-		}
-
 		publicKeys := publicKeyHandler.GetPublicKeys()
 		c.JSON(http.StatusOK, gin.H{
 			"success":    true,
@@ -86,3 +39,59 @@ func (api *API) GetPublicKeysHandler() gin.HandlerFunc {
 		})
 	}
 }
+
+// publishPublicKey signs the peer ID of the node and publishes it with the public key
+// of the node.
+func (api *API) publishPublicKey() (*pubsub.PublicKeyMessage, error) {
+	if api.Node == nil || api.Node.PubSubManager == nil {
+		return nil, errUnavailable("pubsub")
+	}
+
+	keyManager := api.Node.KeyManager
+
+	// Set the data to be signed as the signer's Peer ID
+	data := []byte(api.Node.Host.ID().String())
+
+	// Sign the data using the private key
+	signature, err := consensus.SignData(keyManager.Libp2pPrivKey, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign data: %w", err)
+	}
+
+	// Serialize the public key message
+	msg := pubsub.PublicKeyMessage{
+		PublicKey: keyManager.HexPubKey,
+		Signature: hex.EncodeToString(signature),
+		Data:      string(data),
+	}
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key message: %w", err)
+	}
+
+	// Publish the public key using its string representation, data, and signature
+	publicKeyTopic := config.TopicWithVersion(config.PublicKeyTopic)
+	if err := api.Node.PubSubManager.Publish(publicKeyTopic, msgBytes); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// publicKeyHandler returns the handler collecting the public keys of the nodes.
+func (api *API) publicKeyHandler() (*pubsub.PublicKeySubscriptionHandler, error) {
+	if api.Node == nil || api.Node.PubSubManager == nil {
+		return nil, errUnavailable("pubsub")
+	}
+
+	// Use the PublicKeyTopic constant from the masa package
+	handler, err := api.Node.PubSubManager.GetHandler(string(config.ProtocolWithVersion(config.PublicKeyTopic)))
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyHandler, ok := handler.(*pubsub.PublicKeySubscriptionHandler)
+	if !ok {
+		return nil, errors.New("handler is not of type PublicKeySubscriptionHandler")
+	}
+	return publicKeyHandler, nil
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/masa-finance/masa-oracle/pkg/db"
+)
+
+// QueryDHTHandler pages through the node's local copy of the shared data.
+// Query parameters: prefix, start, end, cursor, offset, limit and includeDeleted.
+func (api *API) QueryDHTHandler() gin.HandlerFunc {
+	return api.cacheQueryHandler(false)
+}
+
+// ListDHTKeysHandler pages through the keys of the node's local copy of the shared
+// data. It accepts the same parameters as QueryDHTHandler.
+func (api *API) ListDHTKeysHandler() gin.HandlerFunc {
+	return api.cacheQueryHandler(true)
+}
+
+func (api *API) cacheQueryHandler(keysOnly bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := parseCacheQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		q.KeysOnly = keysOnly
+		page, err := api.DB.Query(c.Request.Context(), q)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+
+		data := make([]gin.H, len(page.Entries))
+		for i, entry := range page.Entries {
+			item := gin.H{
+				"key":     entry.Key,
+				"version": entry.Version,
+			}
+			if !keysOnly {
+				item["value"] = renderValue(entry.Value)
+			}
+			if entry.Expires != 0 {
+				item["expires"] = time.Unix(0, entry.Expires).UTC()
+			}
+			if entry.Private {
+				item["private"] = true
+			}
+			if q.IncludeDeleted {
+				item["deleted"] = entry.Deleted
+			}
+			data[i] = item
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":    true,
+			"data":       data,
+			"nextCursor": page.NextCursor,
+		})
+	}
+}
+
+// CountDHTHandler counts the entries of the node's local copy of the shared data
+// matching the prefix, start, end and includeDeleted parameters.
+func (api *API) CountDHTHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := parseCacheQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		count, err := api.DB.Count(c.Request.Context(), q)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":    true,
+			"totalCount": count,
+		})
+	}
+}
+
+func parseCacheQuery(c *gin.Context) (db.Query, error) {
+	q := db.Query{
+		Prefix: c.Query("prefix"),
+		Start:  c.Query("start"),
+		End:    c.Query("end"),
+		Cursor: c.Query("cursor"),
+	}
+	var err error
+	if _, ok := c.GetQuery("offset"); ok {
+		if q.Offset, err = GetPathInt(c, "offset"); err != nil {
+			return q, err
+		}
+	}
+	if _, ok := c.GetQuery("limit"); ok {
+		if q.Limit, err = GetPathInt(c, "limit"); err != nil {
+			return q, err
+		}
+	}
+	if val, ok := c.GetQuery("includeDeleted"); ok {
+		if q.IncludeDeleted, err = strconv.ParseBool(val); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// renderValue embeds JSON values as they are and returns other values as strings.
+func renderValue(value []byte) interface{} {
+	if json.Valid(value) {
+		return json.RawMessage(value)
+	}
+	return string(value)
+}
+
+// GetRepublishStatsHandler reports the progress and lag of the DHT republisher.
+func (api *API) GetRepublishStatsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    newRepublishStatus(api.DB.RepublishStats()),
+		})
+	}
+}
+
+// GetKeyVersionsHandler lists the recent versions of a key known to the node, newest
+// first, next to the current version in the DHT. Versions are ordered by their hybrid
+// logical clock timestamp and writer, the last writer wins.
+func (api *API) GetKeyVersionsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Query("key")
+		if len(key) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "missing key param",
+			})
+			return
+		}
+		data, err := api.keyVersions(c, key)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    data,
+		})
+	}
+}
+
+func (api *API) keyVersions(c *gin.Context, key string) (*KeyVersions, error) {
+	versions, err := api.DB.KeyVersions(c.Request.Context(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &KeyVersions{Key: key, Versions: make([]Version, len(versions))}
+	current, err := api.DB.LookupRecord(c.Request.Context(), key)
+	if err == nil {
+		stored := newVersion(current)
+		data.Network = &stored
+	}
+	for i, version := range versions {
+		data.Versions[i] = newVersion(version)
+		if current != nil {
+			isCurrent := version.Compare(current) == 0
+			data.Versions[i].Current = &isCurrent
+		}
+	}
+	// the newest version known locally is the one the DHT settled on
+	data.InSync = current != nil && len(versions) > 0 && versions[0].Compare(current) == 0
+	return data, nil
+}
+
+// GetConflictStatsHandler reports how often concurrent writes of a key conflicted.
+func (api *API) GetConflictStatsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats := api.DB.ConflictStats()
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data": gin.H{
+				"staleWrites": stats.StaleWrites,
+				"adopted":     stats.Adopted,
+				"selections":  stats.Selections,
+			},
+		})
+	}
+}
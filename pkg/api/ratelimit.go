@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"github.com/masa-finance/masa-oracle/pkg/auth"
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+)
+
+// The route groups share a rate limit and a daily quota per client. Publish holds the
+// routes that send messages to the gossip network, which every node relays.
+const (
+	GroupRead    = "read"
+	GroupWrite   = "write"
+	GroupPublish = "publish"
+)
+
+// maxRateLimitClients is the number of token buckets kept before the full ones, which
+// behave like new buckets, are dropped.
+const maxRateLimitClients = 10000
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// DefaultRateLimits are the limits of the route groups not configured in the
+// rateLimits section of the config file.
+var DefaultRateLimits = map[string]config.RateLimit{
+	GroupRead:    {Rate: 20, Burst: 50},
+	GroupWrite:   {Rate: 5, Burst: 20, Daily: 20000},
+	GroupPublish: {Rate: 0.5, Burst: 5, Daily: 1000},
+}
+
+// RateLimiter limits the requests of every client to a route group with a token bucket,
+// and counts them against the daily quota of the group.
+type RateLimiter struct {
+	limits map[string]config.RateLimit
+	// quotas keeps the daily usage, without it only the rates are limited
+	quotas *db.ResolverCache
+	// clock refills the buckets
+	clock clock.Clock
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*rate.Limiter
+}
+
+type bucketKey struct {
+	group  string
+	client string
+}
+
+// NewRateLimiter creates a limiter with the default limits replaced by the configured
+// ones, refilling its buckets by the node clock clk. The daily quotas are counted in the
+// quotas cache, they are not enforced when it is nil.
+func NewRateLimiter(limits map[string]config.RateLimit, quotas *db.ResolverCache, clk clock.Clock) *RateLimiter {
+	l := &RateLimiter{
+		limits:  make(map[string]config.RateLimit),
+		quotas:  quotas,
+		clock:   clk,
+		buckets: make(map[bucketKey]*rate.Limiter),
+	}
+	for group, limit := range DefaultRateLimits {
+		l.limits[group] = limit
+	}
+	for group, limit := range limits {
+		if _, ok := DefaultRateLimits[group]; !ok {
+			logrus.Warnf("Ignoring the rate limit of unknown route group %s", group)
+			continue
+		}
+		l.limits[group] = limit
+	}
+	return l
+}
+
+// Allow takes a request of the client to the group from its bucket and its quota. When
+// the client is over either limit it returns ErrRateLimited or db.ErrQuotaExceeded and
+// how long the client should wait.
+func (l *RateLimiter) Allow(ctx context.Context, group, client string) (time.Duration, error) {
+	limit, ok := l.limits[group]
+	if !ok {
+		return 0, nil
+	}
+	now := l.clock.Now()
+	if bucket := l.bucket(group, client, limit); bucket != nil {
+		reservation := bucket.ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			return delay, ErrRateLimited
+		}
+	}
+	if l.quotas == nil {
+		return 0, nil
+	}
+	if err := l.quotas.UseQuota(ctx, group, client, limit.Daily); err != nil {
+		if errors.Is(err, db.ErrQuotaExceeded) {
+			midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+			return midnight.Sub(now), err
+		}
+		// quotas are not enforced while the cache fails
+		logrus.Errorf("Failed to count the %s quota of %s: %v", group, client, err)
+	}
+	return 0, nil
+}
+
+func (l *RateLimiter) bucket(group, client string, limit config.RateLimit) *rate.Limiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	key := bucketKey{group: group, client: client}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitClients {
+			l.prune()
+		}
+		burst := limit.Burst
+		if burst < 1 {
+			burst = int(math.Ceil(limit.Rate))
+		}
+		bucket = rate.NewLimiter(rate.Limit(limit.Rate), burst)
+		l.buckets[key] = bucket
+	}
+	return bucket
+}
+
+// prune drops the buckets that refilled completely.
+func (l *RateLimiter) prune() {
+	now := l.clock.Now()
+	for key, bucket := range l.buckets {
+		if bucket.TokensAt(now) >= float64(bucket.Burst()) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimit applies the limits of the route groups to the unversioned routes, which
+// share the groups of the routes replacing them.
+func (api *API) RateLimit(endpoints []endpoint) gin.HandlerFunc {
+	groups := map[string]string{}
+	for _, e := range endpoints {
+		if e.Legacy != "" {
+			groups[e.Method+" "+e.Legacy] = e.group()
+		}
+	}
+	return func(c *gin.Context) {
+		if group, ok := groups[c.Request.Method+" "+c.FullPath()]; ok {
+			if err := api.limit(c, group); err != nil {
+				c.AbortWithStatusJSON(err.Status, gin.H{
+					"success": false,
+					"message": err.Message,
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// limitV1 is RateLimit answering with the error envelope of the versioned API.
+func (api *API) limitV1(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := api.limit(c, group); err != nil {
+			writeV1Error(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// limit counts the request against the limits of its client, which is identified by
+// its credentials or, without them, by its IP address.
+func (api *API) limit(c *gin.Context, group string) *APIError {
+	client := "ip:" + c.ClientIP()
+	if principal, ok := c.Get(principalKey); ok {
+		client = principal.(*auth.Principal).Name
+	}
+	seconds, err := api.allow(c.Request.Context(), group, client)
+	if err != nil {
+		c.Header("Retry-After", strconv.Itoa(seconds))
+	}
+	return err
+}
+
+// allow counts a request of the client against the limits of the group. A rejected
+// request gets the number of seconds the client should wait.
+func (api *API) allow(ctx context.Context, group, client string) (int, *APIError) {
+	if api.Limiter == nil {
+		return 0, nil
+	}
+	wait, err := api.Limiter.Allow(ctx, group, client)
+	if err == nil {
+		return 0, nil
+	}
+	seconds := int(math.Ceil(wait.Seconds()))
+	return seconds, newAPIError(http.StatusTooManyRequests, "%s, retry in %d seconds", err.Error(), seconds)
+}
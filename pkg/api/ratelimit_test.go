@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/storage"
+)
+
+func TestRateLimiter(t *testing.T) {
+	mock := clock.NewMock()
+	mock.Set(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	store, err := storage.Open(storage.BackendMemory, "")
+	require.NoError(t, err)
+	quotas := db.NewResolverCache(store, mock)
+	ctx := context.Background()
+
+	limiter := NewRateLimiter(map[string]config.RateLimit{GroupPublish: {Rate: 1, Burst: 2, Daily: 3}}, quotas, mock)
+	for i := 0; i < 2; i++ {
+		_, err = limiter.Allow(ctx, GroupPublish, "a")
+		require.NoError(t, err)
+	}
+	wait, err := limiter.Allow(ctx, GroupPublish, "a")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, time.Second, wait)
+	// clients and groups have their own buckets
+	_, err = limiter.Allow(ctx, GroupPublish, "b")
+	assert.NoError(t, err)
+	_, err = limiter.Allow(ctx, GroupRead, "a")
+	assert.NoError(t, err)
+
+	mock.Add(time.Second)
+	_, err = limiter.Allow(ctx, GroupPublish, "a")
+	require.NoError(t, err)
+	mock.Add(time.Second)
+	wait, err = limiter.Allow(ctx, GroupPublish, "a")
+	assert.ErrorIs(t, err, db.ErrQuotaExceeded)
+	assert.Equal(t, 12*time.Hour-2*time.Second, wait)
+
+	// quotas are kept in the cache and restart every day
+	limiter = NewRateLimiter(map[string]config.RateLimit{GroupPublish: {Rate: 1, Burst: 2, Daily: 3}}, quotas, mock)
+	_, err = limiter.Allow(ctx, GroupPublish, "a")
+	assert.ErrorIs(t, err, db.ErrQuotaExceeded)
+	mock.Add(12 * time.Hour)
+	_, err = limiter.Allow(ctx, GroupPublish, "a")
+	assert.NoError(t, err)
+}
+
+func TestRateLimitResponses(t *testing.T) {
+	router := newTestRouter(newTestAPI(t, nil))
+	for i := 0; i < DefaultRateLimits[GroupRead].Burst; i++ {
+		require.Equal(t, http.StatusOK, serve(router, http.MethodGet, V1Prefix+"/ads", "reader").Code)
+	}
+	w := serve(router, http.MethodGet, V1Prefix+"/ads", "reader")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"too_many_requests"`)
+}
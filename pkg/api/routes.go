@@ -2,9 +2,14 @@ package api
 
 import (
 	"embed"
+	"html/template"
+
 	"github.com/gin-gonic/gin"
+
 	masa "github.com/masa-finance/masa-oracle/pkg"
-	"html/template"
+	"github.com/masa-finance/masa-oracle/pkg/auth"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/health"
 )
 
 // Before:
@@ -14,33 +19,71 @@ import (
 //go:embed templates/*.html
 var htmlTemplates embed.FS
 
-func SetupRoutes(node *masa.OracleNode) *gin.Engine {
+// SetupRoutes registers the API routes. Every route requires credentials with the
+// read, write or admin scope, except the status page, the health probes and the
+// OpenAPI document. The unversioned routes are deprecated aliases of the /api/v1
+// routes. Requests count against the rate limits and daily quotas of their route group,
+// which the limiter shares with the gRPC server.
+func SetupRoutes(node *masa.OracleNode, database *db.Database, limiter *RateLimiter) *gin.Engine {
 	router := gin.Default()
-	// @TODO need to add a Authorization Bearer methodology for api security
 	// add cors middleware
 
-	API := NewAPI(node)
+	API := NewAPI(node, database)
+	API.Auth = API.NewAuthenticator(node.Config)
+	API.Limiter = limiter
+	endpoints := API.v1Endpoints()
+	router.Use(deprecateLegacy(endpoints))
+	limit := API.RateLimit(endpoints)
+
+	read := router.Group("/", API.Require(auth.ScopeRead), limit)
+	write := router.Group("/", API.Require(auth.ScopeWrite), limit)
+	admin := router.Group("/", API.Require(auth.ScopeAdmin), limit)
 
-	router.GET("/peers", API.GetPeersHandler())
-	router.GET("/peerAddresses", API.GetPeerAddresses())
+	read.GET("/peers", API.GetPeersHandler())
+	read.GET("/peerAddresses", API.GetPeerAddresses())
 
-	router.POST("/ads", API.PostAd())
-	router.GET("/ads", API.GetAds())
-	router.POST("/subscribeToAds", API.SubscribeToAds())
+	read.GET("/diagnostics", API.GetDiagnosticsHandler())
+	read.GET("/diagnostics/routingTable", API.GetRoutingTableHandler())
+	read.GET("/diagnostics/peers", API.GetPeerDiagnosticsHandler())
+	read.GET("/diagnostics/dialFailures", API.GetDialFailuresHandler())
 
-	router.GET("/nodeData", API.GetNodeDataHandler())
-	router.GET("/nodeData/:peerID", API.GetNodeHandler())
+	write.POST("/ads", API.PostAd())
+	read.GET("/ads", API.GetAds())
+	write.POST("/subscribeToAds", API.SubscribeToAds())
 
-	router.GET("/publicKeys", API.GetPublicKeysHandler())
-	router.POST("/publishPublicKey", API.PublishPublicKeyHandler())
+	read.GET("/nodeData", API.GetNodeDataHandler())
+	read.GET("/nodeData/:peerID", API.GetNodeHandler())
 
-	router.POST("/createTopic", API.CreateNewTopicHandler())
-	router.POST("/postToTopic", API.PostToTopicHandler())
+	read.GET("/publicKeys", API.GetPublicKeysHandler())
+	admin.POST("/publishPublicKey", API.PublishPublicKeyHandler())
 
-	router.GET("/dht", API.GetFromDHT())
-	router.POST("/dht", API.PostToDHT())
+	write.POST("/createTopic", API.CreateNewTopicHandler())
+	write.POST("/postToTopic", API.PostToTopicHandler())
 
-	router.POST("/nodestatus", API.PostNodeStatusHandler())
+	read.GET("/dht", API.GetFromDHT())
+	write.POST("/dht", API.PostToDHT())
+	write.DELETE("/dht", API.DeleteFromDHT())
+	read.POST("/dht/batchGet", API.BatchGetHandler())
+	write.POST("/dht/batchPut", API.BatchPutHandler())
+	read.GET("/dht/query", API.QueryDHTHandler())
+	read.GET("/dht/keys", API.ListDHTKeysHandler())
+	read.GET("/dht/count", API.CountDHTHandler())
+	read.GET("/dht/republish", API.GetRepublishStatsHandler())
+	read.GET("/dht/versions", API.GetKeyVersionsHandler())
+	read.GET("/dht/conflicts", API.GetConflictStatsHandler())
+	read.GET("/dht/watch", API.WatchDHTHandler())
+	read.GET("/namespaces", API.GetNamespacesHandler())
+
+	write.POST("/blobs", API.PostBlobHandler())
+	read.GET("/blobs/:cid", API.GetBlobHandler())
+
+	read.GET("/acl", API.GetACLHandler())
+	admin.POST("/acl/grant", API.GrantACLRoleHandler())
+	admin.POST("/acl/revoke", API.RevokeACLRoleHandler())
+
+	write.POST("/nodestatus", API.PostNodeStatusHandler())
+
+	API.setupV1(router, endpoints)
 
 	// Serving node status html
 	templ := template.Must(template.ParseFS(htmlTemplates, "templates/*.html"))
@@ -48,5 +91,9 @@ func SetupRoutes(node *masa.OracleNode) *gin.Engine {
 
 	router.GET("/status", API.NodeStatusPageHandler())
 
+	router.GET("/healthz", API.HealthHandler(health.Liveness))
+	router.GET("/readyz", API.HealthHandler(health.Readiness))
+	router.GET("/startupz", API.HealthHandler(health.Startup))
+
 	return router
 }
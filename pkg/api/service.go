@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/nodestatus"
+	"github.com/masa-finance/masa-oracle/pkg/pubsub"
+)
+
+// The operations served by both the /api/v1 routes and the gRPC service. Failures
+// caused by the request or by a missing node component are returned as *APIError.
+
+func (api *API) listPeers(withAddresses bool) ([]PeerInfo, error) {
+	if api.Node == nil || api.Node.DHT == nil {
+		return nil, errUnavailable("the DHT")
+	}
+	// like the routes they replace, peers lists the routing table and addresses the
+	// connected peers
+	peers := api.Node.DHT.RoutingTable().ListPeers()
+	if withAddresses {
+		peers = api.Node.Host.Network().Peers()
+	}
+	data := make([]PeerInfo, len(peers))
+	for i, p := range peers {
+		data[i] = PeerInfo{PeerID: p.String()}
+		if withAddresses {
+			for _, conn := range api.Node.Host.Network().ConnsToPeer(p) {
+				data[i].Addresses = append(data[i].Addresses, conn.RemoteMultiaddr().String())
+			}
+		}
+	}
+	return data, nil
+}
+
+// listNodes returns a page of the node registry and the number of nodes.
+func (api *API) listNodes(query NodePageQuery) ([]pubsub.NodeData, int, error) {
+	if query.PageNbr < 0 || query.PageSize < 1 {
+		return nil, 0, newAPIError(http.StatusBadRequest, "invalid page")
+	}
+	if api.Node == nil || api.Node.NodeTracker == nil {
+		return nil, 0, errUnavailable("the node registry")
+	}
+	nodes := api.Node.NodeTracker.GetAllNodeData()
+	start := int(math.Min(float64(query.PageNbr*query.PageSize), float64(len(nodes))))
+	end := int(math.Min(float64(start+query.PageSize), float64(len(nodes))))
+	return nodes[start:end], len(nodes), nil
+}
+
+// getNode returns a node of the registry with its uptimes up to date.
+func (api *API) getNode(peerID string) (pubsub.NodeData, error) {
+	if api.Node == nil || api.Node.NodeTracker == nil {
+		return pubsub.NodeData{}, errUnavailable("the node registry")
+	}
+	nodeData := api.Node.NodeTracker.GetNodeData(peerID)
+	if nodeData == nil {
+		return pubsub.NodeData{}, newAPIError(http.StatusNotFound, "Node not found")
+	}
+	nd := *nodeData
+	now := api.Node.NodeTracker.Clock.Now()
+	nd.CurrentUptime = nodeData.GetCurrentUptime(now)
+	nd.AccumulatedUptime = nodeData.GetAccumulatedUptime(now)
+	nd.CurrentUptimeStr = pubsub.PrettyDuration(nd.CurrentUptime)
+	nd.AccumulatedUptimeStr = pubsub.PrettyDuration(nd.AccumulatedUptime)
+	return nd, nil
+}
+
+// readRecord reads the current version of a key and opens its value. When the node may
+// not read a private value the record is returned with the error.
+func (api *API) readRecord(key string) (*network.DBRecord, []byte, error) {
+	record, err := api.DB.ReadRecord(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := api.DB.OpenRecord(record)
+	return record, value, err
+}
+
+func (api *API) putRecord(ctx context.Context, request PutRequest) (WriteResult, error) {
+	put, err := toBatchPut(request)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	record, err := api.DB.WriteValue(ctx, put)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	return WriteResult{Key: request.Key, Version: record.Seq}, nil
+}
+
+// watch subscribes to the updates of the keys starting with prefix, the watch must be
+// closed by the caller.
+func (api *API) watch(prefix string) (*network.DBWatch, error) {
+	if api.Node == nil || api.Node.DataUpdates == nil {
+		return nil, errUnavailable("data updates")
+	}
+	return api.Node.DataUpdates.Watch(prefix), nil
+}
+
+func (api *API) createTopic(name string) error {
+	if api.Node == nil || api.Node.PubSubManager == nil {
+		return errUnavailable("pubsub")
+	}
+	return api.Node.PubSubManager.AddSubscription(config.TopicWithVersion(name), pubsub.NewTopicHandler(api.Node.Clock))
+}
+
+func (api *API) publishMessage(name, message string) error {
+	if api.Node == nil || api.Node.PubSubManager == nil {
+		return errUnavailable("pubsub")
+	}
+	return api.Node.PubSubManager.PublishMessage(config.TopicWithVersion(name), message)
+}
+
+// streamTopic opens a stream of the messages of a subscribed topic, the stream must be
+// closed by the caller.
+func (api *API) streamTopic(name string) (*pubsub.TopicStream, error) {
+	handler, err := api.topicHandler(name)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := handler.Stream()
+	if err != nil {
+		return nil, newAPIError(http.StatusTooManyRequests, "%s", err.Error())
+	}
+	return stream, nil
+}
+
+func (api *API) publishNodeStatus(status nodestatus.NodeStatus) error {
+	if api.Node == nil || api.Node.PubSubManager == nil {
+		return errUnavailable("pubsub")
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return api.Node.PubSubManager.Publish(config.TopicWithVersion(config.NodeStatusTopic), data)
+}
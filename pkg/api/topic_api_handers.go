@@ -26,7 +26,7 @@ func (api *API) CreateNewTopicHandler() gin.HandlerFunc {
 		}
 
 		// Initialize a TopicHandler for managing messages from the new topic.
-		topicHandler := pubsub.NewTopicHandler()
+		topicHandler := pubsub.NewTopicHandler(api.Node.Clock)
 
 		// Use the AddSubscription method to create the new topic and subscribe the TopicHandler to it.
 		if err := api.Node.PubSubManager.AddSubscription(config.TopicWithVersion(request.TopicName), topicHandler); err != nil {
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/pubsub"
+)
+
+// topicWriteTimeout bounds the write of a message to a WebSocket client.
+const topicWriteTimeout = time.Second * 10
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// v1TopicStream delivers the messages of a subscribed topic as they arrive, over a
+// WebSocket when the client asks for an upgrade and as server-sent events otherwise.
+// Every client has its own buffer, clients that fall behind are disconnected. Messages
+// published by this node are not delivered.
+func (api *API) v1TopicStream(c *gin.Context) {
+	name := c.Param("name")
+	stream, err := api.streamTopic(name)
+	if err != nil {
+		writeV1Error(c, toAPIError(err))
+		return
+	}
+	defer stream.Close()
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		streamTopicWebSocket(c, name, stream)
+		return
+	}
+	streamTopicEvents(c, name, stream)
+}
+
+// topicHandler returns the handler of a topic created with POST /topics.
+func (api *API) topicHandler(name string) (*pubsub.TopicHandler, error) {
+	if api.Node == nil || api.Node.PubSubManager == nil {
+		return nil, errUnavailable("pubsub")
+	}
+	handler, err := api.Node.PubSubManager.GetHandler(config.TopicWithVersion(name))
+	if err != nil {
+		return nil, newAPIError(http.StatusNotFound, "the node is not subscribed to topic %s", name)
+	}
+	topicHandler, ok := handler.(*pubsub.TopicHandler)
+	if !ok {
+		return nil, newAPIError(http.StatusBadRequest, "topic %s cannot be streamed", name)
+	}
+	return topicHandler, nil
+}
+
+func streamTopicEvents(c *gin.Context, name string, stream *pubsub.TopicStream) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case message, ok := <-stream.Messages():
+			if !ok {
+				if err := stream.Err(); err != nil {
+					c.SSEvent("disconnected", gin.H{"reason": err.Error()})
+				}
+				return false
+			}
+			c.SSEvent("message", newTopicMessage(name, message))
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func streamTopicWebSocket(c *gin.Context, name string, stream *pubsub.TopicStream) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader answered the client already
+		logrus.Debugf("Failed to upgrade the stream of topic %s: %v", name, err)
+		return
+	}
+	defer conn.Close()
+
+	// the stream is one way, reading only handles the control frames and notices when
+	// the client goes away
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case message, ok := <-stream.Messages():
+			if !ok {
+				closeWebSocket(conn, stream.Err())
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(topicWriteTimeout))
+			if err := conn.WriteJSON(newTopicMessage(name, message)); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(topicWriteTimeout)); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// closeWebSocket tells the client why the stream ended.
+func closeWebSocket(conn *websocket.Conn, err error) {
+	code, reason := websocket.CloseNormalClosure, ""
+	if errors.Is(err, pubsub.ErrSlowConsumer) {
+		code, reason = websocket.ClosePolicyViolation, err.Error()
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(topicWriteTimeout))
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/masa-finance/masa-oracle/pkg/auth"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
+	"github.com/masa-finance/masa-oracle/pkg/namespace"
+)
+
+// V1Prefix is the path prefix of the versioned API.
+const V1Prefix = "/api/v1"
+
+// Envelope is the body of every /api/v1 response. Successful responses carry data,
+// failed ones an error.
+type Envelope struct {
+	Success bool      `json:"success"`
+	Data    any       `json:"data,omitempty"`
+	Meta    *Meta     `json:"meta,omitempty"`
+	Error   *APIError `json:"error,omitempty"`
+}
+
+// Meta describes the page of a list response.
+type Meta struct {
+	Total      int    `json:"total,omitempty" doc:"Number of items across all pages"`
+	Failed     int    `json:"failed,omitempty" doc:"Number of failed items of a batch"`
+	NextCursor string `json:"nextCursor,omitempty" doc:"Cursor of the next page, empty on the last page"`
+}
+
+// APIError is the error of a failed /api/v1 request. Code is a stable identifier
+// derived from the HTTP status, Details holds structured information such as the
+// failed constraints of a schema violation.
+type APIError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusRequestEntityTooLarge: "too_large",
+	http.StatusUnprocessableEntity:   "invalid_value",
+	http.StatusPreconditionRequired:  "precondition_required",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusServiceUnavailable:    "unavailable",
+	http.StatusGatewayTimeout:        "timeout",
+}
+
+// newAPIError creates the error of a failed request with the given status.
+func newAPIError(status int, message string, args ...any) *APIError {
+	code, ok := errorCodes[status]
+	if !ok {
+		code = "internal"
+	}
+	return &APIError{Status: status, Code: code, Message: fmt.Sprintf(message, args...)}
+}
+
+// errUnavailable is returned by handlers whose node component is not running.
+func errUnavailable(component string) *APIError {
+	return newAPIError(http.StatusServiceUnavailable, "%s is not available", component)
+}
+
+// toAPIError maps the errors of the node to API errors.
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	apiErr = newAPIError(errorStatus(err), "%s", err.Error())
+	var schemaErr *namespace.SchemaError
+	if errors.As(err, &schemaErr) {
+		apiErr.Details = gin.H{"namespace": schemaErr.Namespace, "violations": schemaErr.Details}
+	}
+	return apiErr
+}
+
+// errorStatus maps the errors of the database to the HTTP status of the request.
+func errorStatus(err error) int {
+	var schemaErr *namespace.SchemaError
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, db.ErrRecordNotFound), errors.Is(err, db.ErrBlobNotFound):
+		return http.StatusNotFound
+	case db.IsTimeout(err):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &schemaErr):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, db.ErrUnauthorized), errors.Is(err, db.ErrAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, namespace.ErrUnknownNamespace), errors.Is(err, db.ErrInvalidReader),
+		errors.Is(err, masacrypto.ErrUnsupportedKey), errors.Is(err, db.ErrBatchTooLarge):
+		return http.StatusBadRequest
+	case errors.Is(err, db.ErrBlobTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Page is returned by handlers of list endpoints to add the page metadata.
+type Page struct {
+	Items any
+	Meta  Meta
+}
+
+// v1Handler serves a request of the versioned API. It returns the data of the
+// response or an error, which is mapped with toAPIError.
+type v1Handler func(c *gin.Context) (any, error)
+
+func (h v1Handler) serve(c *gin.Context) {
+	data, err := h(c)
+	if err != nil {
+		writeV1Error(c, toAPIError(err))
+		return
+	}
+	if page, ok := data.(Page); ok {
+		c.JSON(http.StatusOK, Envelope{Success: true, Data: page.Items, Meta: &page.Meta})
+		return
+	}
+	c.JSON(http.StatusOK, Envelope{Success: true, Data: data})
+}
+
+func writeV1Error(c *gin.Context, err *APIError) {
+	c.AbortWithStatusJSON(err.Status, Envelope{Error: err})
+}
+
+// bindV1 decodes the JSON body of a request into v and reports malformed bodies.
+func bindV1(c *gin.Context, v any) error {
+	if err := c.ShouldBindJSON(v); err != nil {
+		return newAPIError(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+// bindV1Query decodes the query parameters of a request into v.
+func bindV1Query(c *gin.Context, v any) error {
+	if err := c.ShouldBindQuery(v); err != nil {
+		return newAPIError(http.StatusBadRequest, "invalid query: %v", err)
+	}
+	return nil
+}
+
+// endpoint is a route of the versioned API. The route table drives both the router
+// and the OpenAPI document.
+type endpoint struct {
+	Method  string
+	Path    string
+	Scope   auth.Scope
+	Summary string
+	// Group is the route group whose rate limits apply, by default read for endpoints
+	// of the read scope and write for the others.
+	Group string
+	// Query and Body are zero values of the query parameters and the request body.
+	Query any
+	Body  any
+	// Data is a zero value of the data of a successful response, List marks responses
+	// holding a list of it with page metadata.
+	Data any
+	List bool
+	// Produces and Consumes are the content types of endpoints that do not exchange
+	// JSON, their Raw handler writes the response itself.
+	Produces string
+	Consumes string
+	Handler  v1Handler
+	Raw      gin.HandlerFunc
+	// Legacy is the unversioned path the endpoint replaces.
+	Legacy string
+}
+
+func (e endpoint) group() string {
+	switch {
+	case e.Group != "":
+		return e.Group
+	case e.Scope == auth.ScopeRead:
+		return GroupRead
+	default:
+		return GroupWrite
+	}
+}
+
+// setupV1 registers the versioned API on the router.
+func (api *API) setupV1(router *gin.Engine, endpoints []endpoint) {
+	spec := newOpenAPI(endpoints)
+	group := router.Group(V1Prefix)
+	group.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, spec)
+	})
+	for _, e := range endpoints {
+		handler := e.Raw
+		if handler == nil {
+			handler = e.Handler.serve
+		}
+		group.Handle(e.Method, e.Path, api.requireV1(e.Scope), api.limitV1(e.group()), handler)
+	}
+}
+
+// requireV1 is Require answering with the error envelope of the versioned API.
+func (api *API) requireV1(scope auth.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := api.authorize(c, scope); err != nil {
+			writeV1Error(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// deprecateLegacy marks the unversioned routes replaced by an endpoint of the versioned
+// API with the Deprecation and Link headers of RFC 8594. The routes keep working.
+func deprecateLegacy(endpoints []endpoint) gin.HandlerFunc {
+	successors := map[string]string{}
+	for _, e := range endpoints {
+		if e.Legacy != "" {
+			successors[e.Method+" "+e.Legacy] = V1Prefix + e.Path
+		}
+	}
+	return func(c *gin.Context) {
+		successor, ok := successors[c.Request.Method+" "+c.FullPath()]
+		if ok {
+			for _, param := range c.Params {
+				successor = strings.Replace(successor, ":"+param.Key, param.Value, 1)
+			}
+			c.Header("Deprecation", "true")
+			c.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,510 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/masa-finance/masa-oracle/pkg/acl"
+	"github.com/masa-finance/masa-oracle/pkg/ad"
+	"github.com/masa-finance/masa-oracle/pkg/auth"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/namespace"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/nodestatus"
+	"github.com/masa-finance/masa-oracle/pkg/pubsub"
+)
+
+// v1Endpoints lists the routes of the versioned API.
+func (api *API) v1Endpoints() []endpoint {
+	return []endpoint{
+		{Method: http.MethodGet, Path: "/peers", Scope: auth.ScopeRead, Summary: "List the connected peers",
+			Data: PeerInfo{}, List: true, Handler: api.v1Peers(false), Legacy: "/peers"},
+		{Method: http.MethodGet, Path: "/peers/addresses", Scope: auth.ScopeRead, Summary: "List the connected peers with their addresses",
+			Data: PeerInfo{}, List: true, Handler: api.v1Peers(true), Legacy: "/peerAddresses"},
+
+		{Method: http.MethodGet, Path: "/diagnostics", Scope: auth.ScopeRead, Summary: "Summarize the connectivity of the node",
+			Data: Diagnostics{}, Handler: api.v1Diagnostics, Legacy: "/diagnostics"},
+		{Method: http.MethodGet, Path: "/diagnostics/routingTable", Scope: auth.ScopeRead, Summary: "List the DHT routing table per bucket",
+			Data: network.RoutingTableBucket{}, List: true, Handler: api.v1RoutingTable, Legacy: "/diagnostics/routingTable"},
+		{Method: http.MethodGet, Path: "/diagnostics/peers", Scope: auth.ScopeRead, Summary: "Describe the connected peers",
+			Data: network.PeerDiagnostics{}, List: true, Handler: api.v1PeerDiagnostics, Legacy: "/diagnostics/peers"},
+		{Method: http.MethodGet, Path: "/diagnostics/dialFailures", Scope: auth.ScopeRead, Summary: "List the recent failed dials",
+			Data: network.DialFailure{}, List: true, Handler: api.v1DialFailures, Legacy: "/diagnostics/dialFailures"},
+
+		{Method: http.MethodGet, Path: "/ads", Scope: auth.ScopeRead, Summary: "List the collected ads",
+			Data: ad.Ad{}, List: true, Handler: api.v1Ads, Legacy: "/ads"},
+		{Method: http.MethodPost, Path: "/ads", Scope: auth.ScopeWrite, Group: GroupPublish, Summary: "Publish an ad, the node must be staked",
+			Body: ad.Ad{}, Data: Ack{}, Handler: api.v1PostAd, Legacy: "/ads"},
+		{Method: http.MethodPost, Path: "/ads/subscription", Scope: auth.ScopeWrite, Summary: "Collect the ads published on the network",
+			Data: Ack{}, Handler: api.v1SubscribeToAds, Legacy: "/subscribeToAds"},
+
+		{Method: http.MethodGet, Path: "/nodes", Scope: auth.ScopeRead, Summary: "Page through the node registry",
+			Query: NodePageQuery{}, Data: pubsub.NodeData{}, List: true, Handler: api.v1Nodes, Legacy: "/nodeData"},
+		{Method: http.MethodGet, Path: "/nodes/:peerID", Scope: auth.ScopeRead, Summary: "Get a node of the registry",
+			Data: pubsub.NodeData{}, Handler: api.v1Node, Legacy: "/nodeData/:peerID"},
+
+		{Method: http.MethodGet, Path: "/publicKeys", Scope: auth.ScopeRead, Summary: "List the public keys published by the nodes",
+			Data: pubsub.PublicKeyMessage{}, List: true, Handler: api.v1PublicKeys, Legacy: "/publicKeys"},
+		{Method: http.MethodPost, Path: "/publicKeys", Scope: auth.ScopeAdmin, Group: GroupPublish, Summary: "Publish the public key of the node",
+			Data: pubsub.PublicKeyMessage{}, Handler: api.v1PublishPublicKey, Legacy: "/publishPublicKey"},
+
+		{Method: http.MethodPost, Path: "/topics", Scope: auth.ScopeWrite, Summary: "Create and subscribe to a topic",
+			Body: TopicRequest{}, Data: Ack{}, Handler: api.v1CreateTopic, Legacy: "/createTopic"},
+		{Method: http.MethodPost, Path: "/topics/messages", Scope: auth.ScopeWrite, Group: GroupPublish, Summary: "Publish a message on a topic",
+			Body: TopicMessageRequest{}, Data: Ack{}, Handler: api.v1PostToTopic, Legacy: "/postToTopic"},
+		{Method: http.MethodGet, Path: "/topics/:name/stream", Scope: auth.ScopeRead, Summary: "Stream the messages of a topic over a WebSocket or as server-sent events",
+			Data: TopicMessage{}, Produces: "text/event-stream", Raw: api.v1TopicStream},
+
+		{Method: http.MethodGet, Path: "/dht", Scope: auth.ScopeRead, Summary: "Read the current version of a key",
+			Query: KeyQuery{}, Data: Record{}, Handler: api.v1GetRecord, Legacy: "/dht"},
+		{Method: http.MethodPost, Path: "/dht", Scope: auth.ScopeWrite, Summary: "Write a new version of a key",
+			Body: PutRequest{}, Data: WriteResult{}, Handler: api.v1PutRecord, Legacy: "/dht"},
+		{Method: http.MethodDelete, Path: "/dht", Scope: auth.ScopeWrite, Summary: "Delete a key",
+			Query: KeyQuery{}, Data: WriteResult{}, Handler: api.v1DeleteRecord, Legacy: "/dht"},
+		{Method: http.MethodPost, Path: "/dht/batchGet", Scope: auth.ScopeRead, Summary: "Read many keys",
+			Body: BatchGetRequest{}, Data: BatchItem{}, List: true, Handler: api.v1BatchGet, Legacy: "/dht/batchGet"},
+		{Method: http.MethodPost, Path: "/dht/batchPut", Scope: auth.ScopeWrite, Summary: "Write many keys",
+			Body: BatchPutRequest{}, Data: BatchItem{}, List: true, Handler: api.v1BatchPut, Legacy: "/dht/batchPut"},
+		{Method: http.MethodGet, Path: "/dht/query", Scope: auth.ScopeRead, Summary: "Page through the local copy of the shared data",
+			Query: CacheQuery{}, Data: CacheEntry{}, List: true, Handler: api.v1QueryCache(false), Legacy: "/dht/query"},
+		{Method: http.MethodGet, Path: "/dht/keys", Scope: auth.ScopeRead, Summary: "Page through the keys of the local copy of the shared data",
+			Query: CacheQuery{}, Data: CacheEntry{}, List: true, Handler: api.v1QueryCache(true), Legacy: "/dht/keys"},
+		{Method: http.MethodGet, Path: "/dht/count", Scope: auth.ScopeRead, Summary: "Count the entries of the local copy of the shared data",
+			Query: CacheQuery{}, Data: Count{}, Handler: api.v1CountCache, Legacy: "/dht/count"},
+		{Method: http.MethodGet, Path: "/dht/republish", Scope: auth.ScopeRead, Summary: "Report the progress of the republisher",
+			Data: RepublishStatus{}, Handler: api.v1RepublishStatus, Legacy: "/dht/republish"},
+		{Method: http.MethodGet, Path: "/dht/versions", Scope: auth.ScopeRead, Summary: "List the recent versions of a key",
+			Query: KeyQuery{}, Data: KeyVersions{}, Handler: api.v1KeyVersions, Legacy: "/dht/versions"},
+		{Method: http.MethodGet, Path: "/dht/conflicts", Scope: auth.ScopeRead, Summary: "Count the conflicts between concurrent writes",
+			Data: ConflictCounts{}, Handler: api.v1Conflicts, Legacy: "/dht/conflicts"},
+		{Method: http.MethodGet, Path: "/dht/watch", Scope: auth.ScopeRead, Summary: "Stream the new versions of keys as server-sent events",
+			Query: WatchQuery{}, Data: Update{}, Produces: "text/event-stream", Raw: api.v1Watch, Legacy: "/dht/watch"},
+		{Method: http.MethodGet, Path: "/namespaces", Scope: auth.ScopeRead, Summary: "List the namespaces of the database",
+			Data: namespace.Namespace{}, List: true, Handler: api.v1Namespaces, Legacy: "/namespaces"},
+
+		{Method: http.MethodPost, Path: "/blobs", Scope: auth.ScopeWrite, Summary: "Store the request body as a blob",
+			Consumes: "application/octet-stream", Data: Blob{}, Handler: api.v1PostBlob, Legacy: "/blobs"},
+		{Method: http.MethodGet, Path: "/blobs/:cid", Scope: auth.ScopeRead, Summary: "Fetch a blob",
+			Produces: "application/octet-stream", Raw: api.v1GetBlob, Legacy: "/blobs/:cid"},
+
+		{Method: http.MethodGet, Path: "/acl", Scope: auth.ScopeRead, Summary: "List the database ACL",
+			Data: ACL{}, Handler: api.v1ACL, Legacy: "/acl"},
+		{Method: http.MethodPost, Path: "/acl/grant", Scope: auth.ScopeAdmin, Summary: "Grant a role to a peer",
+			Body: ACLChangeRequest{}, Data: acl.Change{}, Handler: api.v1ACLChange(true), Legacy: "/acl/grant"},
+		{Method: http.MethodPost, Path: "/acl/revoke", Scope: auth.ScopeAdmin, Summary: "Revoke the role of a peer",
+			Body: ACLChangeRequest{}, Data: acl.Change{}, Handler: api.v1ACLChange(false), Legacy: "/acl/revoke"},
+
+		{Method: http.MethodPost, Path: "/nodestatus", Scope: auth.ScopeWrite, Group: GroupPublish, Summary: "Publish a node status",
+			Body: nodestatus.NodeStatus{}, Data: Ack{}, Handler: api.v1PostNodeStatus, Legacy: "/nodestatus"},
+	}
+}
+
+func (api *API) v1Peers(withAddresses bool) v1Handler {
+	return func(c *gin.Context) (any, error) {
+		peers, err := api.listPeers(withAddresses)
+		if err != nil {
+			return nil, err
+		}
+		return Page{Items: peers, Meta: Meta{Total: len(peers)}}, nil
+	}
+}
+
+func (api *API) v1Diagnostics(*gin.Context) (any, error) {
+	if api.Node == nil || api.Node.DHT == nil {
+		return nil, errUnavailable("the DHT")
+	}
+	listenAddrs := make([]string, 0)
+	for _, addr := range api.Node.Host.Addrs() {
+		listenAddrs = append(listenAddrs, addr.String())
+	}
+	monitor := api.Node.Connectivity
+	return Diagnostics{
+		PeerID:           api.Node.Host.ID().String(),
+		DHTMode:          network.DHTMode(api.Node.DHT),
+		Reachability:     monitor.Reachability().String(),
+		RoutingTableSize: api.Node.DHT.RoutingTable().Size(),
+		ConnectedPeers:   len(api.Node.Host.Network().Peers()),
+		ListenAddrs:      listenAddrs,
+		DialFailures:     len(monitor.DialFailures()),
+	}, nil
+}
+
+func (api *API) v1RoutingTable(*gin.Context) (any, error) {
+	if api.Node == nil || api.Node.DHT == nil {
+		return nil, errUnavailable("the DHT")
+	}
+	return Page{
+		Items: network.GetRoutingTableBuckets(api.Node.DHT),
+		Meta:  Meta{Total: api.Node.DHT.RoutingTable().Size()},
+	}, nil
+}
+
+func (api *API) v1PeerDiagnostics(*gin.Context) (any, error) {
+	if api.Node == nil || api.Node.Host == nil {
+		return nil, errUnavailable("the node")
+	}
+	peers := network.GetPeerDiagnostics(api.Node.Host, api.Node.DHT)
+	return Page{Items: peers, Meta: Meta{Total: len(peers)}}, nil
+}
+
+func (api *API) v1DialFailures(*gin.Context) (any, error) {
+	if api.Node == nil || api.Node.Host == nil {
+		return nil, errUnavailable("the node")
+	}
+	failures := api.Node.Connectivity.DialFailures()
+	return Page{Items: failures, Meta: Meta{Total: len(failures)}}, nil
+}
+
+func (api *API) v1Ads(*gin.Context) (any, error) {
+	ads := []ad.Ad{}
+	if api.Node != nil && api.Node.AdSubscriptionHandler != nil {
+		ads = api.Node.AdSubscriptionHandler.List()
+	}
+	return Page{Items: ads, Meta: Meta{Total: len(ads)}}, nil
+}
+
+func (api *API) v1PostAd(c *gin.Context) (any, error) {
+	var request ad.Ad
+	if err := bindV1(c, &request); err != nil {
+		return nil, err
+	}
+	if api.Node == nil || api.Node.PubSubManager == nil {
+		return nil, errUnavailable("pubsub")
+	}
+	// Must be staked to publish to ad topic
+	if !api.Node.IsStaked {
+		return nil, newAPIError(http.StatusPreconditionRequired, "node must be staked to be an ad publisher")
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.Node.PubSubManager.Publish(config.TopicWithVersion(config.AdTopic), data); err != nil {
+		return nil, err
+	}
+	return Ack{Message: "Ad published"}, nil
+}
+
+func (api *API) v1SubscribeToAds(*gin.Context) (any, error) {
+	if api.Node == nil || api.Node.PubSubManager == nil {
+		return nil, errUnavailable("pubsub")
+	}
+	// the node keeps the handler, so the ads it collects are listed by GET /ads
+	if api.Node.AdSubscriptionHandler == nil {
+		api.Node.AdSubscriptionHandler = &ad.SubscriptionHandler{}
+	}
+	if err := api.Node.PubSubManager.AddSubscription(config.TopicWithVersion(config.AdTopic), api.Node.AdSubscriptionHandler); err != nil {
+		return nil, err
+	}
+	return Ack{Message: "Subscribed to get ads"}, nil
+}
+
+func (api *API) v1Nodes(c *gin.Context) (any, error) {
+	query := NodePageQuery{PageSize: config.PageSize}
+	if err := bindV1Query(c, &query); err != nil {
+		return nil, err
+	}
+	nodes, total, err := api.listNodes(query)
+	if err != nil {
+		return nil, err
+	}
+	return Page{Items: nodes, Meta: Meta{Total: total}}, nil
+}
+
+func (api *API) v1Node(c *gin.Context) (any, error) {
+	return api.getNode(c.Param("peerID"))
+}
+
+func (api *API) v1PublicKeys(*gin.Context) (any, error) {
+	handler, err := api.publicKeyHandler()
+	if err != nil {
+		return nil, err
+	}
+	keys := handler.GetPublicKeys()
+	if keys == nil {
+		keys = []pubsub.PublicKeyMessage{}
+	}
+	return Page{Items: keys, Meta: Meta{Total: len(keys)}}, nil
+}
+
+func (api *API) v1PublishPublicKey(*gin.Context) (any, error) {
+	return api.publishPublicKey()
+}
+
+func (api *API) v1CreateTopic(c *gin.Context) (any, error) {
+	var request TopicRequest
+	if err := bindV1(c, &request); err != nil {
+		return nil, err
+	}
+	if err := api.createTopic(request.TopicName); err != nil {
+		return nil, err
+	}
+	return Ack{Message: "New topic created and subscribed successfully"}, nil
+}
+
+func (api *API) v1PostToTopic(c *gin.Context) (any, error) {
+	var request TopicMessageRequest
+	if err := bindV1(c, &request); err != nil {
+		return nil, err
+	}
+	if err := api.publishMessage(request.TopicName, request.Message); err != nil {
+		return nil, err
+	}
+	return Ack{Message: "Message posted to topic successfully"}, nil
+}
+
+func (api *API) v1GetRecord(c *gin.Context) (any, error) {
+	var query KeyQuery
+	if err := bindV1Query(c, &query); err != nil {
+		return nil, err
+	}
+	record, value, err := api.readRecord(query.Key)
+	if record == nil {
+		return nil, err
+	}
+	if err != nil {
+		// nodes that may not read a private record get the ciphertext only
+		apiErr := toAPIError(err)
+		apiErr.Details = gin.H{"ciphertext": json.RawMessage(record.Value), "version": record.Seq}
+		return nil, apiErr
+	}
+	return Record{
+		Key:     query.Key,
+		Version: record.Seq,
+		Value:   jsonValue(value),
+		Private: record.Private,
+		Expires: expiresAt(record.Expires),
+	}, nil
+}
+
+func (api *API) v1PutRecord(c *gin.Context) (any, error) {
+	var request PutRequest
+	if err := bindV1(c, &request); err != nil {
+		return nil, err
+	}
+	return api.putRecord(c.Request.Context(), request)
+}
+
+func (api *API) v1DeleteRecord(c *gin.Context) (any, error) {
+	var query KeyQuery
+	if err := bindV1Query(c, &query); err != nil {
+		return nil, err
+	}
+	record, err := api.DB.DeleteRecord(c.Request.Context(), query.Key)
+	if err != nil {
+		return nil, err
+	}
+	return WriteResult{Key: query.Key, Version: record.Seq}, nil
+}
+
+func (api *API) v1BatchGet(c *gin.Context) (any, error) {
+	var request BatchGetRequest
+	if err := bindV1(c, &request); err != nil {
+		return nil, err
+	}
+	items, failed, err := api.batchGet(c, request)
+	if err != nil {
+		return nil, err
+	}
+	return Page{Items: items, Meta: Meta{Total: len(items), Failed: failed}}, nil
+}
+
+func (api *API) v1BatchPut(c *gin.Context) (any, error) {
+	var request BatchPutRequest
+	if err := bindV1(c, &request); err != nil {
+		return nil, err
+	}
+	items, failed, err := api.batchPut(c, request)
+	if err != nil {
+		return nil, err
+	}
+	return Page{Items: items, Meta: Meta{Total: len(items), Failed: failed}}, nil
+}
+
+func (api *API) v1QueryCache(keysOnly bool) v1Handler {
+	return func(c *gin.Context) (any, error) {
+		var query CacheQuery
+		if err := bindV1Query(c, &query); err != nil {
+			return nil, err
+		}
+		q := query.query()
+		q.KeysOnly = keysOnly
+		page, err := api.DB.Query(c.Request.Context(), q)
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "%s", err.Error())
+		}
+		entries := make([]CacheEntry, len(page.Entries))
+		for i, entry := range page.Entries {
+			entries[i] = CacheEntry{
+				Key:     entry.Key,
+				Version: entry.Version,
+				Expires: expiresAt(entry.Expires),
+				Private: entry.Private,
+				Deleted: entry.Deleted,
+			}
+			if !keysOnly {
+				entries[i].Value = jsonValue(entry.Value)
+			}
+		}
+		return Page{Items: entries, Meta: Meta{NextCursor: page.NextCursor}}, nil
+	}
+}
+
+func (api *API) v1CountCache(c *gin.Context) (any, error) {
+	var query CacheQuery
+	if err := bindV1Query(c, &query); err != nil {
+		return nil, err
+	}
+	count, err := api.DB.Count(c.Request.Context(), query.query())
+	if err != nil {
+		return nil, newAPIError(http.StatusBadRequest, "%s", err.Error())
+	}
+	return Count{Count: count}, nil
+}
+
+func (api *API) v1RepublishStatus(*gin.Context) (any, error) {
+	return newRepublishStatus(api.DB.RepublishStats()), nil
+}
+
+func (api *API) v1KeyVersions(c *gin.Context) (any, error) {
+	var query KeyQuery
+	if err := bindV1Query(c, &query); err != nil {
+		return nil, err
+	}
+	return api.keyVersions(c, query.Key)
+}
+
+func (api *API) v1Conflicts(*gin.Context) (any, error) {
+	stats := api.DB.ConflictStats()
+	return ConflictCounts{StaleWrites: stats.StaleWrites, Adopted: stats.Adopted, Selections: stats.Selections}, nil
+}
+
+func (api *API) v1Watch(c *gin.Context) {
+	var query WatchQuery
+	if err := bindV1Query(c, &query); err != nil {
+		writeV1Error(c, toAPIError(err))
+		return
+	}
+	watch, err := api.watch(query.Prefix)
+	if err != nil {
+		writeV1Error(c, toAPIError(err))
+		return
+	}
+	api.streamUpdates(c, watch)
+}
+
+func (api *API) v1Namespaces(*gin.Context) (any, error) {
+	if api.Node == nil || api.Node.Namespaces == nil {
+		return nil, errUnavailable("the namespace registry")
+	}
+	namespaces := api.Node.Namespaces.List()
+	return Page{Items: namespaces, Meta: Meta{Total: len(namespaces)}}, nil
+}
+
+func (api *API) v1PostBlob(c *gin.Context) (any, error) {
+	blobs := api.DB.BlobStore()
+	if blobs == nil {
+		return nil, errUnavailable("the blob store")
+	}
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, db.MaxBlobSize)
+	root, size, err := blobs.Put(c.Request.Context(), body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			err = db.ErrBlobTooLarge
+		}
+		return nil, err
+	}
+	return Blob{CID: root.String(), Size: size}, nil
+}
+
+func (api *API) v1GetBlob(c *gin.Context) {
+	blobs := api.DB.BlobStore()
+	if blobs == nil {
+		writeV1Error(c, errUnavailable("the blob store"))
+		return
+	}
+	root, err := cid.Decode(c.Param("cid"))
+	if err != nil {
+		writeV1Error(c, newAPIError(http.StatusBadRequest, "invalid cid"))
+		return
+	}
+	manifest, err := blobs.Stat(c.Request.Context(), root)
+	if err != nil {
+		writeV1Error(c, toAPIError(err))
+		return
+	}
+	sendBlob(c, blobs, root, manifest.Size)
+}
+
+func (api *API) v1ACL(*gin.Context) (any, error) {
+	if api.Node == nil || api.Node.ACL == nil {
+		return nil, errUnavailable("the ACL")
+	}
+	return ACL{Entries: api.Node.ACL.Entries(), Role: api.Node.ACL.Role(api.Node.Host.ID())}, nil
+}
+
+func (api *API) v1ACLChange(grant bool) v1Handler {
+	return func(c *gin.Context) (any, error) {
+		var request ACLChangeRequest
+		if err := bindV1(c, &request); err != nil {
+			return nil, err
+		}
+		peerID, err := peer.Decode(request.PeerID)
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "invalid peerId")
+		}
+		var change *acl.Change
+		if grant {
+			change, err = db.GrantRole(api.Node, peerID, request.Role)
+		} else {
+			change, err = db.RevokeRole(api.Node, peerID)
+		}
+		if err != nil {
+			if errors.Is(err, acl.ErrNotAdmin) {
+				return nil, newAPIError(http.StatusForbidden, "%s", err.Error())
+			}
+			return nil, newAPIError(http.StatusBadRequest, "%s", err.Error())
+		}
+		return change, nil
+	}
+}
+
+func (api *API) v1PostNodeStatus(c *gin.Context) (any, error) {
+	var status nodestatus.NodeStatus
+	if err := bindV1(c, &status); err != nil {
+		return nil, err
+	}
+	if err := api.publishNodeStatus(status); err != nil {
+		return nil, err
+	}
+	return Ack{Message: "Message posted to topic successfully"}, nil
+}
+
+// toBatchPut converts a write request, with the ttl in seconds.
+func toBatchPut(request PutRequest) (db.BatchPut, error) {
+	if request.Key == "" || len(request.Value) == 0 {
+		return db.BatchPut{}, newAPIError(http.StatusBadRequest, "a write needs a key and a value")
+	}
+	readers, err := decodeReaders(request.Readers)
+	if err != nil {
+		return db.BatchPut{}, err
+	}
+	put := db.BatchPut{
+		Key:     request.Key,
+		Value:   request.Value,
+		Private: request.Private,
+		Readers: readers,
+	}
+	if request.TTL > 0 {
+		put.TTL = time.Duration(request.TTL * float64(time.Second))
+	}
+	return put, nil
+}
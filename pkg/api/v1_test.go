@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/auth"
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+)
+
+// newTestAPI creates an API without a node that accepts the API key "reader".
+// newTestAPI creates an API without a node, its buckets do not refill during the test.
+func newTestAPI(t *testing.T, limits map[string]config.RateLimit) *API {
+	authenticator, err := auth.NewAuthenticator(clock.Wall())
+	require.NoError(t, err)
+	require.NoError(t, authenticator.AddAPIKey("reader"))
+	return &API{Auth: authenticator, Limiter: NewRateLimiter(limits, nil, clock.NewMock())}
+}
+
+func newTestRouter(api *API) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	endpoints := api.v1Endpoints()
+	router.Use(deprecateLegacy(endpoints))
+	router.GET("/nodeData/:peerID", api.Require(auth.ScopeRead), api.RateLimit(endpoints), api.GetNodeHandler())
+	api.setupV1(router, endpoints)
+	return router
+}
+
+func serve(router *gin.Engine, method, target, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader("{}"))
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOpenAPI(t *testing.T) {
+	w := serve(newTestRouter(newTestAPI(t, nil)), http.MethodGet, V1Prefix+"/openapi.json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	for _, e := range (&API{}).v1Endpoints() {
+		assert.Contains(t, spec.Paths[openAPIPath(e.Path)], strings.ToLower(e.Method), e.Path)
+	}
+	assert.Contains(t, spec.Paths, "/nodes/{peerID}")
+
+	// every reference resolves
+	for _, match := range strings.Split(w.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(match, `"`)
+		assert.Contains(t, spec.Components.Schemas, name)
+	}
+	assert.Contains(t, string(spec.Components.Schemas["PutRequest"]), `"required":["key","value"]`)
+}
+
+func TestV1Envelopes(t *testing.T) {
+	router := newTestRouter(newTestAPI(t, nil))
+	decode := func(w *httptest.ResponseRecorder) Envelope {
+		var envelope Envelope
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &envelope))
+		return envelope
+	}
+
+	w := serve(router, http.MethodGet, V1Prefix+"/ads", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	envelope := decode(w)
+	assert.False(t, envelope.Success)
+	require.NotNil(t, envelope.Error)
+	assert.Equal(t, "unauthorized", envelope.Error.Code)
+
+	w = serve(router, http.MethodPost, V1Prefix+"/dht", "reader")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "forbidden", decode(w).Error.Code)
+
+	w = serve(router, http.MethodGet, V1Prefix+"/ads", "reader")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"success":true,"data":[],"meta":{}}`, w.Body.String())
+
+	w = serve(router, http.MethodGet, V1Prefix+"/nodes/abc", "reader")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "unavailable", decode(w).Error.Code)
+
+	// the unversioned routes point to their successor
+	w = serve(router, http.MethodGet, "/nodeData/abc", "reader")
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, `</api/v1/nodes/abc>; rel="successor-version"`, w.Header().Get("Link"))
+	w = serve(router, http.MethodGet, V1Prefix+"/nodes/abc", "reader")
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
@@ -0,0 +1,308 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/masa-finance/masa-oracle/pkg/acl"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/pubsub"
+)
+
+// The request and response types of /api/v1. The OpenAPI document is generated from
+// them, so the description of a field lives in its doc tag.
+
+// Ack is the data of requests that only trigger an action.
+type Ack struct {
+	Message string `json:"message"`
+}
+
+// PeerInfo is a connected peer and the addresses of its connections.
+type PeerInfo struct {
+	PeerID    string   `json:"peerId"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// Diagnostics summarizes the connectivity of the node.
+type Diagnostics struct {
+	PeerID           string   `json:"peerId"`
+	DHTMode          string   `json:"dhtMode"`
+	Reachability     string   `json:"reachability"`
+	RoutingTableSize int      `json:"routingTableSize"`
+	ConnectedPeers   int      `json:"connectedPeers"`
+	ListenAddrs      []string `json:"listenAddrs"`
+	DialFailures     int      `json:"dialFailures"`
+}
+
+// NodePageQuery selects a page of the node registry.
+type NodePageQuery struct {
+	PageNbr  int `form:"pageNbr" doc:"Zero based page number"`
+	PageSize int `form:"pageSize" doc:"Number of nodes per page, 25 by default"`
+}
+
+// TopicRequest names a pubsub topic.
+type TopicRequest struct {
+	TopicName string `json:"topicName" binding:"required"`
+}
+
+// TopicMessageRequest is a message to publish on a topic.
+type TopicMessageRequest struct {
+	TopicName string `json:"topicName" binding:"required"`
+	Message   string `json:"message" binding:"required"`
+}
+
+// TopicMessage is a message received on a topic.
+type TopicMessage struct {
+	Topic    string    `json:"topic"`
+	From     string    `json:"from" doc:"Peer ID of the node that published the message"`
+	Message  string    `json:"message"`
+	Received time.Time `json:"received" doc:"When this node received the message"`
+}
+
+func newTopicMessage(topic string, message pubsub.TopicMessage) TopicMessage {
+	return TopicMessage{
+		Topic:    topic,
+		From:     message.From.String(),
+		Message:  string(message.Data),
+		Received: message.Received.UTC(),
+	}
+}
+
+// KeyQuery names a key of the database, with or without the /db/ prefix.
+type KeyQuery struct {
+	Key string `form:"key" binding:"required" doc:"Key of the database"`
+}
+
+// Record is the current version of a key.
+type Record struct {
+	Key     string          `json:"key"`
+	Version uint64          `json:"version"`
+	Value   json.RawMessage `json:"value,omitempty" doc:"The value, left out of private records the node may not read"`
+	Private bool            `json:"private,omitempty"`
+	Expires *time.Time      `json:"expires,omitempty"`
+}
+
+// PutRequest writes a new version of a key.
+type PutRequest struct {
+	Key     string          `json:"key" binding:"required"`
+	Value   json.RawMessage `json:"value" binding:"required"`
+	TTL     float64         `json:"ttl,omitempty" doc:"Lifetime of the value in seconds, zero keeps it until it is overwritten"`
+	Private bool            `json:"private,omitempty" doc:"Encrypt the value for this node and the readers"`
+	Readers []string        `json:"readers,omitempty" doc:"Peer IDs allowed to read a private value"`
+}
+
+// WriteResult is the version a write created.
+type WriteResult struct {
+	Key     string `json:"key"`
+	Version uint64 `json:"version"`
+}
+
+// BatchGetRequest reads many keys at once.
+type BatchGetRequest struct {
+	Keys    []string `json:"keys" binding:"required"`
+	Timeout float64  `json:"timeout,omitempty" doc:"Deadline of the batch in seconds, capped at 120"`
+}
+
+// BatchPutRequest writes many keys at once.
+type BatchPutRequest struct {
+	Items   []PutRequest `json:"items" binding:"required"`
+	Timeout float64      `json:"timeout,omitempty" doc:"Deadline of the batch in seconds, capped at 120"`
+}
+
+// BatchItem is the result for one key of a batch, with the HTTP status the single key
+// endpoint would have answered.
+type BatchItem struct {
+	Key     string          `json:"key"`
+	Success bool            `json:"success"`
+	Status  int             `json:"status"`
+	Message string          `json:"message,omitempty"`
+	Version uint64          `json:"version,omitempty"`
+	Private bool            `json:"private,omitempty"`
+	Expires *time.Time      `json:"expires,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+}
+
+// CacheQuery selects entries of the node's local copy of the shared data.
+type CacheQuery struct {
+	Prefix         string `form:"prefix" doc:"Only keys starting with the prefix"`
+	Start          string `form:"start" doc:"Only keys from start on"`
+	End            string `form:"end" doc:"Only keys before end"`
+	Cursor         string `form:"cursor" doc:"The nextCursor of the previous page"`
+	Offset         int    `form:"offset"`
+	Limit          int    `form:"limit" doc:"Page size, 25 by default"`
+	IncludeDeleted bool   `form:"includeDeleted"`
+}
+
+func (q CacheQuery) query() db.Query {
+	return db.Query{
+		Prefix:         q.Prefix,
+		Start:          q.Start,
+		End:            q.End,
+		Cursor:         q.Cursor,
+		Offset:         q.Offset,
+		Limit:          q.Limit,
+		IncludeDeleted: q.IncludeDeleted,
+	}
+}
+
+// CacheEntry is an entry of the node's local copy of the shared data.
+type CacheEntry struct {
+	Key     string          `json:"key"`
+	Version uint64          `json:"version"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Expires *time.Time      `json:"expires,omitempty"`
+	Private bool            `json:"private,omitempty"`
+	Deleted bool            `json:"deleted,omitempty"`
+}
+
+// Count is the number of entries matching a query.
+type Count struct {
+	Count int `json:"count"`
+}
+
+// RepublishStatus is the progress and lag of the DHT republisher.
+type RepublishStatus struct {
+	Tracked          int       `json:"tracked"`
+	Pending          int       `json:"pending"`
+	Lag              string    `json:"lag"`
+	Running          bool      `json:"running"`
+	RunTotal         int       `json:"runTotal"`
+	RunDone          int       `json:"runDone"`
+	LastRun          time.Time `json:"lastRun"`
+	LastRunDuration  string    `json:"lastRunDuration"`
+	LastRunPublished int       `json:"lastRunPublished"`
+	LastRunFailed    int       `json:"lastRunFailed"`
+	TotalPublished   uint64    `json:"totalPublished"`
+	TotalFailed      uint64    `json:"totalFailed"`
+}
+
+func newRepublishStatus(stats db.RepublishStats) RepublishStatus {
+	return RepublishStatus{
+		Tracked:          stats.Tracked,
+		Pending:          stats.Pending,
+		Lag:              stats.Lag.String(),
+		Running:          stats.Running,
+		RunTotal:         stats.RunTotal,
+		RunDone:          stats.RunDone,
+		LastRun:          stats.LastRun,
+		LastRunDuration:  stats.LastRunDuration.String(),
+		LastRunPublished: stats.LastRunPublished,
+		LastRunFailed:    stats.LastRunFailed,
+		TotalPublished:   stats.TotalPublished,
+		TotalFailed:      stats.TotalFailed,
+	}
+}
+
+// Version is a version of a key, ordered by its hybrid logical clock timestamp.
+type Version struct {
+	HLC     string     `json:"hlc"`
+	Time    time.Time  `json:"time"`
+	Writer  string     `json:"writer"`
+	Seq     uint64     `json:"seq"`
+	Deleted bool       `json:"deleted"`
+	Private bool       `json:"private,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	Current *bool      `json:"current,omitempty" doc:"Whether the version is the one stored in the DHT"`
+}
+
+// KeyVersions is the history of a key known to the node.
+type KeyVersions struct {
+	Key      string    `json:"key"`
+	Network  *Version  `json:"network,omitempty" doc:"The version stored in the DHT"`
+	Versions []Version `json:"versions"`
+	InSync   bool      `json:"inSync"`
+}
+
+// WatchQuery selects the keys of a watch stream.
+type WatchQuery struct {
+	Prefix string `form:"prefix" doc:"Only keys starting with the prefix"`
+}
+
+// Update is the data of an "update" event of a watch stream. Deleted keys have deleted
+// set and private values are left out.
+type Update struct {
+	Key     string          `json:"key"`
+	Version uint64          `json:"version"`
+	HLC     string          `json:"hlc"`
+	Time    time.Time       `json:"time"`
+	Writer  string          `json:"writer"`
+	Deleted bool            `json:"deleted"`
+	Private bool            `json:"private,omitempty"`
+	Expires *time.Time      `json:"expires,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+}
+
+func newUpdate(update network.DBUpdate) Update {
+	version := newVersion(update.Record)
+	item := Update{
+		Key:     update.Key,
+		Version: update.Record.Seq,
+		HLC:     version.HLC,
+		Time:    version.Time,
+		Writer:  version.Writer,
+		Deleted: version.Deleted,
+		Private: version.Private,
+		Expires: version.Expires,
+	}
+	if !update.Record.Deleted && !update.Record.Private {
+		item.Value = jsonValue(update.Record.Value)
+	}
+	return item
+}
+
+// ConflictCounts counts the conflicts between concurrent writes.
+type ConflictCounts struct {
+	StaleWrites uint64 `json:"staleWrites"`
+	Adopted     uint64 `json:"adopted"`
+	Selections  uint64 `json:"selections"`
+}
+
+// Blob is a stored blob.
+type Blob struct {
+	CID  string `json:"cid"`
+	Size int64  `json:"size"`
+}
+
+// ACL is the database ACL and the role of this node.
+type ACL struct {
+	Entries []acl.Entry `json:"entries"`
+	Role    acl.Role    `json:"role"`
+}
+
+// ACLChangeRequest grants a role to a peer or revokes it.
+type ACLChangeRequest struct {
+	PeerID string   `json:"peerId" binding:"required"`
+	Role   acl.Role `json:"role,omitempty" doc:"writer or admin, ignored when revoking"`
+}
+
+func newVersion(record *network.DBRecord) Version {
+	version := record.Version()
+	return Version{
+		HLC:     version.String(),
+		Time:    version.Time().UTC(),
+		Writer:  record.Writer,
+		Seq:     record.Seq,
+		Deleted: record.Deleted,
+		Private: record.Private,
+		Expires: expiresAt(record.Expires),
+	}
+}
+
+// expiresAt converts the expiry of a record, zero for none.
+func expiresAt(nanos int64) *time.Time {
+	if nanos == 0 {
+		return nil
+	}
+	expires := time.Unix(0, nanos).UTC()
+	return &expires
+}
+
+// jsonValue embeds JSON values as they are and encodes other values as strings.
+func jsonValue(value []byte) json.RawMessage {
+	if json.Valid(value) {
+		return value
+	}
+	encoded, _ := json.Marshal(string(value))
+	return encoded
+}
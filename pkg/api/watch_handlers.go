@@ -0,0 +1,68 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/masa-finance/masa-oracle/pkg/network"
+)
+
+// watchHeartbeat is how often an idle watch stream sends a comment, so proxies keep
+// the connection open and clients notice when it breaks.
+const watchHeartbeat = time.Second * 15
+
+// WatchDHTHandler streams the new versions of the keys starting with the prefix
+// parameter as server-sent events. Every "update" event holds the key, its version and
+// its value; deleted keys have deleted set and private values are left out. A
+// "dropped" event reports how many updates were dropped because the client did not
+// keep up.
+func (api *API) WatchDHTHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		watch, err := api.watch(c.Query("prefix"))
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		api.streamUpdates(c, watch)
+	}
+}
+
+func (api *API) streamUpdates(c *gin.Context, watch *network.DBWatch) {
+	defer watch.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+	var dropped uint64
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case update, ok := <-watch.Updates():
+			if !ok {
+				return false
+			}
+			if n := watch.Dropped(); n > dropped {
+				dropped = n
+				c.SSEvent("dropped", gin.H{"dropped": n})
+			}
+			c.SSEvent("update", newUpdate(update))
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
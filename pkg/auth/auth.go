@@ -0,0 +1,222 @@
+// Package auth authenticates the clients of the node's HTTP API.
+//
+// Clients present either a static API key from the configuration or a short-lived
+// token signed by the node key or by a trusted operator key. Both carry a scope; the
+// scopes are ordered, so a client with the admin scope may also write and read.
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+// MaxTokenTTL is the longest lifetime of a token.
+const MaxTokenTTL = time.Hour * 24
+
+// Scope is the level of access of a client.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrTokenExpired       = errors.New("token expired")
+	ErrUntrustedIssuer    = errors.New("token issuer is not trusted")
+	ErrInvalidScope       = errors.New("invalid scope")
+)
+
+var scopeLevels = map[Scope]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+// ParseScope returns the scope named s.
+func ParseScope(s string) (Scope, error) {
+	scope := Scope(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := scopeLevels[scope]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrInvalidScope, s)
+	}
+	return scope, nil
+}
+
+// Allows reports whether a client with scope s may use a route requiring scope required.
+func (s Scope) Allows(required Scope) bool {
+	return scopeLevels[s] >= scopeLevels[required] && scopeLevels[s] > 0
+}
+
+// Claims is the signed content of a token. Issuer is the hex encoded public key that
+// signed it, in the format of the node's HexPubKey.
+type Claims struct {
+	Issuer   string `json:"iss"`
+	Subject  string `json:"sub,omitempty"`
+	Scope    Scope  `json:"scope"`
+	IssuedAt int64  `json:"iat"`
+	Expires  int64  `json:"exp"`
+}
+
+// NewToken issues a token with the scope for subject at the given time that expires
+// after ttl, signed with privKey. The token is the base64 encoded claims and signature
+// joined by a dot.
+func NewToken(privKey crypto.PrivKey, scope Scope, subject string, issued time.Time, ttl time.Duration) (string, error) {
+	if _, ok := scopeLevels[scope]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+	}
+	if ttl <= 0 || ttl > MaxTokenTTL {
+		return "", fmt.Errorf("the lifetime of a token must be between 0 and %s", MaxTokenTTL)
+	}
+	pubKey, err := crypto.MarshalPublicKey(privKey.GetPublic())
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(Claims{
+		Issuer:   hex.EncodeToString(pubKey),
+		Subject:  subject,
+		Scope:    scope,
+		IssuedAt: issued.Unix(),
+		Expires:  issued.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signature, err := privKey.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Principal is an authenticated client.
+type Principal struct {
+	// Name identifies the client in logs: the subject of a token or a prefix of the
+	// digest of an API key.
+	Name  string
+	Scope Scope
+}
+
+// Authenticator checks API keys and tokens. It is safe for concurrent use once built.
+type Authenticator struct {
+	// apiKeys maps the SHA-256 digest of every key to its scope, so the keys are
+	// not compared byte by byte.
+	apiKeys map[[sha256.Size]byte]Scope
+	issuers map[string]crypto.PubKey
+	clock   clock.Clock
+}
+
+// NewAuthenticator creates an authenticator trusting the tokens signed by the issuers.
+// Token expiry is checked against clk.
+func NewAuthenticator(clk clock.Clock, issuers ...crypto.PubKey) (*Authenticator, error) {
+	a := &Authenticator{
+		clock:   clk,
+		apiKeys: make(map[[sha256.Size]byte]Scope),
+		issuers: make(map[string]crypto.PubKey),
+	}
+	for _, issuer := range issuers {
+		if err := a.TrustIssuer(issuer); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// TrustIssuer accepts the tokens signed by the key.
+func (a *Authenticator) TrustIssuer(pubKey crypto.PubKey) error {
+	raw, err := crypto.MarshalPublicKey(pubKey)
+	if err != nil {
+		return err
+	}
+	a.issuers[hex.EncodeToString(raw)] = pubKey
+	return nil
+}
+
+// TrustIssuerHex accepts the tokens signed by the hex encoded public key.
+func (a *Authenticator) TrustIssuerHex(pubKeyHex string) error {
+	raw, err := hex.DecodeString(strings.TrimSpace(pubKeyHex))
+	if err != nil {
+		return fmt.Errorf("invalid operator key: %w", err)
+	}
+	pubKey, err := crypto.UnmarshalPublicKey(raw)
+	if err != nil {
+		return fmt.Errorf("invalid operator key: %w", err)
+	}
+	return a.TrustIssuer(pubKey)
+}
+
+// AddAPIKey accepts a static API key. The entry is the key followed by its scope
+// after a colon, e.g. "s3cret:write"; keys without a scope may only read.
+func (a *Authenticator) AddAPIKey(entry string) error {
+	key, scopeName, found := strings.Cut(strings.TrimSpace(entry), ":")
+	scope := ScopeRead
+	if found {
+		var err error
+		if scope, err = ParseScope(scopeName); err != nil {
+			return err
+		}
+	}
+	if key == "" {
+		return fmt.Errorf("%w: empty API key", ErrInvalidCredentials)
+	}
+	a.apiKeys[sha256.Sum256([]byte(key))] = scope
+	return nil
+}
+
+// Authenticate identifies the client presenting credential, an API key or a token.
+func (a *Authenticator) Authenticate(credential string) (*Principal, error) {
+	if credential == "" {
+		return nil, ErrMissingCredentials
+	}
+	digest := sha256.Sum256([]byte(credential))
+	if scope, ok := a.apiKeys[digest]; ok {
+		return &Principal{Name: "key:" + hex.EncodeToString(digest[:4]), Scope: scope}, nil
+	}
+	if strings.Contains(credential, ".") {
+		return a.verifyToken(credential)
+	}
+	return nil, ErrInvalidCredentials
+}
+
+func (a *Authenticator) verifyToken(token string) (*Principal, error) {
+	encodedPayload, encodedSignature, _ := strings.Cut(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	issuer, ok := a.issuers[claims.Issuer]
+	if !ok {
+		return nil, ErrUntrustedIssuer
+	}
+	if valid, err := issuer.Verify(payload, signature); err != nil || !valid {
+		return nil, ErrInvalidCredentials
+	}
+	if _, ok := scopeLevels[claims.Scope]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidScope, claims.Scope)
+	}
+	now := a.clock.Now().Unix()
+	if claims.Expires <= now || claims.Expires-claims.IssuedAt > int64(MaxTokenTTL/time.Second) {
+		return nil, ErrTokenExpired
+	}
+	name := claims.Subject
+	if name == "" {
+		name = "token:" + claims.Issuer[len(claims.Issuer)-8:]
+	}
+	return &Principal{Name: name, Scope: claims.Scope}, nil
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+func TestAPIKeys(t *testing.T) {
+	a, err := NewAuthenticator(clock.Wall())
+	require.NoError(t, err)
+	require.NoError(t, a.AddAPIKey("reader"))
+	require.NoError(t, a.AddAPIKey("writer:write"))
+	assert.ErrorIs(t, a.AddAPIKey("other:root"), ErrInvalidScope)
+
+	principal, err := a.Authenticate("reader")
+	require.NoError(t, err)
+	assert.Equal(t, ScopeRead, principal.Scope)
+	principal, err = a.Authenticate("writer")
+	require.NoError(t, err)
+	assert.True(t, principal.Scope.Allows(ScopeRead))
+	assert.True(t, principal.Scope.Allows(ScopeWrite))
+	assert.False(t, principal.Scope.Allows(ScopeAdmin))
+
+	_, err = a.Authenticate("")
+	assert.ErrorIs(t, err, ErrMissingCredentials)
+	_, err = a.Authenticate("writer:write")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestTokens(t *testing.T) {
+	mock := clock.NewMock()
+	node, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	operator, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	stranger, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	a, err := NewAuthenticator(mock, node.GetPublic())
+	require.NoError(t, err)
+	raw, err := crypto.MarshalPublicKey(operator.GetPublic())
+	require.NoError(t, err)
+	require.NoError(t, a.TrustIssuerHex(hex.EncodeToString(raw)))
+
+	token, err := NewToken(operator, ScopeAdmin, "dashboard", mock.Now(), time.Hour)
+	require.NoError(t, err)
+	principal, err := a.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, "dashboard", principal.Name)
+	assert.Equal(t, ScopeAdmin, principal.Scope)
+
+	token, err = NewToken(node, ScopeRead, "", mock.Now(), time.Minute)
+	require.NoError(t, err)
+	principal, err = a.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, ScopeRead, principal.Scope)
+
+	// tokens expire
+	mock.Add(time.Minute)
+	_, err = a.Authenticate(token)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+
+	// tokens of unknown keys, or with claims changed after signing, are rejected
+	token, err = NewToken(stranger, ScopeAdmin, "", mock.Now(), time.Hour)
+	require.NoError(t, err)
+	_, err = a.Authenticate(token)
+	assert.ErrorIs(t, err, ErrUntrustedIssuer)
+	token, err = NewToken(node, ScopeRead, "", mock.Now(), time.Hour)
+	require.NoError(t, err)
+	_, signature, _ := strings.Cut(token, ".")
+	forged, err := NewToken(operator, ScopeAdmin, "", mock.Now(), time.Hour)
+	require.NoError(t, err)
+	payload, _, _ := strings.Cut(forged, ".")
+	_, err = a.Authenticate(payload + "." + signature)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = NewToken(node, ScopeRead, "", mock.Now(), MaxTokenTTL+time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,26 @@
+// Package clock provides the time source used for node lifecycle bookkeeping such as
+// join and leave times, uptimes and buffering windows. Every node is given its own
+// Clock, the wall clock in production; tests pass a mock so they can advance time
+// deterministically.
+package clock
+
+import (
+	"time"
+
+	bclock "github.com/benbjohnson/clock"
+)
+
+// Clock is the time source of a node.
+type Clock = bclock.Clock
+
+// Wall returns the wall clock.
+func Wall() Clock {
+	return bclock.New()
+}
+
+// NewMock returns a mock clock starting at the current wall time.
+func NewMock() *bclock.Mock {
+	mock := bclock.NewMock()
+	mock.Set(time.Now())
+	return mock
+}
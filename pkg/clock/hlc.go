@@ -0,0 +1,95 @@
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MaxHLCOffset is how far the wall time of a remote timestamp may lie ahead of the
+// local clock. Timestamps further ahead come from a clock that is off and are not
+// adopted, they would otherwise win every conflict until real time catches up.
+const MaxHLCOffset = time.Minute
+
+// ErrHLCOffset is returned by HLC.Update for timestamps too far in the future.
+var ErrHLCOffset = errors.New("timestamp is too far ahead of the local clock")
+
+// Timestamp is a hybrid logical clock timestamp: the wall time in nanoseconds and a
+// logical counter that orders events within the same wall time. Timestamps of one
+// HLC always increase and follow every timestamp the clock has observed, so they
+// order causally related events correctly even when wall clocks drift apart.
+type Timestamp struct {
+	Wall    int64  `json:"wall"`
+	Logical uint32 `json:"logical"`
+}
+
+// Compare returns -1, 0 or 1 if t is before, equal to or after o.
+func (t Timestamp) Compare(o Timestamp) int {
+	switch {
+	case t.Wall < o.Wall:
+		return -1
+	case t.Wall > o.Wall:
+		return 1
+	case t.Logical < o.Logical:
+		return -1
+	case t.Logical > o.Logical:
+		return 1
+	}
+	return 0
+}
+
+// IsZero reports whether t is the zero timestamp.
+func (t Timestamp) IsZero() bool {
+	return t.Wall == 0 && t.Logical == 0
+}
+
+// Time returns the wall time of t.
+func (t Timestamp) Time() time.Time {
+	return time.Unix(0, t.Wall)
+}
+
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%d.%d", t.Wall, t.Logical)
+}
+
+// HLC is a hybrid logical clock reading the wall time from the clock of a node.
+type HLC struct {
+	clock Clock
+	mu    sync.Mutex
+	last  Timestamp
+}
+
+// NewHLC creates a hybrid logical clock on top of c.
+func NewHLC(c Clock) *HLC {
+	return &HLC{clock: c}
+}
+
+// Now returns a timestamp after every timestamp returned or observed before.
+func (h *HLC) Now() Timestamp {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	wall := h.clock.Now().UnixNano()
+	if wall > h.last.Wall {
+		h.last = Timestamp{Wall: wall}
+	} else {
+		h.last.Logical++
+	}
+	return h.last
+}
+
+// Update advances the clock past a timestamp received from another node, so the
+// next local timestamp orders after it. Timestamps more than MaxHLCOffset ahead of
+// the local clock are rejected with ErrHLCOffset.
+func (h *HLC) Update(remote Timestamp) error {
+	wall := h.clock.Now()
+	if remote.Time().Sub(wall) > MaxHLCOffset {
+		return fmt.Errorf("%w: %s", ErrHLCOffset, remote.Time().Sub(wall))
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if remote.Compare(h.last) > 0 {
+		h.last = remote
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHLC(t *testing.T) {
+	mock := NewMock()
+	h := NewHLC(mock)
+
+	first := h.Now()
+	second := h.Now()
+	assert.Equal(t, first.Wall, second.Wall)
+	assert.Equal(t, 1, second.Compare(first), "timestamps increase while the wall clock stands still")
+
+	// a remote timestamp ahead of the local clock is followed by the next local one
+	remote := Timestamp{Wall: mock.Now().Add(time.Second).UnixNano(), Logical: 3}
+	require.NoError(t, h.Update(remote))
+	assert.Equal(t, 1, h.Now().Compare(remote))
+
+	// the wall clock takes over once it passes the remote timestamp
+	mock.Add(time.Minute)
+	assert.Equal(t, Timestamp{Wall: mock.Now().UnixNano()}, h.Now())
+
+	assert.ErrorIs(t, h.Update(Timestamp{Wall: mock.Now().Add(time.Hour).UnixNano()}), ErrHLCOffset)
+}
@@ -68,6 +68,24 @@ type AppConfig struct {
 	FilePath             string   `mapstructure:"FilePath"`
 	WriterNode           string   `mapstructure:"writerNode"`
 	CachePath            string   `mapstructure:"cachePath"`
+	CacheBackend         string   `mapstructure:"cacheBackend"`
+	BootnodeManifest     string   `mapstructure:"bootnodeManifest"`
+	BootnodeManifestKey  string   `mapstructure:"bootnodeManifestKey"`
+	PrivateNetwork       bool     `mapstructure:"privateNetwork"`
+	SwarmKeyFile         string   `mapstructure:"swarmKeyFile"`
+	Mdns                 bool     `mapstructure:"mdns"`
+	DhtMode              string   `mapstructure:"dhtMode"`
+	MinPeers             int      `mapstructure:"minPeers"`
+	AclAdmins            []string `mapstructure:"aclAdmins"`
+	ApiListen            string   `mapstructure:"apiListen"`
+	ApiKeys              []string `mapstructure:"apiKeys"`
+	ApiOperatorKeys      []string `mapstructure:"apiOperatorKeys"`
+	GrpcListen           string   `mapstructure:"grpcListen"`
+	GrpcTlsCert          string   `mapstructure:"grpcTlsCert"`
+	GrpcTlsKey           string   `mapstructure:"grpcTlsKey"`
+	// RateLimits overrides the rate limits and daily quotas of the API route groups,
+	// it is only read from the config file.
+	RateLimits map[string]RateLimit `mapstructure:"rateLimits"`
 
 	// These may be moved to a separate struct
 	TwitterCookiesPath string `mapstructure:"TwitterCookiesPath"`
@@ -77,6 +95,16 @@ type AppConfig struct {
 	ClaudeApiKey       string `mapstructure:"ClaudeApiKey"`
 }
 
+// RateLimit limits the requests every client may send to a route group of the API.
+type RateLimit struct {
+	// Rate is the sustained number of requests per second, zero for no limit.
+	Rate float64 `mapstructure:"rate"`
+	// Burst is the number of requests that may be sent at once.
+	Burst int `mapstructure:"burst"`
+	// Daily is the number of requests per UTC day, zero for no quota.
+	Daily int `mapstructure:"daily"`
+}
+
 func GetInstance() *AppConfig {
 	once.Do(func() {
 		instance = &AppConfig{}
@@ -119,6 +147,12 @@ func (c *AppConfig) setDefaultConfig() {
 		viper.SetDefault(FilePath, os.Getenv("FILE_PATH"))
 		viper.SetDefault(WriterNode, os.Getenv("WRITER_NODE"))
 		viper.SetDefault(CachePath, os.Getenv("CACHE_PATH"))
+		viper.SetDefault(CacheBackend, os.Getenv("CACHE_BACKEND"))
+		viper.SetDefault(BootnodeManifest, os.Getenv("BOOTNODE_MANIFEST"))
+		viper.SetDefault(BootnodeManifestKey, os.Getenv("BOOTNODE_MANIFEST_KEY"))
+		viper.SetDefault(AclAdmins, os.Getenv("ACL_ADMINS"))
+		viper.SetDefault(ApiKeys, os.Getenv("API_KEYS"))
+		viper.SetDefault(ApiOperatorKeys, os.Getenv("API_OPERATOR_KEYS"))
 	} else {
 		viper.SetDefault(FilePath, ".")
 		viper.SetDefault(RpcUrl, "https://ethereum-sepolia.publicnode.com")
@@ -130,10 +164,17 @@ func (c *AppConfig) setDefaultConfig() {
 	viper.SetDefault(UDP, true)
 	viper.SetDefault(TCP, false)
 	viper.SetDefault(StakeAmount, "")
-	viper.SetDefault(AllowedPeer, true) // TESTING TRUE FOR Issue-148 default => false
+	viper.SetDefault(AllowedPeer, false)
 	viper.SetDefault(LogLevel, "info")
 	viper.SetDefault(LogFilePath, "masa_oracle_node.log")
 	viper.SetDefault(PrivKeyFile, filepath.Join(viper.GetString(MasaDir), "masa_oracle_key"))
+	viper.SetDefault(PrivateNetwork, false)
+	viper.SetDefault(Mdns, true)
+	viper.SetDefault(DhtMode, "auto")
+	viper.SetDefault(MinPeers, 1)
+	viper.SetDefault(ApiListen, "127.0.0.1:8080")
+	viper.SetDefault(GrpcListen, "127.0.0.1:9090")
+	viper.SetDefault(SwarmKeyFile, filepath.Join(viper.GetString(MasaDir), "swarm.key"))
 }
 
 func (c *AppConfig) setFileConfig(path string) {
@@ -154,7 +195,7 @@ func (c *AppConfig) setEnvVariableConfig() {
 }
 
 func (c *AppConfig) setCommandLineConfig() error {
-	var bootnodes string
+	var bootnodes, aclAdmins, apiKeys, apiOperatorKeys string
 	pflag.IntVar(&c.PortNbr, "port", viper.GetInt(PortNbr), "The port number")
 	pflag.BoolVar(&c.UDP, "udp", viper.GetBool(UDP), "UDP flag")
 	pflag.BoolVar(&c.TCP, "tcp", viper.GetBool(TCP), "TCP flag")
@@ -174,6 +215,21 @@ func (c *AppConfig) setCommandLineConfig() error {
 	pflag.StringVar(&c.FilePath, FilePath, viper.GetString(FilePath), "The node file path")
 	pflag.StringVar(&c.WriterNode, "writerNode", viper.GetString(WriterNode), "Approved writer node boolean")
 	pflag.StringVar(&c.CachePath, "cachePath", viper.GetString(CachePath), "The cache path")
+	pflag.StringVar(&c.CacheBackend, "cacheBackend", viper.GetString(CacheBackend), "The cache storage backend: leveldb, badger, flatfs or memory")
+	pflag.StringVar(&c.BootnodeManifest, "bootnodeManifest", viper.GetString(BootnodeManifest), "Path or URL of a signed bootnode manifest")
+	pflag.StringVar(&aclAdmins, "aclAdmins", viper.GetString(AclAdmins), "Comma-separated peer IDs of the root admins of the database ACL")
+	pflag.StringVar(&c.ApiListen, "apiListen", viper.GetString(ApiListen), "Address the HTTP API listens on")
+	pflag.StringVar(&apiKeys, "apiKeys", viper.GetString(ApiKeys), "Comma-separated API keys with their scope, e.g. key:read,other:admin")
+	pflag.StringVar(&apiOperatorKeys, "apiOperatorKeys", viper.GetString(ApiOperatorKeys), "Comma-separated hex encoded public keys trusted to sign API tokens")
+	pflag.StringVar(&c.GrpcListen, "grpcListen", viper.GetString(GrpcListen), "Address the gRPC API listens on, empty to disable it")
+	pflag.StringVar(&c.GrpcTlsCert, "grpcTlsCert", viper.GetString(GrpcTlsCert), "TLS certificate file of the gRPC API")
+	pflag.StringVar(&c.GrpcTlsKey, "grpcTlsKey", viper.GetString(GrpcTlsKey), "TLS private key file of the gRPC API")
+	pflag.BoolVar(&c.Mdns, "mdns", viper.GetBool(Mdns), "Discover peers on the local network with mDNS")
+	pflag.StringVar(&c.DhtMode, "dhtMode", viper.GetString(DhtMode), "DHT mode: auto, server or client")
+	pflag.IntVar(&c.MinPeers, "minPeers", viper.GetInt(MinPeers), "Number of peers the DHT routing table needs for the node to be ready")
+	pflag.BoolVar(&c.PrivateNetwork, "privateNetwork", viper.GetBool(PrivateNetwork), "Only connect to peers sharing the pre-shared key in the swarm key file")
+	pflag.StringVar(&c.SwarmKeyFile, "swarmKeyFile", viper.GetString(SwarmKeyFile), "The private network pre-shared key file")
+	pflag.StringVar(&c.BootnodeManifestKey, "bootnodeManifestKey", viper.GetString(BootnodeManifestKey), "Hex encoded public key of the bootnode manifest publisher")
 	pflag.StringVar(&c.TwitterUsername, TwitterUsername, viper.GetString(TwitterUsername), "Twitter Username")
 	pflag.StringVar(&c.TwitterPassword, TwitterPassword, viper.GetString(TwitterPassword), "Twitter Password")
 	pflag.StringVar(&c.Twitter2FaCode, Twitter2FaCode, viper.GetString(Twitter2FaCode), "Twitter 2FA Code")
@@ -186,9 +242,23 @@ func (c *AppConfig) setCommandLineConfig() error {
 		return err
 	}
 	c.Bootnodes = strings.Split(bootnodes, ",")
+	c.AclAdmins = splitList(aclAdmins)
+	c.ApiKeys = splitList(apiKeys)
+	c.ApiOperatorKeys = splitList(apiOperatorKeys)
 	return nil
 }
 
+// splitList splits a comma-separated flag, dropping empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // LogConfig logs the non-sensitive parts of the AppConfig.
 // Adjust the fields being logged according to your application's needs.
 func (c *AppConfig) LogConfig() {
@@ -201,7 +271,7 @@ func (c *AppConfig) LogConfig() {
 		value := val.Field(i).Interface()
 
 		// Example of skipping sensitive fields
-		if field.Name == "PrivateKeyFile" || field.Name == "Signature" {
+		if field.Name == "PrivateKeyFile" || field.Name == "Signature" || field.Name == "ApiKeys" {
 			continue
 		}
 		logrus.Infof("%s: %v", field.Name, value)
@@ -209,5 +279,5 @@ func (c *AppConfig) LogConfig() {
 }
 
 func (c *AppConfig) HasBootnodes() bool {
-	return c.Bootnodes[0] != ""
+	return (len(c.Bootnodes) > 0 && c.Bootnodes[0] != "") || c.BootnodeManifest != ""
 }
@@ -8,35 +8,54 @@ import (
 )
 
 const (
-	PrivKeyFile = "MASA_PRIV_KEY_FILE"
-	BootNodes   = "BOOTNODES"
-	MasaDir     = "MASA_DIR"
-	RpcUrl      = "RPC_URL"
-	PortNbr     = "PORT_NBR"
-	UDP         = "UDP"
-	TCP         = "TCP"
-	PrivateKey  = "PRIVATE_KEY"
-	StakeAmount = "STAKE_AMOUNT"
-	LogLevel    = "LOG_LEVEL"
-	LogFilePath = "LOG_FILEPATH"
-	Environment = "ENV"
-	AllowedPeer = "allowedPeer"
-	Signature   = "signature"
-	Debug       = "debug"
-	Version     = "v0.0.9-alpha"
-	FilePath    = "FILE_PATH"
-	WriterNode  = "WRITER_NODE"
-	CachePath   = "CACHE_PATH"
-
-	MasaPrefix           = "/masa"
-	OracleProtocol       = "oracle_protocol"
-	NodeDataSyncProtocol = "nodeDataSync"
-	NodeGossipTopic      = "gossip"
-	AdTopic              = "ad"
-	NodeStatusTopic      = "nodeStatus"
-	PublicKeyTopic       = "bootNodePublicKey"
-	Rendezvous           = "masa-mdns"
-	PageSize             = 25
+	PrivKeyFile  = "MASA_PRIV_KEY_FILE"
+	BootNodes    = "BOOTNODES"
+	MasaDir      = "MASA_DIR"
+	RpcUrl       = "RPC_URL"
+	PortNbr      = "PORT_NBR"
+	UDP          = "UDP"
+	TCP          = "TCP"
+	PrivateKey   = "PRIVATE_KEY"
+	StakeAmount  = "STAKE_AMOUNT"
+	LogLevel     = "LOG_LEVEL"
+	LogFilePath  = "LOG_FILEPATH"
+	Environment  = "ENV"
+	AllowedPeer  = "allowedPeer"
+	Signature    = "signature"
+	Debug        = "debug"
+	Version      = "v0.0.9-alpha"
+	FilePath     = "FILE_PATH"
+	WriterNode   = "WRITER_NODE"
+	CachePath    = "CACHE_PATH"
+	CacheBackend = "CACHE_BACKEND"
+
+	BootnodeManifest    = "BOOTNODE_MANIFEST"
+	BootnodeManifestKey = "BOOTNODE_MANIFEST_KEY"
+	PrivateNetwork      = "PRIVATE_NETWORK"
+	SwarmKeyFile        = "SWARM_KEY_FILE"
+	Mdns                = "MDNS"
+	DhtMode             = "DHT_MODE"
+	MinPeers            = "MIN_PEERS"
+	AclAdmins           = "ACL_ADMINS"
+	ApiListen           = "API_LISTEN"
+	ApiKeys             = "API_KEYS"
+	ApiOperatorKeys     = "API_OPERATOR_KEYS"
+	GrpcListen          = "GRPC_LISTEN"
+	GrpcTlsCert         = "GRPC_TLS_CERT"
+	GrpcTlsKey          = "GRPC_TLS_KEY"
+
+	MasaPrefix            = "/masa"
+	OracleProtocol        = "oracle_protocol"
+	NodeDataSyncProtocol  = "nodeDataSync"
+	NodeGossipTopic       = "gossip"
+	AdTopic               = "ad"
+	NodeStatusTopic       = "nodeStatus"
+	PublicKeyTopic        = "bootNodePublicKey"
+	AclTopic              = "acl"
+	DataUpdatesTopic      = "dataUpdates"
+	BlockExchangeProtocol = "blockExchange"
+	Rendezvous            = "masa-mdns"
+	PageSize              = 25
 
 	TwitterUsername = "TWITTER_USERNAME"
 	TwitterPassword = "TWITTER_PASSWORD"
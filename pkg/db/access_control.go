@@ -1,98 +1,28 @@
-// Developer Note: This code is part of an ongoing development effort. It is crucial for managing access control within a DHT database environment.
-// Specifically, it involves verifying if a peer, identified through its host ID, has the authorization to write data to the database. This process is
-// accomplished by retrieving an allowed peer ID and its corresponding public key from the application's configuration settings. Subsequently, a signature
-// provided by the peer is verified to ensure it matches the allowed credentials. This mechanism is vital for maintaining the database's security by
-// ensuring that only authorized peers can perform write operations.
+// Developer Note: Write access to the DHT database is governed by the replicated ACL in pkg/acl. Root admins are
+// configured through the allowed peer and the ACL admin list; they and any admins they appoint grant the reader,
+// writer and admin roles with signed changes that every node verifies and applies. The same rules are enforced
+// locally before a write and by the /db DHT validator on every node that stores a record.
 
 package db
 
 import (
-	"encoding/hex"
-	libp2pCrypto "github.com/libp2p/go-libp2p/core/crypto"
-	"github.com/libp2p/go-libp2p/core/host"
-	"github.com/sirupsen/logrus"
-	"strings"
+	"github.com/libp2p/go-libp2p/core/peer"
 
-	"github.com/masa-finance/masa-oracle/pkg/config"
-	"github.com/masa-finance/masa-oracle/pkg/consensus"
+	masa "github.com/masa-finance/masa-oracle/pkg"
+	"github.com/masa-finance/masa-oracle/pkg/acl"
 )
 
-// AuthorizedNodes Set of authorized nodes that can write to the database
-type AuthorizedNodes map[string]bool
-
-// authorizedNodes Interface
-var authorizedNodes = AuthorizedNodes{}
-
-// Modifier idiomatic check if a node is authorized to write to the database
-func isAuthorized(nodeID string) bool {
-	// Check if the node is in the list of authorized nodes
-	for id := range authorizedNodes {
-		if id == nodeID {
-			return true
-		}
-	}
-	return false
+// isAuthorized checks whether the node may write the given database key
+func isAuthorized(node *masa.OracleNode, key string) bool {
+	return node.IsDBWriter(node.Host.ID(), "/db/"+key)
 }
 
-// Verifier checks if the given host is allowed to access to the database and verifies the signature
-func Verifier(h host.Host, data []byte, signature []byte) bool {
-	// Load configuration instance
-	cfg := config.GetInstance()
-
-	// Get allowed peer ID and public key from the configuration
-	allowedPeerID := cfg.AllowedPeerId
-	allowedPeerPubKeyString := cfg.AllowedPeerPublicKey
-
-	if allowedPeerID == "" || allowedPeerPubKeyString == "" {
-		logrus.Warn("Allowed peer ID or public key not found in configuration")
-		return false
-	}
-
-	// Decode the public key
-	allowedPeerPubKeyBytes, err := hex.DecodeString(allowedPeerPubKeyString)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to decode allowed peer public key")
-		return false
-	}
-
-	// Unmarshal the public key
-	allowedPeerPubKey, err := libp2pCrypto.UnmarshalPublicKey(allowedPeerPubKeyBytes)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to unmarshal allowed peer public key")
-		return false
-	}
-
-	// Check if the host ID matches the allowed peer ID
-	if h.ID().String() != allowedPeerID {
-		logrus.WithFields(logrus.Fields{
-			"hostID":        h.ID().String(),
-			"allowedPeerID": allowedPeerID,
-		}).Warn("Host ID does not match allowed peer ID")
-		return false
-	}
-
-	// Verify the signature
-	isValid, err := consensus.VerifySignature(allowedPeerPubKey, data, hex.EncodeToString(signature))
-	if err != nil || !isValid {
-		logrus.WithFields(logrus.Fields{
-			"hostID":        h.ID().String(),
-			"allowedPeerID": allowedPeerID,
-			"error":         err,
-		}).Warn("Failed to verify signature or signature is invalid")
-		return false
-	}
-
-	if strings.ToLower(cfg.WriterNode) == "true" {
-
-		logrus.WithFields(logrus.Fields{
-			"hostID":        h.ID().String(),
-			"allowedPeerID": allowedPeerID,
-		}).Info("Host is allowed to write to the database")
-
-		authorizedNodes = map[string]bool{
-			allowedPeerID: true,
-		}
-	}
+// GrantRole gives a peer a role in the ACL, signed with the node's key. The node must be an admin.
+func GrantRole(node *masa.OracleNode, peerID peer.ID, role acl.Role) (*acl.Change, error) {
+	return node.ACL.Grant(node.KeyManager.Libp2pPrivKey, peerID, role)
+}
 
-	return true
+// RevokeRole removes the role of a peer from the ACL, signed with the node's key. The node must be an admin.
+func RevokeRole(node *masa.OracleNode, peerID peer.ID) (*acl.Change, error) {
+	return node.ACL.Revoke(node.KeyManager.Libp2pPrivKey, peerID)
 }
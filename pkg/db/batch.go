@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	gosync "sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/masa-finance/masa-oracle/pkg/network"
+)
+
+const (
+	// BatchConcurrency bounds the number of DHT operations a batch runs at once.
+	BatchConcurrency = 16
+	// BatchTimeout is the deadline shared by the operations of a batch.
+	BatchTimeout = time.Second * 120
+	// MaxBatchSize is the largest number of keys in a batch.
+	MaxBatchSize = 500
+)
+
+// ErrBatchTooLarge is returned for batches of more than MaxBatchSize keys.
+var ErrBatchTooLarge = fmt.Errorf("a batch holds at most %d keys", MaxBatchSize)
+
+// BatchPut is one write of a batch. Private values are encrypted for the node and
+// the readers, see WritePrivateData.
+type BatchPut struct {
+	Key     string
+	Value   []byte
+	TTL     time.Duration
+	Private bool
+	Readers []peer.ID
+}
+
+// BatchResult is the outcome of the operation on one key of a batch. Record is the
+// version read or written. Value is the opened value of a read, Err is set when the
+// operation failed, such as with ErrRecordNotFound, ErrReadTimeout or ErrAccessDenied.
+type BatchResult struct {
+	Key    string
+	Record *network.DBRecord
+	Value  []byte
+	Err    error
+}
+
+// BatchGet reads the keys with at most BatchConcurrency lookups at once. The lookups
+// share the deadline of ctx, capped at BatchTimeout. The results are in the order of
+// the keys.
+func (d *Database) BatchGet(ctx context.Context, keys []string) ([]BatchResult, error) {
+	if len(keys) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+	results := make([]BatchResult, len(keys))
+	for i, key := range keys {
+		results[i] = BatchResult{Key: key, Err: fmt.Errorf("%w: %s", ErrReadTimeout, key)}
+	}
+	runBatch(ctx, len(keys), func(ctx context.Context, i int) {
+		result := &results[i]
+		result.Record, result.Err = d.readRecord(ctx, keys[i])
+		if result.Err == nil {
+			// private records the node may not read are returned without a value
+			result.Value, result.Err = d.OpenRecord(result.Record)
+		}
+	})
+	return results, nil
+}
+
+// BatchWrite writes the values with at most BatchConcurrency puts at once, under the
+// same deadline as BatchGet. The results are in the order of the puts.
+func (d *Database) BatchWrite(ctx context.Context, puts []BatchPut) ([]BatchResult, error) {
+	if len(puts) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+	results := make([]BatchResult, len(puts))
+	for i, put := range puts {
+		results[i] = BatchResult{Key: put.Key, Err: fmt.Errorf("%w: %s was not written", context.DeadlineExceeded, put.Key)}
+	}
+	runBatch(ctx, len(puts), func(ctx context.Context, i int) {
+		results[i].Record, results[i].Err = d.WriteValue(ctx, puts[i])
+	})
+	return results, nil
+}
+
+// WriteValue writes one value like WriteDataWithTTL or WritePrivateData and returns
+// the version it created.
+func (d *Database) WriteValue(ctx context.Context, put BatchPut) (*network.DBRecord, error) {
+	if put.Private {
+		return d.writePrivateData(ctx, put.Key, put.Value, put.Readers, put.TTL)
+	}
+	return d.writeData(ctx, put.Key, put.Value, put.TTL)
+}
+
+// runBatch calls op for the indexes below n with bounded concurrency. The operations
+// that did not start before the deadline are not run, their results keep the timeout
+// error set up front.
+func runBatch(ctx context.Context, n int, op func(ctx context.Context, i int)) {
+	ctx, cancel := context.WithTimeout(ctx, BatchTimeout)
+	defer cancel()
+
+	sem := make(chan struct{}, BatchConcurrency)
+	var wg gosync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			op(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// IsTimeout reports whether err is the deadline of a read or write passing.
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrReadTimeout) || errors.Is(err, context.DeadlineExceeded)
+}
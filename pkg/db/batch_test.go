@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBatch(t *testing.T) {
+	var running, peak atomic.Int32
+	done := make([]bool, BatchConcurrency*3)
+	runBatch(context.Background(), len(done), func(ctx context.Context, i int) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond * 5)
+		running.Add(-1)
+		done[i] = true
+	})
+	assert.Equal(t, int32(BatchConcurrency), peak.Load())
+	for _, ok := range done {
+		assert.True(t, ok)
+	}
+
+	// operations that did not start before the shared deadline are skipped
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	var started atomic.Int32
+	runBatch(ctx, BatchConcurrency*3, func(ctx context.Context, i int) {
+		started.Add(1)
+		<-ctx.Done()
+	})
+	assert.Equal(t, int32(BatchConcurrency), started.Load())
+}
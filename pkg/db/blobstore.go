@@ -0,0 +1,369 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/config"
+)
+
+const (
+	// BlobChunkSize is the size of the blocks a blob is split into.
+	BlobChunkSize = 256 * 1024
+	// MaxBlobSize is the largest blob accepted for upload.
+	MaxBlobSize = 1 << 30
+	// BlobReprovideInterval is how often the provider records of the stored blobs are
+	// refreshed, they expire from the DHT after 48 hours.
+	BlobReprovideInterval = time.Hour * 22
+
+	// maxBlockSize bounds the blocks accepted from peers, a manifest of a blob of
+	// MaxBlobSize stays well below it.
+	maxBlockSize = 1 << 20
+	// maxBlobProviders is the number of providers asked for a missing blob.
+	maxBlobProviders = 10
+	blobFetchTimeout = time.Second * 30
+
+	blobBlocksPrefix = "/.blobs/blocks"
+	blobRootsPrefix  = "/.blobs/roots"
+
+	blockFound    = "ok"
+	blockNotFound = "not found"
+)
+
+var (
+	ErrBlobNotFound = errors.New("blob not found")
+	ErrBlobTooLarge = errors.New("blob too large")
+	ErrInvalidBlock = errors.New("block does not match its CID")
+)
+
+var (
+	chunkPrefix    = cid.NewPrefixV1(cid.Raw, mh.SHA2_256)
+	manifestPrefix = cid.NewPrefixV1(cid.DagJSON, mh.SHA2_256)
+)
+
+// BlobManifest is the root block of a blob. It lists the CIDs of the chunks holding
+// the data in order, encoded as DAG-JSON so the links are readable by IPLD tools.
+type BlobManifest struct {
+	Chunks []cid.Cid `json:"chunks"`
+	Size   int64     `json:"size"`
+}
+
+// BlobStore stores payloads too large for DHT values. A blob is split into chunks
+// that are addressed by their CID, the CID of its manifest addresses the blob. Nodes
+// holding a blob announce it with a DHT provider record and serve its blocks over the
+// block exchange protocol, every block is verified against its CID when fetched.
+type BlobStore struct {
+	store     ds.Batching
+	host      host.Host
+	routing   routing.ContentRouting
+	ChunkSize int
+}
+
+// NewBlobStore creates a blob store keeping its blocks in store.
+func NewBlobStore(store ds.Batching, h host.Host, r routing.ContentRouting) *BlobStore {
+	return &BlobStore{
+		store:     store,
+		host:      h,
+		routing:   r,
+		ChunkSize: BlobChunkSize,
+	}
+}
+
+// Start serves the stored blocks to other nodes.
+func (b *BlobStore) Start() {
+	b.host.SetStreamHandler(config.ProtocolWithVersion(config.BlockExchangeProtocol), b.handleStream)
+}
+
+// Put stores the blob read from r and announces it in the DHT.
+func (b *BlobStore) Put(ctx context.Context, r io.Reader) (cid.Cid, int64, error) {
+	manifest := BlobManifest{Chunks: make([]cid.Cid, 0)}
+	for {
+		// every chunk gets its own buffer, datastores may keep the slice they are given
+		buf := make([]byte, b.ChunkSize)
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			manifest.Size += int64(n)
+			if manifest.Size > MaxBlobSize {
+				return cid.Undef, 0, ErrBlobTooLarge
+			}
+			c, perr := b.putBlock(ctx, chunkPrefix, buf[:n])
+			if perr != nil {
+				return cid.Undef, 0, perr
+			}
+			manifest.Chunks = append(manifest.Chunks, c)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return cid.Undef, 0, err
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return cid.Undef, 0, err
+	}
+	root, err := b.putBlock(ctx, manifestPrefix, data)
+	if err != nil {
+		return cid.Undef, 0, err
+	}
+	if err := b.addRoot(ctx, root); err != nil {
+		return cid.Undef, 0, err
+	}
+	go b.provide(root)
+	return root, manifest.Size, nil
+}
+
+// Stat returns the manifest of the blob, fetching it from a provider if needed.
+func (b *BlobStore) Stat(ctx context.Context, root cid.Cid) (*BlobManifest, error) {
+	return b.manifest(ctx, root, &blobProviders{store: b, root: root})
+}
+
+// Get writes the blob to w, fetching missing blocks from the nodes providing it. A
+// node that fetched a complete blob provides it as well.
+func (b *BlobStore) Get(ctx context.Context, root cid.Cid, w io.Writer) (int64, error) {
+	providers := &blobProviders{store: b, root: root}
+	manifest, err := b.manifest(ctx, root, providers)
+	if err != nil {
+		return 0, err
+	}
+	var written int64
+	for _, c := range manifest.Chunks {
+		data, err := b.getBlock(ctx, c, providers)
+		if err != nil {
+			return written, err
+		}
+		n, err := w.Write(data)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	if providers.used {
+		if err := b.addRoot(ctx, root); err != nil {
+			logrus.Warnf("Failed to record blob %s: %v", root, err)
+		}
+		go b.provide(root)
+	}
+	return written, nil
+}
+
+// Has reports whether the blob is stored completely on this node.
+func (b *BlobStore) Has(ctx context.Context, root cid.Cid) (bool, error) {
+	return b.store.Has(ctx, rootKey(root))
+}
+
+// Reprovide refreshes the provider records of every blob stored on this node.
+func (b *BlobStore) Reprovide(ctx context.Context) {
+	results, err := b.store.Query(ctx, query.Query{Prefix: blobRootsPrefix, KeysOnly: true})
+	if err != nil {
+		logrus.Errorf("Failed to list blobs: %v", err)
+		return
+	}
+	defer results.Close()
+	for result := range results.Next() {
+		if result.Error != nil {
+			logrus.Errorf("Failed to list blobs: %v", result.Error)
+			return
+		}
+		root, err := cid.Decode(strings.TrimPrefix(result.Key, blobRootsPrefix+"/"))
+		if err != nil {
+			continue
+		}
+		b.provide(root)
+	}
+}
+
+// ReprovideLoop calls Reprovide every interval until ctx is done.
+func (b *BlobStore) ReprovideLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.Reprovide(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *BlobStore) provide(root cid.Cid) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := b.routing.Provide(ctx, root, true); err != nil {
+		logrus.Warnf("Failed to announce blob %s: %v", root, err)
+	}
+}
+
+func (b *BlobStore) manifest(ctx context.Context, root cid.Cid, providers *blobProviders) (*BlobManifest, error) {
+	if root.Type() != cid.DagJSON {
+		return nil, fmt.Errorf("%w: %s is not a blob manifest", ErrBlobNotFound, root)
+	}
+	data, err := b.getBlock(ctx, root, providers)
+	if err != nil {
+		return nil, err
+	}
+	var manifest BlobManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid blob manifest %s: %w", root, err)
+	}
+	return &manifest, nil
+}
+
+func (b *BlobStore) putBlock(ctx context.Context, prefix cid.Prefix, data []byte) (cid.Cid, error) {
+	c, err := prefix.Sum(data)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return c, b.store.Put(ctx, blockKey(c), data)
+}
+
+func (b *BlobStore) addRoot(ctx context.Context, root cid.Cid) error {
+	return b.store.Put(ctx, rootKey(root), nil)
+}
+
+// getBlock returns the block from the local store or from one of the providers.
+func (b *BlobStore) getBlock(ctx context.Context, c cid.Cid, providers *blobProviders) ([]byte, error) {
+	data, err := b.store.Get(ctx, blockKey(c))
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, ds.ErrNotFound) {
+		return nil, err
+	}
+	for _, p := range providers.list(ctx) {
+		data, err := b.fetchBlock(ctx, p, c)
+		if err != nil {
+			logrus.Debugf("Failed to fetch block %s from %s: %v", c, p, err)
+			continue
+		}
+		if err := b.store.Put(ctx, blockKey(c), data); err != nil {
+			return nil, err
+		}
+		providers.used = true
+		return data, nil
+	}
+	return nil, fmt.Errorf("%w: block %s", ErrBlobNotFound, c)
+}
+
+// fetchBlock requests a block from a peer and verifies it against its CID.
+func (b *BlobStore) fetchBlock(ctx context.Context, p peer.ID, c cid.Cid) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, blobFetchTimeout)
+	defer cancel()
+	stream, err := b.host.NewStream(ctx, p, config.ProtocolWithVersion(config.BlockExchangeProtocol))
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+
+	if _, err := stream.Write([]byte(c.String() + "\n")); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseWrite(); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(stream)
+	status, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(status) != blockFound {
+		return nil, ErrBlobNotFound
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBlockSize {
+		return nil, ErrBlobTooLarge
+	}
+	sum, err := c.Prefix().Sum(data)
+	if err != nil || !sum.Equals(c) {
+		return nil, ErrInvalidBlock
+	}
+	return data, nil
+}
+
+func (b *BlobStore) handleStream(stream network.Stream) {
+	defer stream.Close()
+	_ = stream.SetDeadline(time.Now().Add(blobFetchTimeout))
+
+	line, err := bufio.NewReader(io.LimitReader(stream, 256)).ReadString('\n')
+	if err != nil {
+		logrus.Debugf("Failed to read block request: %v", err)
+		return
+	}
+	c, err := cid.Decode(strings.TrimSpace(line))
+	if err != nil {
+		_, _ = stream.Write([]byte(blockNotFound + "\n"))
+		return
+	}
+	data, err := b.store.Get(context.Background(), blockKey(c))
+	if err != nil {
+		_, _ = stream.Write([]byte(blockNotFound + "\n"))
+		return
+	}
+	if _, err := stream.Write([]byte(blockFound + "\n")); err != nil {
+		return
+	}
+	_, _ = stream.Write(data)
+}
+
+// blobProviders looks up the providers of a blob once, when the first block is missing.
+type blobProviders struct {
+	store  *BlobStore
+	root   cid.Cid
+	peers  []peer.ID
+	looked bool
+	used   bool
+}
+
+func (p *blobProviders) list(ctx context.Context) []peer.ID {
+	if p.looked {
+		return p.peers
+	}
+	p.looked = true
+	ctx, cancel := context.WithTimeout(ctx, blobFetchTimeout)
+	defer cancel()
+	self := p.store.host.ID()
+	for info := range p.store.routing.FindProvidersAsync(ctx, p.root, maxBlobProviders) {
+		if info.ID == self {
+			continue
+		}
+		if len(info.Addrs) > 0 {
+			p.store.host.Peerstore().AddAddrs(info.ID, info.Addrs, time.Hour)
+		}
+		p.peers = append(p.peers, info.ID)
+	}
+	return p.peers
+}
+
+func blockKey(c cid.Cid) ds.Key {
+	return ds.NewKey(blobBlocksPrefix + "/" + c.String())
+}
+
+func rootKey(c cid.Cid) ds.Key {
+	return ds.NewKey(blobRootsPrefix + "/" + c.String())
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	masa "github.com/masa-finance/masa-oracle/pkg"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+)
+
+// Database is the record store of one node: its resolver cache, the republisher that
+// keeps the cached records in the DHT and the blob store sharing the cache datastore.
+type Database struct {
+	node        *masa.OracleNode
+	cache       *ResolverCache
+	republisher *Republisher
+	blobs       *BlobStore
+}
+
+// New creates the database of the node on top of cache. Start begins publishing its
+// records.
+func New(node *masa.OracleNode, cache *ResolverCache) *Database {
+	// every node republishes the records it wrote, peers that are not authorized to
+	// write a key reject its records in the DHT validator
+	republisher := NewRepublisher(cache, func(ctx context.Context, dhtKey string, value []byte) error {
+		return node.DHT.PutValue(ctx, dhtKey, value)
+	})
+	republisher.Get = func(ctx context.Context, dhtKey string) ([]byte, error) {
+		return node.DHT.GetValue(ctx, dhtKey)
+	}
+	return &Database{
+		node:        node,
+		cache:       cache,
+		republisher: republisher,
+		blobs:       NewBlobStore(cache.Datastore(), node.Host, node.DHT),
+	}
+}
+
+// Start loads the change log and runs the republisher, the blob reprovider and the
+// node status updates until ctx is done.
+func (d *Database) Start(ctx context.Context) {
+	if err := d.republisher.Load(ctx); err != nil {
+		logrus.Errorf("Failed to load the change log: %v", err)
+	}
+	go d.republisher.Run(ctx, RepublishInterval)
+	d.blobs.Start()
+	go d.blobs.ReprovideLoop(ctx, BlobReprovideInterval)
+	go d.monitorNodeData(ctx)
+}
+
+// Cache returns the resolver cache of the node.
+func (d *Database) Cache() *ResolverCache {
+	return d.cache
+}
+
+// BlobStore returns the blob store of the node, nil without a database.
+func (d *Database) BlobStore() *BlobStore {
+	if d == nil {
+		return nil
+	}
+	return d.blobs
+}
+
+// RepublishStats returns the progress of the republisher.
+func (d *Database) RepublishStats() RepublishStats {
+	return d.republisher.Stats()
+}
+
+// ConflictStats returns the conflict counters of the node.
+func (d *Database) ConflictStats() ConflictStats {
+	return ConflictStats{
+		StaleWrites: d.cache.staleWrites.Load(),
+		Adopted:     d.republisher.adopted.Load(),
+		Selections:  network.DBConflicts(),
+	}
+}
+
+// Query returns the page of entries of the node's cache selected by q.
+func (d *Database) Query(ctx context.Context, q Query) (*Page, error) {
+	return d.cache.Query(ctx, q)
+}
+
+// Count returns the number of entries of the node's cache matching q.
+func (d *Database) Count(ctx context.Context, q Query) (int, error) {
+	return d.cache.Count(ctx, q)
+}
+
+// KeyVersions returns the recent versions of the key known to the cache, newest first.
+func (d *Database) KeyVersions(ctx context.Context, key string) ([]*network.DBRecord, error) {
+	return d.cache.Versions(ctx, normalizeKey(key))
+}
@@ -0,0 +1,236 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/acl"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/namespace"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/nodestatus"
+	"github.com/masa-finance/masa-oracle/pkg/testharness"
+)
+
+func TestBlobExchange(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 3, MemoryCache: true})
+	h.WaitForRoutingTables()
+
+	stores := make([]*db.BlobStore, 3)
+	for i := range stores {
+		stores[i] = h.DB(i).BlobStore()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testharness.ConvergeTimeout)
+	defer cancel()
+
+	data := make([]byte, db.BlobChunkSize*2+100)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	root, size, err := stores[1].Put(ctx, bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), size)
+
+	var fetched bytes.Buffer
+	require.Eventually(t, func() bool {
+		fetched.Reset()
+		_, err := stores[2].Get(ctx, root, &fetched)
+		return err == nil
+	}, testharness.ConvergeTimeout, time.Second, "node 2 should fetch the blob from node 1")
+	assert.Equal(t, data, fetched.Bytes())
+
+	// node 2 provides the blob it fetched, so it survives node 1 leaving
+	has, err := stores[2].Has(ctx, root)
+	require.NoError(t, err)
+	assert.True(t, has)
+	h.StopNode(1)
+	require.Eventually(t, func() bool {
+		fetched.Reset()
+		_, err := stores[0].Get(ctx, root, &fetched)
+		return err == nil
+	}, testharness.ConvergeTimeout, time.Second, "node 0 should fetch the blob from node 2")
+	assert.Equal(t, data, fetched.Bytes())
+}
+
+func TestPrivateRecordReaders(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 3, MemoryCache: true})
+	h.WaitForRoutingTables()
+
+	// node 1 shares its private data with node 2 only
+	key := h.ID(1).String()
+	_, err := h.DB(1).WritePrivateData(key, []byte(`{"apiKey":"secret"}`), []peer.ID{h.ID(2)}, 0)
+	require.NoError(t, err)
+
+	record, err := h.DB(2).ReadRecord(key)
+	require.NoError(t, err)
+	assert.True(t, record.Private)
+	assert.NotContains(t, string(record.Value), "secret")
+	value, err := h.DB(2).OpenRecord(record)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"apiKey":"secret"}`, string(value))
+
+	record, err = h.DB(0).ReadRecord(key)
+	require.NoError(t, err)
+	_, err = h.DB(0).OpenRecord(record)
+	assert.ErrorIs(t, err, db.ErrAccessDenied)
+
+	// writes to the node's own key are private by default, node 2 looks the new version
+	// up instead of serving the one it cached
+	_, err = h.DB(1).WriteData(key, []byte(`{"apiKey":"rotated"}`))
+	require.NoError(t, err)
+	record, err = h.DB(2).LookupRecord(context.Background(), key)
+	require.NoError(t, err)
+	assert.True(t, record.Private)
+	_, err = h.DB(2).OpenRecord(record)
+	assert.ErrorIs(t, err, db.ErrAccessDenied)
+	value, err = h.DB(1).ReadData(key)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"apiKey":"rotated"}`, string(value))
+
+	// private values are checked against the schema of their namespace before they are
+	// sealed, the DHT validators accept the sealed record
+	profile := "profiles/" + h.ID(1).String()
+	_, err = h.DB(1).WritePrivateData(profile, []byte(`{"nick":"x"}`), []peer.ID{h.ID(2)}, 0)
+	assert.ErrorIs(t, err, namespace.ErrSchemaViolation)
+	_, err = h.DB(1).WritePrivateData(profile, []byte(`{"name":"operator"}`), []peer.ID{h.ID(2)}, 0)
+	require.NoError(t, err)
+	record, err = h.DB(2).ReadRecord(profile)
+	require.NoError(t, err)
+	assert.True(t, record.Private)
+	value, err = h.DB(2).OpenRecord(record)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"operator"}`, string(value))
+}
+
+func TestDataUpdatesReachWatchers(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 3, MemoryCache: true})
+	h.WaitForRoutingTables()
+
+	watch := h.Node(2).DataUpdates.Watch("profiles/")
+	defer watch.Close()
+	key := "profiles/" + h.ID(1).String()
+	_, err := h.DB(1).WriteData(key, []byte(`{"name":"operator"}`))
+	require.NoError(t, err)
+	envelope, err := h.DB(1).Cache().Get(context.Background(), key)
+	require.NoError(t, err)
+
+	// the announcement is repeated until the gossip mesh of the topic has formed
+	var update network.DBUpdate
+	require.Eventually(t, func() bool {
+		select {
+		case update = <-watch.Updates():
+			return true
+		default:
+			h.Node(1).DataUpdates.Announce(envelope)
+			return false
+		}
+	}, testharness.ConvergeTimeout, testharness.PollInterval, "node 2 should be notified of the write")
+	assert.Equal(t, key, update.Key)
+	assert.Equal(t, uint64(1), update.Record.Seq)
+	assert.JSONEq(t, `{"name":"operator"}`, string(update.Record.Value))
+}
+
+func TestBatchReadsAndWrites(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 3, MemoryCache: true})
+	h.WaitForRoutingTables()
+
+	ctx := context.Background()
+	own := "profiles/" + h.ID(1).String()
+	written, err := h.DB(1).BatchWrite(ctx, []db.BatchPut{
+		{Key: own, Value: []byte(`{"name":"operator"}`)},
+		{Key: own + "/nested", Value: []byte(`{"nick":"x"}`)},
+		{Key: "unknown/key", Value: []byte(`{}`)},
+	})
+	require.NoError(t, err)
+	require.Len(t, written, 3)
+	require.NoError(t, written[0].Err)
+	assert.Equal(t, uint64(1), written[0].Record.Seq)
+	assert.Error(t, written[1].Err)
+	assert.ErrorIs(t, written[2].Err, namespace.ErrUnknownNamespace)
+
+	// the results keep the order of the keys, with an error per key
+	read, err := h.DB(2).BatchGet(ctx, []string{"profiles/" + h.ID(0).String(), own})
+	require.NoError(t, err)
+	require.Len(t, read, 2)
+	assert.ErrorIs(t, read[0].Err, db.ErrRecordNotFound)
+	require.NoError(t, read[1].Err)
+	assert.JSONEq(t, `{"name":"operator"}`, string(read[1].Value))
+
+	_, err = h.DB(2).BatchGet(ctx, make([]string, db.MaxBatchSize+1))
+	assert.ErrorIs(t, err, db.ErrBatchTooLarge)
+}
+
+func TestNodesStoreTheirOwnStatus(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 3, MemoryCache: true})
+	h.WaitForRoutingTables()
+	require.Equal(t, acl.RoleNone, h.Node(1).ACL.Role(h.ID(1)))
+
+	// node 1 holds no role in the ACL and still stores its status on the next tick
+	key := "nodestatus/" + h.ID(1).String()
+	require.Eventually(t, func() bool {
+		h.AdvanceTime(time.Minute)
+		_, err := h.DB(1).Cache().Get(context.Background(), key)
+		return err == nil
+	}, testharness.ConvergeTimeout, testharness.PollInterval, "node 1 should store its status")
+
+	record, err := h.DB(2).LookupRecord(context.Background(), key)
+	require.NoError(t, err)
+	assert.Equal(t, h.ID(1).String(), record.Writer)
+	var status nodestatus.NodeStatus
+	require.NoError(t, json.Unmarshal(record.Value, &status))
+	assert.Equal(t, h.ID(1).String(), status.PeerID)
+
+	// the statuses node 1 receives from the other nodes are theirs to store
+	_, err = h.DB(1).Cache().Get(context.Background(), "nodestatus/"+h.ID(2).String())
+	assert.Error(t, err)
+}
+
+func TestWritesKeepOnlyStoredVersions(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 3, MemoryCache: true})
+	h.WaitForRoutingTables()
+	ctx := context.Background()
+
+	key := "profiles/" + h.ID(1).String()
+	for seq := uint64(1); seq <= 2; seq++ {
+		record, err := h.DB(1).WriteValue(ctx, db.BatchPut{Key: key, Value: []byte(`{"name":"operator"}`)})
+		require.NoError(t, err)
+		assert.Equal(t, seq, record.Seq)
+	}
+
+	// a version the DHT did not store before the deadline is not kept
+	expired, cancel := context.WithTimeout(ctx, 0)
+	defer cancel()
+	_, err := h.DB(1).WriteValue(expired, db.BatchPut{Key: key, Value: []byte(`{"name":"renamed"}`)})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	record, err := h.DB(1).ReadRecord(key)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), record.Seq)
+	assert.JSONEq(t, `{"name":"operator"}`, string(record.Value))
+}
+
+func TestRecordsFollowTheNodeClock(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 3, MemoryCache: true})
+	h.WaitForRoutingTables()
+
+	// the nodes run ahead of the process
+	h.AdvanceTime(time.Minute * 10)
+	key := "profiles/" + h.ID(1).String()
+	_, err := h.DB(1).WriteDataWithTTL(key, []byte(`{"name":"operator"}`), time.Hour)
+	require.NoError(t, err)
+	record, err := h.DB(1).ReadRecord(key)
+	require.NoError(t, err)
+	assert.WithinDuration(t, h.Clock.Now(), time.Unix(0, record.Timestamp), time.Minute)
+
+	// the record expires by the node clock, not by the wall clock
+	h.AdvanceTime(time.Hour)
+	_, err = h.DB(1).ReadRecord(key)
+	assert.ErrorIs(t, err, db.ErrRecordNotFound)
+}
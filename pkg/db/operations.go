@@ -2,82 +2,247 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	masa "github.com/masa-finance/masa-oracle/pkg"
+	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
+	"github.com/masa-finance/masa-oracle/pkg/network"
 
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/sirupsen/logrus"
 )
 
+// ErrRecordNotFound is returned when a key was never written, has been deleted or has expired.
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrUnauthorized is returned when the node may not write the key.
+var ErrUnauthorized = errors.New("401, node is not authorized to write to the datastore")
+
+// ErrAccessDenied is returned when the node is not a reader of a private record.
+var ErrAccessDenied = masacrypto.ErrAccessDenied
+
+// ErrInvalidReader is returned for readers of private records whose public key is unknown.
+var ErrInvalidReader = errors.New("invalid reader")
+
+// WriteTimeout bounds the storage of a new version in the DHT.
+const WriteTimeout = time.Second * 120
+
+// normalizeKey strips a leading /db/ so callers may pass either form of the key.
+func normalizeKey(key string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, "/"), "db/")
+}
+
 // WriteData encapsulates the logic for writing data to the database,
 // including access control checks from access_control.go.
-func WriteData(node *masa.OracleNode, key string, value []byte) (bool, error) {
-	if !isAuthorized(node.Host.ID().String()) {
+func (d *Database) WriteData(key string, value []byte) (bool, error) {
+	return d.WriteDataWithTTL(key, value, 0)
+}
+
+// WriteDataWithTTL writes a new version of the key that expires after ttl. A ttl of
+// zero keeps the value until it is overwritten or deleted. The key named after the
+// node's own peer ID holds the node's private data, it is encrypted for the node only.
+func (d *Database) WriteDataWithTTL(key string, value []byte, ttl time.Duration) (bool, error) {
+	_, err := d.writeData(context.Background(), key, value, ttl)
+	return err == nil, err
+}
+
+func (d *Database) writeData(ctx context.Context, key string, value []byte, ttl time.Duration) (*network.DBRecord, error) {
+	if normalizeKey(key) == d.node.Host.ID().String() {
+		return d.writePrivateData(ctx, key, value, nil, ttl)
+	}
+	if err := d.validateValue(key, value); err != nil {
+		return nil, err
+	}
+	return d.putRecord(ctx, key, func(dhtKey string, seq uint64) (*network.DBRecord, error) {
+		return network.NewDBRecord(d.node.HLC, d.node.KeyManager.Libp2pPrivKey, dhtKey, value, seq, ttl)
+	})
+}
+
+// WritePrivateData writes a new version of the key with the value encrypted for this
+// node and the given readers. Everybody can fetch the record, only the readers can
+// decrypt it. Readers are identified by peer IDs that embed their public key, which
+// holds for the secp256k1 keys of masa nodes.
+func (d *Database) WritePrivateData(key string, value []byte, readers []peer.ID, ttl time.Duration) (bool, error) {
+	_, err := d.writePrivateData(context.Background(), key, value, readers, ttl)
+	return err == nil, err
+}
+
+func (d *Database) writePrivateData(ctx context.Context, key string, value []byte, readers []peer.ID, ttl time.Duration) (*network.DBRecord, error) {
+	if err := d.validateValue(key, value); err != nil {
+		return nil, err
+	}
+	readerKeys := make([]crypto.PubKey, 0, len(readers))
+	for _, reader := range readers {
+		pubKey, err := reader.ExtractPublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidReader, reader, err)
+		}
+		readerKeys = append(readerKeys, pubKey)
+	}
+	return d.putRecord(ctx, key, func(dhtKey string, seq uint64) (*network.DBRecord, error) {
+		return network.NewPrivateDBRecord(d.node.HLC, d.node.KeyManager.Libp2pPrivKey, dhtKey, value, readerKeys, seq, ttl)
+	})
+}
+
+// validateValue checks the value against the schema of the namespace of the key.
+// Private values are checked before they are encrypted.
+func (d *Database) validateValue(key string, value []byte) error {
+	ns, _, err := d.node.Namespaces.Lookup(normalizeKey(key))
+	if err != nil {
+		return err
+	}
+	if ns != nil {
+		return ns.Validate(value)
+	}
+	return nil
+}
+
+// DeleteData deletes the key by publishing a tombstone as its next version. The
+// tombstone is republished like any other record, so replicas of the deleted value
+// are replaced across the network.
+func (d *Database) DeleteData(key string) (bool, error) {
+	_, err := d.DeleteRecord(context.Background(), key)
+	return err == nil, err
+}
+
+// DeleteRecord deletes the key like DeleteData and returns the tombstone.
+func (d *Database) DeleteRecord(ctx context.Context, key string) (*network.DBRecord, error) {
+	return d.putRecord(ctx, key, func(dhtKey string, seq uint64) (*network.DBRecord, error) {
+		return network.NewDBTombstone(d.node.HLC, d.node.KeyManager.Libp2pPrivKey, dhtKey, seq)
+	})
+}
+
+// putRecord signs the next version of the key built by newRecord and stores it in the
+// DHT and then the local cache, within WriteTimeout or the deadline of ctx. Versions
+// the DHT did not accept are not kept.
+func (d *Database) putRecord(ctx context.Context, key string, newRecord func(dhtKey string, seq uint64) (*network.DBRecord, error)) (*network.DBRecord, error) {
+	key = normalizeKey(key)
+	if _, _, err := d.node.Namespaces.Lookup(key); err != nil {
+		return nil, err
+	}
+	if !isAuthorized(d.node, key) {
 		logrus.WithFields(logrus.Fields{
-			"nodeID":       node.Host.ID().String(),
+			"nodeID":       d.node.Host.ID().String(),
 			"isAuthorized": false,
 			"WriteData":    true,
-		})
-		return false, fmt.Errorf("401, node is not authorized to write to the datastore")
+		}).Warn("Rejected write to the datastore")
+		return nil, ErrUnauthorized
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*120)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeout)
 	defer cancel()
 
-	var err error
-	node.DHT.ForceRefresh()
-	if key != node.Host.ID().String() {
-		err = node.DHT.PutValue(ctx, "/db/"+key, value) // any key value so the data is public
-		_, er := PutCache(ctx, key, value)
-		if er != nil {
-			logrus.Errorf("%v", er)
-		}
-	} else {
-		err = node.DHT.PutValue(ctx, "/db/"+node.Host.ID().String(), value) // nodes private data based on node id
-		_, er := PutCache(ctx, node.Host.ID().String(), value)
-		if er != nil {
-			logrus.Errorf("%v", er)
-		}
+	d.node.DHT.ForceRefresh()
+	// any key value so the data is public, the node id key holds the nodes private data
+	dhtKey := "/db/" + key
+	record, err := newRecord(dhtKey, d.nextSeq(ctx, key, dhtKey))
+	if err != nil {
+		return nil, err
 	}
-
+	envelope, err := record.Marshal()
 	if err != nil {
+		return nil, err
+	}
+	written := d.node.Clock.Now()
+	if err := d.node.DHT.PutValue(ctx, dhtKey, envelope); err != nil {
 		logrus.WithFields(logrus.Fields{
+			"key":   key,
 			"error": err,
-		})
-		return false, err
+		}).Error("Failed to store the record in the DHT")
+		return nil, err
+	}
+	if stored, err := d.cache.PutRecord(ctx, key, envelope); err != nil {
+		logrus.Errorf("%v", err)
+	} else if !stored {
+		logrus.Warnf("A newer version of %s was written concurrently, version %s lost", key, record.Version())
+	} else {
+		d.cache.Touch(key)
 	}
+	d.republisher.MarkPublished(ctx, key, written)
+	d.node.DataUpdates.Announce(envelope)
 
-	return true, nil
+	return record, nil
 }
 
-// ReadData reads the value for the given key from the database.
-// It requires the host for access control verification before reading.
-func ReadData(node *masa.OracleNode, key string) []byte {
-	logrus.WithFields(logrus.Fields{
-		"nodeID":       node.Host.ID().String(),
-		"isAuthorized": true,
-		"ReadData":     true,
-	})
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*120)
+// nextSeq returns the sequence number for the next version of a key, one above the
+// current version known locally. The DHT is only asked for keys the node neither wrote
+// nor read. The hybrid logical clock observes the current version, so the new version
+// is timestamped after it.
+func (d *Database) nextSeq(ctx context.Context, key, dhtKey string) uint64 {
+	if record, _ := d.cache.current(ctx, key); record != nil {
+		_ = d.node.HLC.Update(record.Version())
+		return record.Seq + 1
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
+	if stored, err := d.node.DHT.GetValue(lookupCtx, dhtKey); err == nil {
+		if record, err := d.dhtRecord(stored); err == nil {
+			return record.Seq + 1
+		}
+	}
+	return 1
+}
 
-	var err error
-	var val []byte
-
-	if key != node.Host.ID().String() {
-		val, err = node.DHT.GetValue(ctx, "/db/"+key)
-	} else {
-		val, err = node.DHT.GetValue(ctx, "/db/"+node.Host.ID().String())
+// LookupRecord returns the current version of the key in the DHT, including
+// tombstones.
+func (d *Database) LookupRecord(ctx context.Context, key string) (*network.DBRecord, error) {
+	val, err := d.node.DHT.GetValue(ctx, "/db/"+normalizeKey(key))
+	if err != nil {
+		return nil, err
 	}
+	return d.dhtRecord(val)
+}
 
+// dhtRecord decodes a record fetched from the DHT and lets the hybrid logical clock
+// observe its version. The DHT only hands out records that passed the /db validator,
+// so the record is not verified again.
+func (d *Database) dhtRecord(value []byte) (*network.DBRecord, error) {
+	record, err := network.UnmarshalDBRecord(value)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"error": err,
-		}).Error("Failed to read from the database")
-		return nil
+		return nil, err
+	}
+	_ = d.node.HLC.Update(record.Version())
+	return record, nil
+}
+
+// ReadRecord returns the current version of the key, from the cache while it is fresh
+// for the namespace of the key and from the DHT otherwise. Deleted, expired and missing
+// keys are reported as ErrRecordNotFound, lookups that time out as ErrReadTimeout.
+func (d *Database) ReadRecord(key string) (*network.DBRecord, error) {
+	return d.readRecord(context.Background(), key)
+}
+
+func (d *Database) readRecord(ctx context.Context, key string) (*network.DBRecord, error) {
+	key = normalizeKey(key)
+	var ttl time.Duration
+	if ns, _, err := d.node.Namespaces.Lookup(key); err == nil && ns != nil {
+		ttl = ns.FreshFor()
 	}
+	return d.cache.Read(ctx, key, ttl, func(ctx context.Context, key string) (*network.DBRecord, error) {
+		return d.LookupRecord(ctx, key)
+	})
+}
+
+// OpenRecord returns the value of the record, decrypting private records with the
+// node key. Nodes that are not readers of a private record get ErrAccessDenied.
+func (d *Database) OpenRecord(record *network.DBRecord) ([]byte, error) {
+	return record.Open(d.node.KeyManager.Libp2pPrivKey)
+}
 
-	return val
+// ReadData reads the value for the given key from the database, see ReadRecord.
+// Private values are decrypted with the node key.
+func (d *Database) ReadData(key string) ([]byte, error) {
+	record, err := d.ReadRecord(key)
+	if err != nil {
+		if !errors.Is(err, ErrRecordNotFound) {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("Failed to read from the database")
+		}
+		return nil, err
+	}
+	return d.OpenRecord(record)
 }
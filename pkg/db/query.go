@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+)
+
+// MaxQueryLimit caps the number of entries returned by a single page.
+const MaxQueryLimit = 1000
+
+// ErrInvalidQuery is returned for queries with negative bounds or an empty range.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query selects entries of the resolver cache in key order. Keys use the same form as
+// WriteData, e.g. nodestatus/<peer id>.
+type Query struct {
+	// Prefix matches keys starting with the given string, e.g. "twitter/" or "twitter/ma".
+	Prefix string
+	// Start is the inclusive lower bound of the key range.
+	Start string
+	// End is the exclusive upper bound of the key range.
+	End string
+	// Cursor resumes a listing after the given key, pass the NextCursor of the previous page.
+	Cursor string
+	// Offset skips the given number of matching entries.
+	Offset int
+	// Limit is the page size, zero uses config.PageSize.
+	Limit int
+	// KeysOnly leaves the values out of the result.
+	KeysOnly bool
+	// IncludeDeleted also returns tombstones and expired records.
+	IncludeDeleted bool
+}
+
+// Entry is a record of the resolver cache.
+type Entry struct {
+	Key     string
+	Value   []byte
+	Version uint64
+	Expires int64
+	Deleted bool
+	// Private entries hold the encrypted value, see OpenRecord.
+	Private bool
+}
+
+// Page is one page of query results. NextCursor is empty on the last page.
+type Page struct {
+	Entries    []Entry
+	NextCursor string
+}
+
+// Query returns the page of cache entries selected by q.
+func (c *ResolverCache) Query(ctx context.Context, q Query) (*Page, error) {
+	if q.Offset < 0 || q.Limit < 0 {
+		return nil, ErrInvalidQuery
+	}
+	if q.Limit == 0 {
+		q.Limit = config.PageSize
+	}
+	if q.Limit > MaxQueryLimit {
+		q.Limit = MaxQueryLimit
+	}
+	dsq, err := q.datastoreQuery(true, c.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	dsq.Offset = q.Offset
+	dsq.Limit = q.Limit
+
+	results, err := c.store.Query(ctx, dsq)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	page := &Page{Entries: make([]Entry, 0, q.Limit)}
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		entry := Entry{Key: strings.TrimPrefix(result.Key, "/")}
+		if record, err := network.UnmarshalDBRecord(result.Value); err == nil {
+			entry.Version = record.Seq
+			entry.Expires = record.Expires
+			entry.Deleted = !record.Live(c.clock.Now())
+			entry.Private = record.Private
+			if !q.KeysOnly {
+				entry.Value = record.Value
+			}
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	if len(page.Entries) == q.Limit {
+		page.NextCursor = page.Entries[len(page.Entries)-1].Key
+	}
+	return page, nil
+}
+
+// Count returns the number of cache entries matching the prefix and range of q.
+// Cursor, offset and limit are ignored.
+func (c *ResolverCache) Count(ctx context.Context, q Query) (int, error) {
+	q.Cursor = ""
+	dsq, err := q.datastoreQuery(false, c.clock.Now())
+	if err != nil {
+		return 0, err
+	}
+	results, err := c.store.Query(ctx, dsq)
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	count := 0
+	for result := range results.Next() {
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		count++
+	}
+	return count, nil
+}
+
+// datastoreQuery translates q into a datastore query ordered by key. Values are only
+// loaded when they are needed to hide deleted records or when withValues is set,
+// records count as expired from now on.
+func (q Query) datastoreQuery(withValues bool, now time.Time) (query.Query, error) {
+	if q.Start != "" && q.End != "" && q.Start >= q.End {
+		return query.Query{}, ErrInvalidQuery
+	}
+	dsq := query.Query{
+		Orders:   []query.Order{query.OrderByKey{}},
+		KeysOnly: !withValues && q.IncludeDeleted,
+		Filters:  []query.Filter{recordFilter{}},
+	}
+	if prefix := cacheKey(q.Prefix); prefix != "/" {
+		// the datastore matches whole path segments, the remainder is matched by a filter
+		if strings.HasSuffix(q.Prefix, "/") {
+			dsq.Prefix = prefix
+			prefix += "/"
+		} else if i := strings.LastIndex(prefix, "/"); i > 0 {
+			dsq.Prefix = prefix[:i]
+		}
+		dsq.Filters = append(dsq.Filters, query.FilterKeyPrefix{Prefix: prefix})
+	}
+	if q.Start != "" {
+		dsq.Filters = append(dsq.Filters, query.FilterKeyCompare{Op: query.GreaterThanOrEqual, Key: cacheKey(q.Start)})
+	}
+	if q.End != "" {
+		dsq.Filters = append(dsq.Filters, query.FilterKeyCompare{Op: query.LessThan, Key: cacheKey(q.End)})
+	}
+	if q.Cursor != "" {
+		dsq.Filters = append(dsq.Filters, query.FilterKeyCompare{Op: query.GreaterThan, Key: cacheKey(q.Cursor)})
+	}
+	if !q.IncludeDeleted {
+		dsq.Filters = append(dsq.Filters, liveFilter{now: now})
+	}
+	return dsq, nil
+}
+
+// cacheKey returns the datastore form of a key, matching the keys written by PutCache.
+func cacheKey(key string) string {
+	return ds.NewKey(normalizeKey(key)).String()
+}
+
+// liveFilter drops tombstones and records expired at now.
+type liveFilter struct {
+	now time.Time
+}
+
+func (f liveFilter) Filter(e query.Entry) bool {
+	record, err := network.UnmarshalDBRecord(e.Value)
+	return err == nil && record.Live(f.now)
+}
+
+func (liveFilter) String() string {
+	return "live records"
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/storage"
+)
+
+func useTestCache(t *testing.T, clk clock.Clock) *ResolverCache {
+	store, err := storage.Open(storage.BackendMemory, "")
+	require.NoError(t, err)
+	return NewResolverCache(store, clk)
+}
+
+func putTestRecord(t *testing.T, c *ResolverCache, hlc *clock.HLC, privKey crypto.PrivKey, key string, ttl time.Duration) {
+	record, err := network.NewDBRecord(hlc, privKey, "/db/"+key, []byte(`"`+key+`"`), 1, ttl)
+	require.NoError(t, err)
+	data, err := record.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, c.Put(context.Background(), key, data))
+}
+
+func keysOf(page *Page) []string {
+	keys := make([]string, len(page.Entries))
+	for i, entry := range page.Entries {
+		keys[i] = entry.Key
+	}
+	return keys
+}
+
+func TestQueryCache(t *testing.T) {
+	mock := clock.NewMock()
+	hlc := clock.NewHLC(mock)
+	c := useTestCache(t, mock)
+	ctx := context.Background()
+
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		putTestRecord(t, c, hlc, privKey, fmt.Sprintf("twitter/q%d", i), 0)
+	}
+	putTestRecord(t, c, hlc, privKey, "twitterbot", 0)
+	putTestRecord(t, c, hlc, privKey, "nodestatus/a", 0)
+	putTestRecord(t, c, hlc, privKey, "twitter/expiring", time.Minute)
+	mock.Add(time.Minute)
+
+	page, err := c.Query(ctx, Query{Prefix: "twitter/", Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"twitter/q0", "twitter/q1"}, keysOf(page))
+	assert.Equal(t, []byte(`"twitter/q0"`), page.Entries[0].Value)
+	assert.Equal(t, "twitter/q1", page.NextCursor)
+
+	page, err = c.Query(ctx, Query{Prefix: "twitter/", Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"twitter/q2", "twitter/q3"}, keysOf(page))
+
+	page, err = c.Query(ctx, Query{Prefix: "twitter/", Offset: 4})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"twitter/q4"}, keysOf(page))
+	assert.Empty(t, page.NextCursor)
+
+	page, err = c.Query(ctx, Query{Prefix: "twitter", KeysOnly: true})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 6)
+	assert.Nil(t, page.Entries[0].Value)
+
+	page, err = c.Query(ctx, Query{Start: "twitter/q1", End: "twitter/q3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"twitter/q1", "twitter/q2"}, keysOf(page))
+
+	count, err := c.Count(ctx, Query{Prefix: "twitter/"})
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	count, err = c.Count(ctx, Query{Prefix: "twitter/", IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, 6, count)
+
+	// the bookkeeping entries of the cache are not records, at any depth
+	require.NoError(t, c.Put(ctx, "/.health", []byte("ok")))
+	require.NoError(t, c.Put(ctx, "/.quotas/read/a", []byte("{}")))
+	count, err = c.Count(ctx, Query{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, 8, count)
+	records, err := c.All(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 8)
+
+	_, err = c.Query(ctx, Query{Start: "b", End: "a"})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
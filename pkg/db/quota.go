@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"path"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+const quotasPrefix = "/.quotas"
+
+var ErrQuotaExceeded = errors.New("daily quota exceeded")
+
+// quotaUsage is the number of requests a client sent to a route group on a day.
+type quotaUsage struct {
+	Day  string `json:"day"`
+	Used int    `json:"used"`
+}
+
+// UseQuota counts a request of a client against its daily quota for a route group of
+// the API, and returns ErrQuotaExceeded once the quota is used up. Days start at
+// midnight UTC. The usage is kept in the cache so quotas survive restarts, a quota of
+// zero allows every request.
+func (c *ResolverCache) UseQuota(ctx context.Context, group, client string, quota int) error {
+	if quota <= 0 {
+		return nil
+	}
+	key := ds.NewKey(path.Join(quotasPrefix, url.PathEscape(group), url.PathEscape(client)))
+	usage := quotaUsage{Day: c.clock.Now().UTC().Format(time.DateOnly)}
+
+	c.quotas.Lock()
+	defer c.quotas.Unlock()
+	data, err := c.store.Get(ctx, key)
+	switch {
+	case err == nil:
+		// the count restarts with every day
+		var stored quotaUsage
+		if json.Unmarshal(data, &stored) == nil && stored.Day == usage.Day {
+			usage = stored
+		}
+	case !errors.Is(err, ds.ErrNotFound):
+		return err
+	}
+	if usage.Used >= quota {
+		return ErrQuotaExceeded
+	}
+	usage.Used++
+	data, err = json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	return c.store.Put(ctx, key, data)
+}
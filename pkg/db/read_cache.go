@@ -0,0 +1,239 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	gosync "sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/network"
+)
+
+const (
+	// DefaultReadTTL is how long a record read from the DHT is served from the cache
+	// when its namespace has no cacheTTL.
+	DefaultReadTTL = time.Minute
+	// NegativeReadTTL is how long a key that was not found in the DHT is reported as
+	// missing without looking it up again.
+	NegativeReadTTL = time.Second * 30
+	// ReadTimeout bounds a lookup in the DHT.
+	ReadTimeout = time.Second * 120
+	// MaxReadMisses bounds the number of missing keys remembered.
+	MaxReadMisses = 4096
+	// MaxReadEntries bounds the number of records read from the DHT that are kept, the
+	// least recently fetched one makes room for a new one.
+	MaxReadEntries = 4096
+)
+
+// ErrReadTimeout is returned when the DHT did not answer a read in time.
+var ErrReadTimeout = errors.New("read timed out")
+
+// LookupFunc looks up the current version of a key in the DHT. It returns
+// routing.ErrNotFound for keys that were never written.
+type LookupFunc func(ctx context.Context, key string) (*network.DBRecord, error)
+
+// readEntry is the last version of a key fetched from the DHT. The record is nil for
+// keys the node wrote, their version is in the datastore.
+type readEntry struct {
+	record  *network.DBRecord
+	fetched time.Time
+}
+
+// readState holds the records read from the DHT, the keys recently found missing and
+// the keys being revalidated. It is kept in memory apart from the datastore, so the
+// records of other writers are neither persisted nor republished by the node.
+type readState struct {
+	mu         gosync.Mutex
+	entries    map[string]readEntry
+	misses     map[string]time.Time
+	refreshing map[string]struct{}
+}
+
+func newReadState() *readState {
+	return &readState{
+		entries:    make(map[string]readEntry),
+		misses:     make(map[string]time.Time),
+		refreshing: make(map[string]struct{}),
+	}
+}
+
+// Read returns the current version of the key, reading through the cache. The current
+// version is the newer of the record the node wrote and the one last fetched from the
+// DHT. A record fetched less than ttl ago is served from the cache. A stale record is
+// served as well, while it is revalidated against the DHT in the background. Keys found
+// missing are remembered for NegativeReadTTL. Deleted, expired and missing keys are
+// reported as ErrRecordNotFound, lookups that take longer than ReadTimeout as
+// ErrReadTimeout.
+func (c *ResolverCache) Read(ctx context.Context, key string, ttl time.Duration, lookup LookupFunc) (*network.DBRecord, error) {
+	if ttl <= 0 {
+		ttl = DefaultReadTTL
+	}
+	now := c.clock.Now()
+	if record, fetched := c.current(ctx, key); record != nil {
+		if now.Sub(fetched) >= ttl {
+			c.revalidate(key, lookup)
+		}
+		return c.liveRecord(record, nil)
+	}
+	if c.missing(key, now) {
+		return nil, ErrRecordNotFound
+	}
+
+	record, err := fetch(ctx, key, lookup)
+	if errors.Is(err, ErrRecordNotFound) {
+		c.addMiss(key, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.liveRecord(c.keep(ctx, key, record), nil)
+}
+
+// Touch records that the cached version of the key is current, such as after the
+// node wrote it, and forgets that it was missing.
+func (c *ResolverCache) Touch(key string) {
+	c.reads.mu.Lock()
+	defer c.reads.mu.Unlock()
+	entry := c.reads.entries[key]
+	entry.fetched = c.clock.Now()
+	c.reads.put(key, entry)
+	delete(c.reads.misses, key)
+}
+
+// current returns the newer of the record the node wrote and the record last fetched
+// from the DHT, with the time the key was last found current. It returns nil when the
+// key is not cached.
+func (c *ResolverCache) current(ctx context.Context, key string) (*network.DBRecord, time.Time) {
+	c.reads.mu.Lock()
+	entry := c.reads.entries[key]
+	c.reads.mu.Unlock()
+	record := entry.record
+	if stored, err := c.Get(ctx, key); err == nil {
+		if own, err := network.UnmarshalDBRecord(stored); err == nil && (record == nil || own.NewerThan(record)) {
+			record = own
+		}
+	}
+	return record, entry.fetched
+}
+
+// put stores the entry of the key, evicting the least recently fetched entry when the
+// state holds MaxReadEntries. The caller holds mu.
+func (s *readState) put(key string, entry readEntry) {
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= MaxReadEntries {
+		var oldest string
+		for k, e := range s.entries {
+			if oldest == "" || e.fetched.Before(s.entries[oldest].fetched) {
+				oldest = k
+			}
+		}
+		delete(s.entries, oldest)
+	}
+	s.entries[key] = entry
+}
+
+func (c *ResolverCache) missing(key string, now time.Time) bool {
+	c.reads.mu.Lock()
+	defer c.reads.mu.Unlock()
+	at, ok := c.reads.misses[key]
+	if ok && now.Sub(at) >= NegativeReadTTL {
+		delete(c.reads.misses, key)
+		return false
+	}
+	return ok
+}
+
+func (c *ResolverCache) addMiss(key string, now time.Time) {
+	c.reads.mu.Lock()
+	defer c.reads.mu.Unlock()
+	if len(c.reads.misses) >= MaxReadMisses {
+		for k, at := range c.reads.misses {
+			if now.Sub(at) >= NegativeReadTTL {
+				delete(c.reads.misses, k)
+			}
+		}
+		// still full of recent misses, make room for the new one
+		for k := range c.reads.misses {
+			if len(c.reads.misses) < MaxReadMisses {
+				break
+			}
+			delete(c.reads.misses, k)
+		}
+	}
+	c.reads.misses[key] = now
+}
+
+// revalidate fetches the key from the DHT in the background, once at a time per key.
+func (c *ResolverCache) revalidate(key string, lookup LookupFunc) {
+	c.reads.mu.Lock()
+	if _, ok := c.reads.refreshing[key]; ok {
+		c.reads.mu.Unlock()
+		return
+	}
+	c.reads.refreshing[key] = struct{}{}
+	c.reads.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.reads.mu.Lock()
+			delete(c.reads.refreshing, key)
+			c.reads.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), ReadTimeout)
+		defer cancel()
+		record, err := fetch(ctx, key, lookup)
+		switch {
+		case err == nil:
+			_ = c.keep(ctx, key, record)
+		case errors.Is(err, ErrRecordNotFound):
+			// the DHT lost the record, the cached copy stays current until its
+			// writer republishes it
+			c.Touch(key)
+		default:
+			logrus.Debugf("Failed to revalidate %s: %v", key, err)
+		}
+	}()
+}
+
+// keep remembers a record fetched from the DHT and returns the current version, which
+// is the one the node wrote when it is newer.
+func (c *ResolverCache) keep(ctx context.Context, key string, record *network.DBRecord) *network.DBRecord {
+	c.reads.mu.Lock()
+	c.reads.put(key, readEntry{record: record, fetched: c.clock.Now()})
+	delete(c.reads.misses, key)
+	c.reads.mu.Unlock()
+	current, _ := c.current(ctx, key)
+	return current
+}
+
+// fetch looks the key up and maps the errors of the DHT to ErrRecordNotFound and
+// ErrReadTimeout.
+func fetch(ctx context.Context, key string, lookup LookupFunc) (*network.DBRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeout)
+	defer cancel()
+	record, err := lookup(ctx, key)
+	switch {
+	case err == nil:
+		return record, nil
+	case errors.Is(err, routing.ErrNotFound):
+		return nil, ErrRecordNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, fmt.Errorf("%w: %s", ErrReadTimeout, key)
+	default:
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+}
+
+// liveRecord reports deleted and expired records as ErrRecordNotFound.
+func (c *ResolverCache) liveRecord(record *network.DBRecord, err error) (*network.DBRecord, error) {
+	if err != nil {
+		return nil, err
+	}
+	if !record.Live(c.clock.Now()) {
+		return nil, ErrRecordNotFound
+	}
+	return record, nil
+}
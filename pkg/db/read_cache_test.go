@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+)
+
+func TestReadThroughCache(t *testing.T) {
+	mock := clock.NewMock()
+	hlc := clock.NewHLC(mock)
+	c := useTestCache(t, mock)
+	ctx := context.Background()
+
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	first, err := network.UnmarshalDBRecord(signedRecord(t, hlc, privKey, "k", `"first"`, 1))
+	require.NoError(t, err)
+	mock.Add(time.Second)
+	second, err := network.UnmarshalDBRecord(signedRecord(t, hlc, privKey, "k", `"second"`, 2))
+	require.NoError(t, err)
+
+	var lookups atomic.Int32
+	var current atomic.Pointer[network.DBRecord]
+	current.Store(first)
+	lookup := func(context.Context, string) (*network.DBRecord, error) {
+		lookups.Add(1)
+		if record := current.Load(); record != nil {
+			return record, nil
+		}
+		return nil, routing.ErrNotFound
+	}
+
+	// the first read goes to the DHT, the following ones are served while fresh
+	record, err := c.Read(ctx, "k", time.Minute, lookup)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), record.Seq)
+	current.Store(second)
+	record, err = c.Read(ctx, "k", time.Minute, lookup)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), record.Seq)
+	assert.Equal(t, int32(1), lookups.Load())
+	// records read from the DHT stay out of the datastore, so they are not republished
+	_, err = c.Get(ctx, "k")
+	assert.ErrorIs(t, err, ds.ErrNotFound)
+
+	// a stale record is served while it is revalidated in the background
+	mock.Add(time.Minute)
+	record, err = c.Read(ctx, "k", time.Minute, lookup)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), record.Seq)
+	require.Eventually(t, func() bool {
+		record, err := c.Read(ctx, "k", time.Minute, lookup)
+		return err == nil && record.Seq == 2
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, int32(2), lookups.Load())
+
+	// missing keys are remembered for a while
+	current.Store(nil)
+	_, err = c.Read(ctx, "missing", time.Minute, lookup)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+	_, err = c.Read(ctx, "missing", time.Minute, lookup)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+	assert.Equal(t, int32(3), lookups.Load())
+	mock.Add(NegativeReadTTL)
+	_, err = c.Read(ctx, "missing", time.Minute, lookup)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+	assert.Equal(t, int32(4), lookups.Load())
+
+	// timeouts and failures are told apart from missing keys and not remembered
+	_, err = c.Read(ctx, "slow", time.Minute, func(context.Context, string) (*network.DBRecord, error) {
+		return nil, context.DeadlineExceeded
+	})
+	assert.ErrorIs(t, err, ErrReadTimeout)
+	broken := errors.New("broken")
+	_, err = c.Read(ctx, "slow", time.Minute, func(context.Context, string) (*network.DBRecord, error) {
+		return nil, broken
+	})
+	assert.ErrorIs(t, err, broken)
+	assert.NotErrorIs(t, err, ErrRecordNotFound)
+
+	// deleted keys are cached like other versions
+	tombstone, err := network.NewDBTombstone(hlc, privKey, "/db/k", 3)
+	require.NoError(t, err)
+	envelope, err := tombstone.Marshal()
+	require.NoError(t, err)
+	_, err = c.PutRecord(ctx, "k", envelope)
+	require.NoError(t, err)
+	c.Touch("k")
+	_, err = c.Read(ctx, "k", time.Minute, lookup)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestReadThroughCacheBound(t *testing.T) {
+	mock := clock.NewMock()
+	hlc := clock.NewHLC(mock)
+	c := useTestCache(t, mock)
+	ctx := context.Background()
+
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, -1)
+	require.NoError(t, err)
+	record, err := network.UnmarshalDBRecord(signedRecord(t, hlc, privKey, "k", `"v"`, 1))
+	require.NoError(t, err)
+	var lookups atomic.Int32
+	lookup := func(context.Context, string) (*network.DBRecord, error) {
+		lookups.Add(1)
+		return record, nil
+	}
+
+	for i := 0; i <= MaxReadEntries; i++ {
+		_, err := c.Read(ctx, strconv.Itoa(i), time.Minute, lookup)
+		require.NoError(t, err)
+		if i == 0 {
+			mock.Add(time.Second)
+		}
+	}
+	assert.Len(t, c.reads.entries, MaxReadEntries)
+
+	// the least recently fetched record made room and is fetched again
+	_, err = c.Read(ctx, strconv.Itoa(MaxReadEntries), time.Minute, lookup)
+	require.NoError(t, err)
+	assert.Equal(t, int32(MaxReadEntries+1), lookups.Load())
+	_, err = c.Read(ctx, "0", time.Minute, lookup)
+	require.NoError(t, err)
+	assert.Equal(t, int32(MaxReadEntries+2), lookups.Load())
+}
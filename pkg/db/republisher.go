@@ -0,0 +1,397 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"sync/atomic"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/network"
+)
+
+const (
+	// RepublishInterval is how often the republisher looks for records to publish.
+	RepublishInterval = time.Second * 60
+	// RepublishAfter is the age after which a published record is stored again, well
+	// before the DHT drops it after 48 hours.
+	RepublishAfter = time.Hour * 22
+	// RepublishConcurrency bounds the number of concurrent DHT puts.
+	RepublishConcurrency = 8
+	// RepublishTimeout bounds a single DHT put.
+	RepublishTimeout = time.Second * 30
+
+	// changeLogPrefix holds the change log next to the records in the cache. Keys under
+	// it can not collide with records because namespace names may not start with a dot.
+	changeLogPrefix = "/.changelog"
+	// reservedPrefix starts the keys of the cache that are not records.
+	reservedPrefix     = "/."
+	changeLogDirty     = changeLogPrefix + "/dirty"
+	changeLogPublished = changeLogPrefix + "/published"
+)
+
+// PutFunc stores a signed record in the DHT.
+type PutFunc func(ctx context.Context, dhtKey string, value []byte) error
+
+// GetFunc returns the current record of a key from the DHT.
+type GetFunc func(ctx context.Context, dhtKey string) ([]byte, error)
+
+// RepublishStats reports the progress of the republisher.
+type RepublishStats struct {
+	// Tracked is the number of records in the change log.
+	Tracked int
+	// Pending is the number of new or changed records that were not published yet.
+	Pending int
+	// Lag is the age of the oldest change that was not published yet.
+	Lag time.Duration
+	// Running reports whether a run is in progress, RunDone of RunTotal records are done.
+	Running  bool
+	RunTotal int
+	RunDone  int
+
+	LastRun          time.Time
+	LastRunDuration  time.Duration
+	LastRunPublished int
+	LastRunFailed    int
+	TotalPublished   uint64
+	TotalFailed      uint64
+}
+
+// Republisher keeps the records of the cache stored in the DHT. It keeps a change log
+// of dirty keys, so every run only publishes new or changed records and records whose
+// last publication is about to expire, instead of the whole cache. When Get is set, the
+// version in the DHT is checked first, and a newer one written by another node
+// replaces the cached record instead of being overwritten by it.
+type Republisher struct {
+	cache       *ResolverCache
+	Put         PutFunc
+	Get         GetFunc
+	Concurrency int
+	After       time.Duration
+
+	mu        gosync.Mutex
+	dirty     map[string]time.Time
+	published map[string]time.Time
+	stats     RepublishStats
+	// adopted counts the newer versions found in the DHT, see ConflictStats
+	adopted atomic.Uint64
+}
+
+// NewRepublisher creates a republisher that stores the records of cache with put.
+func NewRepublisher(cache *ResolverCache, put PutFunc) *Republisher {
+	return &Republisher{
+		cache:       cache,
+		Put:         put,
+		Concurrency: RepublishConcurrency,
+		After:       RepublishAfter,
+		dirty:       make(map[string]time.Time),
+		published:   make(map[string]time.Time),
+	}
+}
+
+// Load restores the change log from the cache. Records without a known publication
+// time are marked dirty, so they are published on the next run.
+func (r *Republisher) Load(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loaded := make(map[string]time.Time)
+	for _, prefix := range []string{changeLogDirty, changeLogPublished} {
+		results, err := r.cache.store.Query(ctx, query.Query{Prefix: prefix})
+		if err != nil {
+			return err
+		}
+		for result := range results.Next() {
+			if result.Error != nil {
+				results.Close()
+				return result.Error
+			}
+			key := strings.TrimPrefix(result.Key, prefix+"/")
+			nanos, _ := strconv.ParseInt(string(result.Value), 10, 64)
+			if prefix == changeLogDirty {
+				r.dirty[key] = time.Unix(0, nanos)
+			} else {
+				loaded[key] = time.Unix(0, nanos)
+			}
+		}
+		results.Close()
+	}
+
+	results, err := r.cache.store.Query(ctx, query.Query{KeysOnly: true, Filters: []query.Filter{recordFilter{}}})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+	now := r.cache.clock.Now()
+	for result := range results.Next() {
+		if result.Error != nil {
+			return result.Error
+		}
+		key := strings.TrimPrefix(result.Key, "/")
+		if _, ok := r.dirty[key]; ok {
+			continue
+		}
+		if at, ok := loaded[key]; ok {
+			r.published[key] = at
+		} else {
+			r.dirty[key] = now
+		}
+	}
+	return nil
+}
+
+// MarkDirty records that the key changed and has to be published.
+func (r *Republisher) MarkDirty(ctx context.Context, key string) {
+	now := r.cache.clock.Now()
+	r.mu.Lock()
+	if _, ok := r.dirty[key]; !ok {
+		r.dirty[key] = now
+	}
+	at := r.dirty[key]
+	delete(r.published, key)
+	r.mu.Unlock()
+
+	r.persist(ctx, changeLogDirty, key, at)
+	_ = r.cache.store.Delete(ctx, changeLogKey(changeLogPublished, key))
+}
+
+// MarkPublished records that the version of the key read at the given time was stored
+// in the DHT. Changes made after that time keep the key dirty.
+func (r *Republisher) MarkPublished(ctx context.Context, key string, at time.Time) {
+	r.mu.Lock()
+	if changed, ok := r.dirty[key]; ok && changed.After(at) {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.dirty, key)
+	r.published[key] = at
+	r.mu.Unlock()
+
+	r.persist(ctx, changeLogPublished, key, at)
+	_ = r.cache.store.Delete(ctx, changeLogKey(changeLogDirty, key))
+}
+
+// Forget removes the key from the change log.
+func (r *Republisher) Forget(ctx context.Context, key string) {
+	r.mu.Lock()
+	delete(r.dirty, key)
+	delete(r.published, key)
+	r.mu.Unlock()
+
+	_ = r.cache.store.Delete(ctx, changeLogKey(changeLogDirty, key))
+	_ = r.cache.store.Delete(ctx, changeLogKey(changeLogPublished, key))
+}
+
+func (r *Republisher) persist(ctx context.Context, prefix, key string, at time.Time) {
+	if err := r.cache.store.Put(ctx, changeLogKey(prefix, key), []byte(strconv.FormatInt(at.UnixNano(), 10))); err != nil {
+		logrus.Warnf("Failed to update the change log for %s: %v", key, err)
+	}
+}
+
+// due returns the dirty keys, oldest change first, followed by the published keys
+// that have to be refreshed.
+func (r *Republisher) due(now time.Time) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]string, 0, len(r.dirty))
+	for key := range r.dirty {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return r.dirty[keys[i]].Before(r.dirty[keys[j]]) })
+
+	stale := make([]string, 0)
+	for key, at := range r.published {
+		if now.Sub(at) >= r.After {
+			stale = append(stale, key)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return r.published[stale[i]].Before(r.published[stale[j]]) })
+	return append(keys, stale...)
+}
+
+// Run republishes due records every interval until ctx is done.
+func (r *Republisher) Run(ctx context.Context, interval time.Duration) {
+	ticker := r.cache.clock.Ticker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.RepublishOnce(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RepublishOnce publishes the due records with bounded concurrency. Records that fail
+// stay dirty and are retried on the next run, expired records are dropped from the cache.
+func (r *Republisher) RepublishOnce(ctx context.Context) {
+	start := r.cache.clock.Now()
+	keys := r.due(start)
+
+	r.mu.Lock()
+	r.stats.Running = true
+	r.stats.RunTotal = len(keys)
+	r.stats.RunDone = 0
+	r.mu.Unlock()
+
+	concurrency := r.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg gosync.WaitGroup
+	var published, failed int
+	var countMu gosync.Mutex
+
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(key string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ok := r.publish(ctx, key)
+			countMu.Lock()
+			if ok {
+				published++
+			} else {
+				failed++
+			}
+			countMu.Unlock()
+			r.mu.Lock()
+			r.stats.RunDone++
+			r.mu.Unlock()
+		}(key)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Running = false
+	r.stats.LastRun = start
+	r.stats.LastRunDuration = r.cache.clock.Since(start)
+	r.stats.LastRunPublished = published
+	r.stats.LastRunFailed = failed
+	r.stats.TotalPublished += uint64(published)
+	r.stats.TotalFailed += uint64(failed)
+	if len(keys) > 0 {
+		logrus.Infof("Republished %d records, %d failed, in %s", published, failed, r.stats.LastRunDuration)
+	}
+}
+
+// publish stores the cached record of key in the DHT and reports whether it succeeded.
+// Records that expired or vanished from the cache count as done.
+func (r *Republisher) publish(ctx context.Context, key string) bool {
+	read := r.cache.clock.Now()
+	value, err := r.cache.Get(ctx, key)
+	if errors.Is(err, ds.ErrNotFound) {
+		r.Forget(ctx, key)
+		return true
+	}
+	if err != nil {
+		logrus.Warnf("Failed to read %s from the cache: %v", key, err)
+		return false
+	}
+	// republish the signed record as it is, re-signing would bump its version
+	signed, err := network.UnmarshalDBRecord(value)
+	if err != nil {
+		logrus.Warnf("Skipping unsigned cache entry %s: %v", key, err)
+		r.Forget(ctx, key)
+		return true
+	}
+	// tombstones are republished until they expire so deletions reach every replica
+	if signed.Expired(r.cache.clock.Now()) {
+		_ = r.cache.Delete(ctx, key)
+		r.Forget(ctx, key)
+		return true
+	}
+
+	putCtx, cancel := context.WithTimeout(ctx, RepublishTimeout)
+	defer cancel()
+	if r.adoptNewer(putCtx, key, signed) {
+		r.MarkPublished(ctx, key, read)
+		return true
+	}
+	if err := r.Put(putCtx, signed.Key, value); err != nil {
+		logrus.Warnf("Failed to republish %s: %v", signed.Key, err)
+		r.MarkDirty(ctx, key)
+		return false
+	}
+	r.MarkPublished(ctx, key, read)
+	return true
+}
+
+// adoptNewer looks up the version of the key in the DHT and stores it in the cache if
+// it is newer than the cached record under last-writer-wins.
+func (r *Republisher) adoptNewer(ctx context.Context, key string, cached *network.DBRecord) bool {
+	if r.Get == nil {
+		return false
+	}
+	value, err := r.Get(ctx, cached.Key)
+	if err != nil {
+		return false
+	}
+	current, err := network.UnmarshalDBRecord(value)
+	if err != nil || !current.NewerThan(cached) {
+		return false
+	}
+	stored, err := r.cache.PutRecord(ctx, key, value)
+	if err != nil {
+		logrus.Warnf("Failed to store the newer version of %s: %v", key, err)
+		return false
+	}
+	if stored {
+		r.adopted.Add(1)
+		logrus.Infof("Adopted version %s of %s written by %s", current.Version(), key, current.Writer)
+	}
+	return true
+}
+
+// Stats returns the progress of the current run and the totals of past runs.
+func (r *Republisher) Stats() RepublishStats {
+	now := r.cache.clock.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.stats
+	stats.Tracked = len(r.dirty) + len(r.published)
+	stats.Pending = len(r.dirty)
+	for _, at := range r.dirty {
+		if lag := now.Sub(at); lag > stats.Lag {
+			stats.Lag = lag
+		}
+	}
+	return stats
+}
+
+// IsChangeLogKey reports whether the key of the cache belongs to the change log of
+// the republisher rather than to a record.
+func IsChangeLogKey(key string) bool {
+	return strings.HasPrefix(key, changeLogPrefix+"/")
+}
+
+func changeLogKey(prefix, key string) ds.Key {
+	return ds.NewKey(prefix + "/" + key)
+}
+
+// recordFilter hides the change log and other bookkeeping from queries over the records.
+type recordFilter struct{}
+
+func (recordFilter) Filter(e query.Entry) bool {
+	return !strings.HasPrefix(e.Key, reservedPrefix)
+}
+
+func (recordFilter) String() string {
+	return "records"
+}
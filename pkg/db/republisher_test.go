@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+// fakeDHT records the puts of the republisher and fails the keys in failing.
+type fakeDHT struct {
+	mu      gosync.Mutex
+	puts    []string
+	failing map[string]bool
+}
+
+func (f *fakeDHT) put(_ context.Context, dhtKey string, _ []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing[dhtKey] {
+		return errors.New("no peers")
+	}
+	f.puts = append(f.puts, dhtKey)
+	return nil
+}
+
+func (f *fakeDHT) take() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	puts := f.puts
+	f.puts = nil
+	return puts
+}
+
+func TestRepublisher(t *testing.T) {
+	mock := clock.NewMock()
+	hlc := clock.NewHLC(mock)
+	c := useTestCache(t, mock)
+	ctx := context.Background()
+
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		putTestRecord(t, c, hlc, privKey, fmt.Sprintf("k%d", i), 0)
+	}
+	putTestRecord(t, c, hlc, privKey, "short", time.Hour)
+
+	dht := &fakeDHT{failing: map[string]bool{"/db/k2": true}}
+	r := NewRepublisher(c, dht.put)
+	require.NoError(t, r.Load(ctx))
+	assert.Equal(t, 4, r.Stats().Pending)
+
+	// the first run publishes every record that was never published
+	r.RepublishOnce(ctx)
+	assert.ElementsMatch(t, []string{"/db/k0", "/db/k1", "/db/short"}, dht.take())
+	stats := r.Stats()
+	assert.Equal(t, 1, stats.Pending)
+	assert.Equal(t, 1, stats.LastRunFailed)
+	assert.Equal(t, 4, stats.Tracked)
+
+	// unchanged records are skipped, failed ones are retried
+	delete(dht.failing, "/db/k2")
+	mock.Add(time.Minute)
+	assert.Equal(t, time.Minute, r.Stats().Lag)
+	r.RepublishOnce(ctx)
+	assert.Equal(t, []string{"/db/k2"}, dht.take())
+	assert.Zero(t, r.Stats().Pending)
+
+	r.MarkDirty(ctx, "k1")
+	r.RepublishOnce(ctx)
+	assert.Equal(t, []string{"/db/k1"}, dht.take())
+
+	// the change log survives a restart
+	restarted := NewRepublisher(c, dht.put)
+	require.NoError(t, restarted.Load(ctx))
+	assert.Zero(t, restarted.Stats().Pending)
+	restarted.RepublishOnce(ctx)
+	assert.Empty(t, dht.take())
+
+	// records nearing expiry in the DHT are refreshed, expired ones are dropped
+	mock.Add(RepublishAfter)
+	restarted.RepublishOnce(ctx)
+	assert.ElementsMatch(t, []string{"/db/k0", "/db/k1", "/db/k2"}, dht.take())
+	_, err = c.Get(ctx, "short")
+	assert.Error(t, err)
+	assert.Equal(t, 3, restarted.Stats().Tracked)
+
+	page, err := c.Query(ctx, Query{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k0", "k1", "k2"}, keysOf(page))
+}
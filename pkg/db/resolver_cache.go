@@ -4,139 +4,124 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/masa-finance/masa-oracle/pkg/consensus"
-	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
 	"github.com/masa-finance/masa-oracle/pkg/nodestatus"
-	"log"
+	gosync "sync"
+	"sync/atomic"
 	"time"
 
-	masa "github.com/masa-finance/masa-oracle/pkg"
-
 	ds "github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/query"
-	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/masa-finance/masa-oracle/pkg/clock"
 	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/storage"
 	"github.com/sirupsen/logrus"
 )
 
-var cache ds.Datastore
-var nodeStatusCh = make(chan []byte)
+// healthKey is read by the health check to make sure the datastore answers.
+var healthKey = ds.NewKey("/.health")
 
 type Record struct {
 	Key   string
 	Value []byte
 }
 
-func InitResolverCache(node *masa.OracleNode, keyManager *masacrypto.KeyManager) {
-	var err error
-	cachePath := config.GetInstance().CachePath
-	cache, err = leveldb.NewDatastore(cachePath, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println("ResolverCache initialized")
+// ResolverCache is the node's local copy of the records it writes. It can be backed by
+// any of the datastores of the storage package.
+type ResolverCache struct {
+	store ds.Batching
+	// mu serializes the comparisons of PutRecord with the writes they guard
+	mu    gosync.Mutex
+	reads *readState
+	// quotas serializes the updates of the API quota counters
+	quotas gosync.Mutex
+	closed atomic.Bool
+	// staleWrites counts the versions rejected for a newer one, see ConflictStats
+	staleWrites atomic.Uint64
+	// clock decides the freshness of reads, the expiry of records and the quota days
+	clock clock.Clock
+}
+
+// NewResolverCache creates a cache on top of the given datastore that reads the time
+// from the node clock clk.
+func NewResolverCache(store ds.Batching, clk clock.Clock) *ResolverCache {
+	return &ResolverCache{store: store, reads: newReadState(), clock: clk}
+}
 
-	data := []byte(node.Host.ID().String())
-	signature, err := consensus.SignData(keyManager.Libp2pPrivKey, data)
+// OpenResolverCache opens the cache with the given storage backend at path.
+func OpenResolverCache(backend, path string, clk clock.Clock) (*ResolverCache, error) {
+	store, err := storage.Open(backend, path)
 	if err != nil {
-		logrus.Errorf("%v", err)
+		return nil, err
 	}
-	_ = Verifier(node.Host, data, signature)
-
-	go monitorNodeData(context.Background(), node)
+	return NewResolverCache(store, clk), nil
+}
 
-	if !isAuthorized(node.Host.ID().String()) {
-		logrus.WithFields(logrus.Fields{
-			"nodeID":       node.Host.ID().String(),
-			"isAuthorized": false,
-			"Sync":         true,
-		})
-		return
-	} else {
-		syncInterval := time.Second * 60 // Change as needed
-		go sync(context.Background(), node, syncInterval)
-	}
+// Datastore returns the datastore backing the cache.
+func (c *ResolverCache) Datastore() ds.Batching {
+	return c.store
+}
 
+// Close closes the datastore backing the cache.
+func (c *ResolverCache) Close() error {
+	c.closed.Store(true)
+	return c.store.Close()
 }
 
-func PutCache(ctx context.Context, keyStr string, value []byte) (any, error) {
-	// key, _ := stringToCid(keyStr)
-	err := cache.Put(ctx, ds.NewKey(keyStr), value)
-	if err != nil {
-		return nil, err
+// Check is the health check of the cache, it fails once the cache is closed or when
+// the datastore does not answer.
+func (c *ResolverCache) Check(ctx context.Context) (string, error) {
+	if c.closed.Load() {
+		return "", fmt.Errorf("the cache is closed")
+	}
+	if _, err := c.store.Has(ctx, healthKey); err != nil {
+		return "", fmt.Errorf("the datastore is not available: %w", err)
 	}
-	return keyStr, nil
+	return "open", nil
 }
 
-func GetCache(ctx context.Context, keyStr string) ([]byte, error) {
-	value, err := cache.Get(ctx, ds.NewKey(keyStr))
-	if err != nil {
-		return nil, err
-	}
-	return value, nil
+func (c *ResolverCache) Put(ctx context.Context, keyStr string, value []byte) error {
+	return c.store.Put(ctx, ds.NewKey(keyStr), value)
 }
 
-func DelCache(ctx context.Context, keyStr string) bool {
-	var err error
-	key := ds.NewKey(keyStr)
-	err = cache.Delete(ctx, key)
-	if err != nil {
-		return false
+func (c *ResolverCache) Get(ctx context.Context, keyStr string) ([]byte, error) {
+	return c.store.Get(ctx, ds.NewKey(keyStr))
+}
+
+// Delete removes the key and its version history.
+func (c *ResolverCache) Delete(ctx context.Context, keyStr string) error {
+	entries, err := c.versionEntries(ctx, keyStr)
+	if err == nil {
+		for _, entry := range entries {
+			_ = c.store.Delete(ctx, ds.NewKey(entry.Key))
+		}
 	}
-	return true
+	return c.store.Delete(ctx, ds.NewKey(keyStr))
 }
 
-func UpdateCache(ctx context.Context, keyStr string, newValue []byte) (bool, error) {
+// Update replaces the value of an existing key.
+func (c *ResolverCache) Update(ctx context.Context, keyStr string, newValue []byte) (bool, error) {
 	// Check if the key exists
 	key := ds.NewKey(keyStr)
-	res, err := cache.Has(ctx, key)
+	res, err := c.store.Has(ctx, key)
 	if err != nil {
 		return false, fmt.Errorf("error checking key existence: %w", err)
 	}
 
 	if !res {
-		return false, fmt.Errorf("key does not exist, adding new key-value pair, %+v", err.Error())
+		return false, fmt.Errorf("key does not exist: %s", keyStr)
 	}
 
 	// Put the new value for the key in the datastore
-	if err := cache.Put(ctx, key, newValue); err != nil {
+	if err := c.store.Put(ctx, key, newValue); err != nil {
 		return false, fmt.Errorf("error updating data: %w", err)
 	}
 
 	return true, nil
 }
 
-func sync(ctx context.Context, node *masa.OracleNode, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			iterateAndPublish(ctx, node)
-		case <-ctx.Done():
-			return
-		}
-	}
-}
-
-func iterateAndPublish(ctx context.Context, node *masa.OracleNode) {
-	records, err := QueryAll(ctx)
-	if err != nil {
-		logrus.Errorf("%+v", err)
-	}
-	for _, record := range records {
-		logrus.Printf("syncing record %s %s", record.Key, record.Value)
-		// ok := DelCache(ctx, record.Key)
-		//if ok {
-		//	logrus.Println("deleted")
-		//}
-		_, _ = WriteData(node, record.Key, record.Value)
-	}
-}
-
-func QueryAll(ctx context.Context) ([]Record, error) {
-	results, err := cache.Query(ctx, query.Query{})
+// All returns every record of the cache.
+func (c *ResolverCache) All(ctx context.Context) ([]Record, error) {
+	results, err := c.store.Query(ctx, query.Query{Filters: []query.Filter{recordFilter{}}})
 	if err != nil {
 		logrus.Errorf("Failed to query the resolver cache: %v", err)
 		return nil, err
@@ -157,15 +142,17 @@ func QueryAll(ctx context.Context) ([]Record, error) {
 	return records, nil
 }
 
-func monitorNodeData(ctx context.Context, node *masa.OracleNode) {
+func (d *Database) monitorNodeData(ctx context.Context) {
+	node := d.node
 	syncInterval := time.Second * 60
+	nodeStatusCh := make(chan []byte)
 	nodeStatusHandler := &nodestatus.SubscriptionHandler{NodeStatusCh: nodeStatusCh}
 	err := node.PubSubManager.Subscribe(config.TopicWithVersion(config.NodeStatusTopic), nodeStatusHandler)
 	if err != nil {
 		logrus.Println(err)
 	}
 
-	ticker := time.NewTicker(syncInterval)
+	ticker := node.Clock.Ticker(syncInterval)
 	defer ticker.Stop()
 	for {
 		select {
@@ -177,15 +164,33 @@ func monitorNodeData(ctx context.Context, node *masa.OracleNode) {
 			if e != nil {
 				logrus.Printf("%v", e)
 			}
-
-		case <-nodeStatusCh:
-			nodes := nodeStatusHandler.NodeStatus
-			for _, n := range nodes {
-				jsonData, _ := json.Marshal(n)
-				_, _ = WriteData(node, "/db/"+n.PeerID, jsonData)
+			if nodeData != nil {
+				d.writeNodeStatus(jsonData, false)
 			}
+
+		case data := <-nodeStatusCh:
+			d.writeNodeStatus(data, true)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+// writeNodeStatus stores a node status under nodestatus/<peer id>. Every node stores
+// its own status, admins also store the statuses they receive from the other nodes.
+// The node receives its own status from the topic as well, it is already stored.
+func (d *Database) writeNodeStatus(nodeData []byte, received bool) {
+	var status nodestatus.NodeStatus
+	if err := json.Unmarshal(nodeData, &status); err != nil {
+		logrus.Errorf("Failed to decode the node status: %v", err)
+		return
+	}
+	own := status.PeerID == d.node.Host.ID().String()
+	if status.PeerID == "" || (received && own) || !isAuthorized(d.node, "nodestatus/"+status.PeerID) {
+		return
+	}
+	jsonData, _ := json.Marshal(status)
+	if _, err := d.WriteData("nodestatus/"+status.PeerID, jsonData); err != nil {
+		logrus.Errorf("Failed to write the node status: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/network"
+)
+
+const (
+	// MaxKeyVersions is the number of recent versions of a key kept for inspection.
+	MaxKeyVersions = 10
+
+	versionsPrefix = "/.versions"
+)
+
+// ConflictStats counts the conflicts between versions of keys written concurrently.
+type ConflictStats struct {
+	// StaleWrites is the number of versions the cache rejected because it held a newer one.
+	StaleWrites uint64
+	// Adopted is the number of newer versions the republisher found in the DHT and
+	// stored in the cache instead of publishing the cached one.
+	Adopted uint64
+	// Selections is the number of times the DHT validator chose between distinct versions.
+	Selections uint64
+}
+
+// PutRecord stores a signed record unless the cache holds a newer version of the key
+// under last-writer-wins, and reports whether it was stored. Every version is kept in
+// the history of the key, including the ones that lost.
+func (c *ResolverCache) PutRecord(ctx context.Context, key string, envelope []byte) (bool, error) {
+	record, err := network.UnmarshalDBRecord(envelope)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.addVersion(ctx, key, record, envelope)
+	current, err := c.store.Get(ctx, ds.NewKey(key))
+	if err == nil {
+		// entries that are not records are replaced
+		if existing, err := network.UnmarshalDBRecord(current); err == nil && !record.NewerThan(existing) {
+			if record.Compare(existing) != 0 {
+				c.staleWrites.Add(1)
+			}
+			return false, nil
+		}
+	} else if !errors.Is(err, ds.ErrNotFound) {
+		return false, err
+	}
+	return true, c.store.Put(ctx, ds.NewKey(key), envelope)
+}
+
+// Versions returns the recent versions of the key known to the cache, newest first.
+func (c *ResolverCache) Versions(ctx context.Context, key string) ([]*network.DBRecord, error) {
+	entries, err := c.versionEntries(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*network.DBRecord, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		record, err := network.UnmarshalDBRecord(entries[i].Value)
+		if err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (c *ResolverCache) addVersion(ctx context.Context, key string, record *network.DBRecord, envelope []byte) {
+	version := record.Version()
+	// the zero padded timestamp sorts the history of a key by version
+	name := fmt.Sprintf("%020d.%010d-%s", version.Wall, version.Logical, record.Writer)
+	if err := c.store.Put(ctx, ds.NewKey(versionsPrefix+"/"+key+"/"+name), envelope); err != nil {
+		logrus.Warnf("Failed to record version %s of %s: %v", version, key, err)
+		return
+	}
+	entries, err := c.versionEntries(ctx, key)
+	if err != nil {
+		return
+	}
+	for i := 0; i < len(entries)-MaxKeyVersions; i++ {
+		_ = c.store.Delete(ctx, ds.NewKey(entries[i].Key))
+	}
+}
+
+// versionEntries lists the history of the key, oldest first. The history of a key
+// nested below it lives in a sub directory and is skipped.
+func (c *ResolverCache) versionEntries(ctx context.Context, key string) ([]query.Entry, error) {
+	prefix := ds.NewKey(versionsPrefix+"/"+key).String() + "/"
+	results, err := c.store.Query(ctx, query.Query{
+		Prefix: prefix,
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+	var entries []query.Entry
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if strings.Contains(strings.TrimPrefix(result.Key, prefix), "/") {
+			continue
+		}
+		entries = append(entries, result.Entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+)
+
+func signedRecord(t *testing.T, hlc *clock.HLC, privKey crypto.PrivKey, key, value string, seq uint64) []byte {
+	record, err := network.NewDBRecord(hlc, privKey, "/db/"+key, []byte(value), seq, 0)
+	require.NoError(t, err)
+	data, err := record.Marshal()
+	require.NoError(t, err)
+	return data
+}
+
+func TestPutRecordLastWriterWins(t *testing.T) {
+	mock := clock.NewMock()
+	hlc := clock.NewHLC(mock)
+	c := useTestCache(t, mock)
+	ctx := context.Background()
+
+	a, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	b, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+
+	older := signedRecord(t, hlc, a, "k", `"a"`, 2)
+	newer := signedRecord(t, hlc, b, "k", `"b"`, 1)
+
+	stored, err := c.PutRecord(ctx, "k", newer)
+	require.NoError(t, err)
+	assert.True(t, stored)
+	// the concurrent write that happened first loses, whatever its sequence number
+	stored, err = c.PutRecord(ctx, "k", older)
+	require.NoError(t, err)
+	assert.False(t, stored)
+	assert.Equal(t, uint64(1), c.staleWrites.Load())
+	value, err := c.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, newer, value)
+
+	versions, err := c.Versions(ctx, "k")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.True(t, versions[0].NewerThan(versions[1]))
+
+	// the history is bounded and hidden from queries
+	for i := 0; i < MaxKeyVersions; i++ {
+		_, err := c.PutRecord(ctx, "k", signedRecord(t, hlc, a, "k", fmt.Sprintf("%d", i), uint64(i+3)))
+		require.NoError(t, err)
+	}
+	_, err = c.PutRecord(ctx, "k/nested", signedRecord(t, hlc, a, "k/nested", `"n"`, 1))
+	require.NoError(t, err)
+	versions, err = c.Versions(ctx, "k")
+	require.NoError(t, err)
+	assert.Len(t, versions, MaxKeyVersions)
+	assert.Equal(t, uint64(MaxKeyVersions+2), versions[0].Seq)
+	page, err := c.Query(ctx, Query{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k", "k/nested"}, keysOf(page))
+}
+
+func TestRepublisherAdoptsNewerVersions(t *testing.T) {
+	mock := clock.NewMock()
+	hlc := clock.NewHLC(mock)
+	c := useTestCache(t, mock)
+	ctx := context.Background()
+
+	a, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	b, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	err = c.Put(ctx, "k", signedRecord(t, hlc, a, "k", `"local"`, 1))
+	require.NoError(t, err)
+	remote := signedRecord(t, hlc, b, "k", `"remote"`, 1)
+
+	dht := &fakeDHT{}
+	r := NewRepublisher(c, dht.put)
+	r.Get = func(context.Context, string) ([]byte, error) { return remote, nil }
+	require.NoError(t, r.Load(ctx))
+
+	// the newer version written by b replaces the cached one instead of being overwritten
+	r.RepublishOnce(ctx)
+	assert.Empty(t, dht.take())
+	assert.Equal(t, uint64(1), r.adopted.Load())
+	value, err := c.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, remote, value)
+	assert.Zero(t, r.Stats().Pending)
+
+	// a local version newer than the DHT one is published
+	err = c.Put(ctx, "k", signedRecord(t, hlc, a, "k", `"latest"`, 2))
+	require.NoError(t, err)
+	r.MarkDirty(ctx, "k")
+	r.RepublishOnce(ctx)
+	assert.Equal(t, []string{"/db/k"}, dht.take())
+}
@@ -0,0 +1,195 @@
+// Package health aggregates the checks that subsystems of the node register into the
+// liveness, readiness and startup probes.
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+// Probe is a set of checks answering one question about the node.
+type Probe string
+
+const (
+	// Liveness fails when a subsystem broke and the node should be restarted.
+	Liveness Probe = "liveness"
+	// Readiness fails while the node cannot serve its clients.
+	Readiness Probe = "readiness"
+	// Startup fails until the node has started.
+	Startup Probe = "startup"
+)
+
+// The status of a check and of a report.
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+)
+
+// CheckTimeout bounds the run of a check, a check that does not return in time fails.
+const CheckTimeout = time.Second * 5
+
+var errTimeout = errors.New("the check timed out")
+
+// Check reports the state of a subsystem. The detail describes a healthy subsystem and
+// the error an unhealthy one.
+type Check func(ctx context.Context) (detail string, err error)
+
+// Result is the outcome of a check.
+type Result struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the outcome of the checks of a probe, it passes when every check passes.
+type Report struct {
+	Probe  Probe    `json:"probe"`
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Healthy reports whether every check passed.
+func (r Report) Healthy() bool {
+	return r.Status == StatusPass
+}
+
+type registration struct {
+	check  Check
+	probes []Probe
+}
+
+// Registry holds the checks of the subsystems of a node.
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]registration
+}
+
+// NewRegistry creates a registry without checks.
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]registration)}
+}
+
+// Register adds a check to the probes, replacing the check registered with the same
+// name.
+func (r *Registry) Register(name string, check Check, probes ...Probe) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = registration{check: check, probes: probes}
+}
+
+// Run runs the checks of the probe concurrently and reports their results ordered by
+// name.
+func (r *Registry) Run(ctx context.Context, probe Probe) Report {
+	r.mu.RLock()
+	checks := make(map[string]Check)
+	for name, reg := range r.checks {
+		for _, p := range reg.probes {
+			if p == probe {
+				checks[name] = reg.check
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	report := Report{Probe: probe, Status: StatusPass, Checks: make([]Result, 0, len(checks))}
+	results := make(chan Result, len(checks))
+	for name, check := range checks {
+		go func(name string, check Check) {
+			results <- run(ctx, name, check)
+		}(name, check)
+	}
+	for range checks {
+		result := <-results
+		if result.Status != StatusPass {
+			report.Status = StatusFail
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	sort.Slice(report.Checks, func(i, j int) bool {
+		return report.Checks[i].Name < report.Checks[j].Name
+	})
+	return report
+}
+
+func run(ctx context.Context, name string, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+	type outcome struct {
+		detail string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		detail, err := check(ctx)
+		done <- outcome{detail, err}
+	}()
+	result := Result{Name: name, Status: StatusPass}
+	select {
+	case o := <-done:
+		result.Detail = o.detail
+		if o.err != nil {
+			result.Status, result.Error = StatusFail, o.err.Error()
+		}
+	case <-ctx.Done():
+		result.Status, result.Error = StatusFail, errTimeout.Error()
+	}
+	return result
+}
+
+// Status is the check of a one-off task, such as a verification at startup. It fails
+// with the pending message until the task sets its outcome.
+type Status struct {
+	mu      sync.RWMutex
+	done    bool
+	pending string
+	detail  string
+	err     error
+}
+
+// NewStatus creates the status of a task that has not finished.
+func NewStatus(pending string) *Status {
+	return &Status{pending: pending}
+}
+
+// Set records the outcome of the task.
+func (s *Status) Set(detail string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done, s.detail, s.err = true, detail, err
+}
+
+// Check implements Check.
+func (s *Status) Check(context.Context) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.done {
+		return "", errors.New(s.pending)
+	}
+	return s.detail, s.err
+}
+
+// Cached runs check at most once per ttl of clk and repeats its last outcome in between,
+// for checks that are expensive or count against the limits of a remote service.
+func Cached(clk clock.Clock, check Check, ttl time.Duration) Check {
+	var (
+		mu      sync.Mutex
+		checked time.Time
+		detail  string
+		err     error
+	)
+	return func(ctx context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if now := clk.Now(); checked.IsZero() || now.Sub(checked) >= ttl {
+			detail, err = check(ctx)
+			checked = now
+		}
+		return detail, err
+	}
+}
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	pass := func(context.Context) (string, error) { return "ok", nil }
+	registry.Register("b", pass, Liveness, Readiness)
+	registry.Register("a", func(context.Context) (string, error) { return "", errors.New("down") }, Readiness)
+
+	report := registry.Run(context.Background(), Liveness)
+	assert.True(t, report.Healthy())
+	assert.Equal(t, []Result{{Name: "b", Status: StatusPass, Detail: "ok"}}, report.Checks)
+
+	report = registry.Run(context.Background(), Readiness)
+	assert.False(t, report.Healthy())
+	assert.Equal(t, []Result{
+		{Name: "a", Status: StatusFail, Error: "down"},
+		{Name: "b", Status: StatusPass, Detail: "ok"},
+	}, report.Checks)
+
+	// a check registered again replaces the previous one
+	registry.Register("a", pass, Readiness)
+	assert.True(t, registry.Run(context.Background(), Readiness).Healthy())
+
+	report = registry.Run(context.Background(), Startup)
+	assert.True(t, report.Healthy())
+	assert.Empty(t, report.Checks)
+}
+
+func TestRegistryCancelledCheck(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("slow", func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return "done", nil
+	}, Readiness)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := registry.Run(ctx, Readiness)
+	assert.Equal(t, []Result{{Name: "slow", Status: StatusFail, Error: errTimeout.Error()}}, report.Checks)
+}
+
+func TestStatus(t *testing.T) {
+	status := NewStatus("verifying")
+	_, err := status.Check(context.Background())
+	assert.EqualError(t, err, "verifying")
+
+	status.Set("staked", nil)
+	detail, err := status.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "staked", detail)
+}
+
+func TestCached(t *testing.T) {
+	mock := clock.NewMock()
+	calls := 0
+	check := Cached(mock, func(context.Context) (string, error) {
+		calls++
+		return "", errors.New("logged out")
+	}, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		_, err := check(context.Background())
+		assert.EqualError(t, err, "logged out")
+	}
+	assert.Equal(t, 1, calls)
+	mock.Add(time.Minute)
+	_, _ = check(context.Background())
+	assert.Equal(t, 2, calls)
+}
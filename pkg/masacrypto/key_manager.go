@@ -87,14 +87,28 @@ func (km *KeyManager) loadPrivateKey() (err error) {
 			}
 		}
 	}
-	km.Libp2pPubKey = km.Libp2pPrivKey.GetPublic()
-	// After obtaining the libp2p privKey, convert it to an ECDSA private key
-	km.EcdsaPrivKey, err = libp2pPrivateKeyToEcdsa(km.Libp2pPrivKey)
-	if err != nil {
+	if err = km.deriveKeys(); err != nil {
 		return err
 	}
+	return saveEcdesaPrivateKeyToFile(km.EcdsaPrivKey, fmt.Sprintf("%s.ecdsa", keyFile))
+}
 
-	err = saveEcdesaPrivateKeyToFile(km.EcdsaPrivKey, fmt.Sprintf("%s.ecdsa", keyFile))
+// NewKeyManager creates a KeyManager for the given libp2p private key without reading or
+// writing any key files. Unlike the KeyManagerInstance singleton it allows several
+// identities in one process, e.g. when running multiple nodes in a test.
+func NewKeyManager(privKey crypto.PrivKey) (*KeyManager, error) {
+	km := &KeyManager{Libp2pPrivKey: privKey}
+	if err := km.deriveKeys(); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+// deriveKeys fills in the public key, ECDSA and hex representations from Libp2pPrivKey.
+func (km *KeyManager) deriveKeys() (err error) {
+	km.Libp2pPubKey = km.Libp2pPrivKey.GetPublic()
+	// After obtaining the libp2p privKey, convert it to an ECDSA private key
+	km.EcdsaPrivKey, err = libp2pPrivateKeyToEcdsa(km.Libp2pPrivKey)
 	if err != nil {
 		return err
 	}
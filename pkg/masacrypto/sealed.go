@@ -0,0 +1,214 @@
+package masacrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	secp "github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	ErrAccessDenied   = errors.New("access denied")
+	ErrUnsupportedKey = errors.New("only secp256k1 node keys can encrypt and decrypt")
+	ErrInvalidSealed  = errors.New("invalid sealed box")
+)
+
+const sealedKeyInfo = "masa sealed box v1"
+
+// SealedBox is a value encrypted for a set of readers. The value is encrypted once
+// with a random content key, which is wrapped for every reader with a key derived by
+// ECDH from an ephemeral key and the reader's node key. Only the holders of the
+// readers' node keys can recover the value.
+type SealedBox struct {
+	EphemeralKey []byte            `json:"ephemeralKey"`
+	Nonce        []byte            `json:"nonce"`
+	Ciphertext   []byte            `json:"ciphertext"`
+	Readers      []SealedRecipient `json:"readers"`
+}
+
+// SealedRecipient holds the content key of a SealedBox wrapped for one reader.
+type SealedRecipient struct {
+	PeerID string `json:"peerId"`
+	Nonce  []byte `json:"nonce"`
+	Key    []byte `json:"key"`
+}
+
+// Seal encrypts plaintext for the given readers. The additional data, e.g. the key
+// the value is stored under, is authenticated but not encrypted, the box can only be
+// opened with the same additional data.
+func Seal(plaintext, additionalData []byte, readers []crypto.PubKey) (*SealedBox, error) {
+	if len(readers) == 0 {
+		return nil, fmt.Errorf("%w: no readers", ErrInvalidSealed)
+	}
+	contentKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, contentKey); err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := aesSeal(contentKey, plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := secp.GeneratePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	box := &SealedBox{
+		EphemeralKey: ephemeral.PubKey().SerializeCompressed(),
+		Nonce:        nonce,
+		Ciphertext:   ciphertext,
+	}
+
+	seen := make(map[peer.ID]bool)
+	for _, reader := range readers {
+		id, err := peer.IDFromPublicKey(reader)
+		if err != nil {
+			return nil, err
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		pubKey, err := secpPublicKey(reader)
+		if err != nil {
+			return nil, err
+		}
+		wrapKey, err := deriveWrapKey(secp.GenerateSharedSecret(ephemeral, pubKey), box.EphemeralKey, id)
+		if err != nil {
+			return nil, err
+		}
+		keyNonce, wrapped, err := aesSeal(wrapKey, contentKey, []byte(id))
+		if err != nil {
+			return nil, err
+		}
+		box.Readers = append(box.Readers, SealedRecipient{PeerID: id.String(), Nonce: keyNonce, Key: wrapped})
+	}
+	return box, nil
+}
+
+// Open decrypts the box with the node key of one of its readers. Nodes that are not
+// readers get ErrAccessDenied.
+func (b *SealedBox) Open(privKey crypto.PrivKey, additionalData []byte) ([]byte, error) {
+	id, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	var recipient *SealedRecipient
+	for i := range b.Readers {
+		if b.Readers[i].PeerID == id.String() {
+			recipient = &b.Readers[i]
+			break
+		}
+	}
+	if recipient == nil {
+		return nil, ErrAccessDenied
+	}
+	key, err := secpPrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := secp.ParsePubKey(b.EphemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSealed, err)
+	}
+	wrapKey, err := deriveWrapKey(secp.GenerateSharedSecret(key, ephemeral), b.EphemeralKey, id)
+	if err != nil {
+		return nil, err
+	}
+	contentKey, err := aesOpen(wrapKey, recipient.Nonce, recipient.Key, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSealed, err)
+	}
+	plaintext, err := aesOpen(contentKey, b.Nonce, b.Ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSealed, err)
+	}
+	return plaintext, nil
+}
+
+// ReaderIDs returns the peer IDs of the readers of the box.
+func (b *SealedBox) ReaderIDs() []string {
+	ids := make([]string, len(b.Readers))
+	for i, r := range b.Readers {
+		ids[i] = r.PeerID
+	}
+	return ids
+}
+
+// Marshal encodes the box as JSON.
+func (b *SealedBox) Marshal() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+// UnmarshalSealedBox decodes a box encoded by Marshal.
+func UnmarshalSealedBox(data []byte) (*SealedBox, error) {
+	var box SealedBox
+	if err := json.Unmarshal(data, &box); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSealed, err)
+	}
+	return &box, nil
+}
+
+func deriveWrapKey(sharedSecret, ephemeralKey []byte, reader peer.ID) ([]byte, error) {
+	key := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, sharedSecret, ephemeralKey, append([]byte(sealedKeyInfo), reader...))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func aesSeal(key, plaintext, additionalData []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+func aesOpen(key, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func secpPublicKey(pubKey crypto.PubKey) (*secp.PublicKey, error) {
+	key, ok := pubKey.(*crypto.Secp256k1PublicKey)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+	return (*secp.PublicKey)(key), nil
+}
+
+func secpPrivateKey(privKey crypto.PrivKey) (*secp.PrivateKey, error) {
+	key, ok := privKey.(*crypto.Secp256k1PrivateKey)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+	return (*secp.PrivateKey)(key), nil
+}
@@ -0,0 +1,46 @@
+package masacrypto
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealedBox(t *testing.T) {
+	owner, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	reader, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	stranger, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+
+	box, err := Seal([]byte("secret"), []byte("/db/key"), []crypto.PubKey{owner.GetPublic(), reader.GetPublic(), owner.GetPublic()})
+	require.NoError(t, err)
+	assert.Len(t, box.Readers, 2)
+	assert.NotContains(t, string(box.Ciphertext), "secret")
+
+	data, err := box.Marshal()
+	require.NoError(t, err)
+	box, err = UnmarshalSealedBox(data)
+	require.NoError(t, err)
+
+	for _, key := range []crypto.PrivKey{owner, reader} {
+		plaintext, err := box.Open(key, []byte("/db/key"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("secret"), plaintext)
+	}
+
+	_, err = box.Open(stranger, []byte("/db/key"))
+	assert.ErrorIs(t, err, ErrAccessDenied)
+
+	// the box is bound to the key it was sealed for
+	_, err = box.Open(owner, []byte("/db/other"))
+	assert.ErrorIs(t, err, ErrInvalidSealed)
+
+	ed, _, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+	require.NoError(t, err)
+	_, err = Seal([]byte("secret"), nil, []crypto.PubKey{ed.GetPublic()})
+	assert.ErrorIs(t, err, ErrUnsupportedKey)
+}
@@ -0,0 +1,61 @@
+package namespace
+
+import "encoding/json"
+
+// builtins are the namespaces every node knows without configuration.
+func builtins() []Namespace {
+	return []Namespace{
+		{
+			Name:        "nodestatus",
+			Description: "Status and uptime of the nodes, keyed by peer ID",
+			Policy:      PolicyOwner,
+			CacheTTL:    "1m",
+			Schema: json.RawMessage(`{
+				"type": "object",
+				"required": ["peerId", "isActive", "isStaked"],
+				"properties": {
+					"peerId": {"type": "string", "minLength": 1},
+					"isActive": {"type": "boolean"},
+					"isStaked": {"type": "boolean"},
+					"isWriterNode": {"type": "boolean"},
+					"accumulatedUptime": {"type": "integer", "minimum": 0},
+					"currentUptime": {"type": "integer", "minimum": 0},
+					"firstJoined": {"type": "string"},
+					"lastJoined": {"type": "string"}
+				}
+			}`),
+		},
+		{
+			Name:        "twitter",
+			Description: "Results of twitter scrapes, keyed by query",
+			Policy:      PolicyWriters,
+			CacheTTL:    "10m",
+			Schema: json.RawMessage(`{
+				"type": "object",
+				"required": ["query", "tweets"],
+				"properties": {
+					"query": {"type": "string", "minLength": 1},
+					"count": {"type": "integer", "minimum": 0},
+					"tweets": {"type": "array", "items": {"type": "object"}}
+				}
+			}`),
+		},
+		{
+			Name:        "profiles",
+			Description: "Self-published node operator profiles, keyed by peer ID",
+			Policy:      PolicyOwner,
+			CacheTTL:    "1h",
+			Schema: json.RawMessage(`{
+				"type": "object",
+				"required": ["name"],
+				"additionalProperties": false,
+				"properties": {
+					"name": {"type": "string", "minLength": 1, "maxLength": 100},
+					"description": {"type": "string", "maxLength": 1000},
+					"website": {"type": "string", "format": "uri"},
+					"ethAddress": {"type": "string", "pattern": "^0x[0-9a-fA-F]{40}$"}
+				}
+			}`),
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package namespace_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/namespace"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/testharness"
+)
+
+func TestNamespacePolicyAndSchemaInDHT(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 3})
+	h.WaitForRoutingTables()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testharness.ConvergeTimeout)
+	defer cancel()
+	writer := h.Node(1)
+	put := func(key, value string) error {
+		record, err := network.NewDBRecord(writer.HLC, writer.KeyManager.Libp2pPrivKey, key, []byte(value), 1, 0)
+		require.NoError(t, err)
+		envelope, err := record.Marshal()
+		require.NoError(t, err)
+		return writer.DHT.PutValue(ctx, key, envelope)
+	}
+
+	own := "/db/profiles/" + h.ID(1).String()
+	assert.ErrorIs(t, put(own, `{"nick": "x"}`), namespace.ErrSchemaViolation)
+	assert.ErrorIs(t, put("/db/profiles/"+h.ID(2).String(), `{"name": "x"}`), network.ErrDBRecordUnauthorized)
+	assert.ErrorIs(t, put("/db/unknown/key", `{}`), network.ErrDBRecordUnauthorized)
+	require.NoError(t, put(own, `{"name": "operator"}`))
+
+	stored, err := h.Node(2).DHT.GetValue(ctx, own)
+	require.NoError(t, err)
+	record, err := network.UnmarshalDBRecord(stored)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "operator"}`, string(record.Value))
+}
@@ -0,0 +1,237 @@
+// Package namespace partitions the keys of the DHT database into registered namespaces.
+//
+// A key such as profiles/<peer id> belongs to the namespace named by its first path
+// segment. Each namespace has a JSON Schema that every value written to it must
+// satisfy and an access policy that decides which peers may write its keys. The
+// built-in namespaces are always registered, operators can add their own by placing
+// namespace definitions in a directory that is loaded on startup.
+package namespace
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/masa-finance/masa-oracle/pkg/acl"
+)
+
+// Policy decides which peers may write the keys of a namespace.
+type Policy string
+
+const (
+	// PolicyWriters admits peers with the writer or admin role in the ACL.
+	PolicyWriters Policy = "writers"
+	// PolicyAdmins admits ACL admins only.
+	PolicyAdmins Policy = "admins"
+	// PolicyOwner lets every peer write the key named after its own peer ID, e.g.
+	// profiles/<peer id>. ACL admins may write every key.
+	PolicyOwner Policy = "owner"
+)
+
+var (
+	ErrUnknownNamespace = errors.New("unknown namespace")
+	ErrInvalidNamespace = errors.New("invalid namespace definition")
+	ErrSchemaViolation  = errors.New("value violates the namespace schema")
+)
+
+// SchemaError reports why a value was rejected by the schema of its namespace.
+type SchemaError struct {
+	Namespace string   `json:"namespace"`
+	Details   []string `json:"details"`
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s %q: %s", ErrSchemaViolation, e.Namespace, strings.Join(e.Details, "; "))
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaViolation
+}
+
+// Namespace is a registered part of the keyspace. CacheTTL is how long a node serves
+// a value it read from the DHT without checking for a newer version, as a duration
+// such as "5m". Namespaces without it use the default of the node.
+type Namespace struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Policy      Policy          `json:"policy"`
+	CacheTTL    string          `json:"cacheTTL,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+	schema      *jsonschema.Schema
+	cacheTTL    time.Duration
+}
+
+func (ns *Namespace) compile() error {
+	// names starting with a dot are reserved for bookkeeping next to the records
+	if ns.Name == "" || strings.ContainsAny(ns.Name, "/ ") || strings.HasPrefix(ns.Name, ".") {
+		return fmt.Errorf("%w: name %q", ErrInvalidNamespace, ns.Name)
+	}
+	switch ns.Policy {
+	case PolicyWriters, PolicyAdmins, PolicyOwner:
+	default:
+		return fmt.Errorf("%w: %s: unknown policy %q", ErrInvalidNamespace, ns.Name, ns.Policy)
+	}
+	if ns.CacheTTL != "" {
+		ttl, err := time.ParseDuration(ns.CacheTTL)
+		if err != nil || ttl < 0 {
+			return fmt.Errorf("%w: %s: invalid cacheTTL %q", ErrInvalidNamespace, ns.Name, ns.CacheTTL)
+		}
+		ns.cacheTTL = ttl
+	}
+	schema, err := jsonschema.CompileString(ns.Name+".json", string(ns.Schema))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidNamespace, ns.Name, err)
+	}
+	ns.schema = schema
+	return nil
+}
+
+// FreshFor returns the CacheTTL of the namespace, or zero if it has none.
+func (ns *Namespace) FreshFor() time.Duration {
+	return ns.cacheTTL
+}
+
+// Validate checks a JSON encoded value against the namespace schema.
+func (ns *Namespace) Validate(value []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return &SchemaError{Namespace: ns.Name, Details: []string{"value is not valid JSON: " + err.Error()}}
+	}
+	err := ns.schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return &SchemaError{Namespace: ns.Name, Details: []string{err.Error()}}
+	}
+	details := make([]string, 0)
+	for _, e := range validationErr.BasicOutput().Errors {
+		if e.Error == "" || strings.HasPrefix(e.Error, "doesn't validate with") {
+			continue
+		}
+		details = append(details, fmt.Sprintf("%s: %s", locationOrRoot(e.InstanceLocation), e.Error))
+	}
+	if len(details) == 0 {
+		details = append(details, validationErr.Error())
+	}
+	return &SchemaError{Namespace: ns.Name, Details: details}
+}
+
+func locationOrRoot(location string) string {
+	if location == "" {
+		return "/"
+	}
+	return location
+}
+
+// Allows reports whether writer, holding role in the ACL, may write the key id of the namespace.
+func (ns *Namespace) Allows(writer peer.ID, id string, role acl.Role) bool {
+	switch ns.Policy {
+	case PolicyWriters:
+		return role.CanWrite()
+	case PolicyAdmins:
+		return role == acl.RoleAdmin
+	case PolicyOwner:
+		return role == acl.RoleAdmin || id == writer.String()
+	}
+	return false
+}
+
+// Registry holds the namespaces known to the node.
+type Registry struct {
+	mu         sync.RWMutex
+	namespaces map[string]*Namespace
+}
+
+// NewRegistry creates a registry with the built-in namespaces.
+func NewRegistry() *Registry {
+	r := &Registry{namespaces: make(map[string]*Namespace)}
+	for _, ns := range builtins() {
+		if err := r.Register(ns); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds a namespace or replaces the one with the same name.
+func (r *Registry) Register(ns Namespace) error {
+	if err := ns.compile(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.namespaces[ns.Name] = &ns
+	return nil
+}
+
+// LoadDir registers every *.json namespace definition in dir. A missing directory is not an error.
+func (r *Registry) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var ns Namespace
+		if err := json.Unmarshal(data, &ns); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidNamespace, file, err)
+		}
+		if err := r.Register(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the namespace with the given name.
+func (r *Registry) Get(name string) (*Namespace, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ns, ok := r.namespaces[name]
+	return ns, ok
+}
+
+// Lookup splits a database key into its namespace and the id within the namespace.
+// Keys without a namespace segment return ok false and a nil error, keys naming a
+// namespace that is not registered return ErrUnknownNamespace.
+func (r *Registry) Lookup(key string) (ns *Namespace, id string, err error) {
+	key = strings.TrimPrefix(key, "/")
+	name, id, found := strings.Cut(key, "/")
+	if !found {
+		return nil, key, nil
+	}
+	ns, ok := r.Get(name)
+	if !ok {
+		return nil, id, fmt.Errorf("%w: %s", ErrUnknownNamespace, name)
+	}
+	return ns, id, nil
+}
+
+// List returns the registered namespaces sorted by name.
+func (r *Registry) List() []*Namespace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]*Namespace, 0, len(r.namespaces))
+	for _, ns := range r.namespaces {
+		list = append(list, ns)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,81 @@
+package namespace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/acl"
+)
+
+func TestLookupAndValidate(t *testing.T) {
+	registry := NewRegistry()
+
+	ns, id, err := registry.Lookup("profiles/abc")
+	require.NoError(t, err)
+	assert.Equal(t, "profiles", ns.Name)
+	assert.Equal(t, "abc", id)
+
+	ns, _, err = registry.Lookup("flatkey")
+	require.NoError(t, err)
+	assert.Nil(t, ns)
+
+	_, _, err = registry.Lookup("unknown/abc")
+	assert.ErrorIs(t, err, ErrUnknownNamespace)
+
+	profiles, _ := registry.Get("profiles")
+	assert.NoError(t, profiles.Validate([]byte(`{"name": "node operator", "website": "https://masa.ai"}`)))
+
+	err = profiles.Validate([]byte(`{"name": "", "extra": 1}`))
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	assert.ErrorIs(t, err, ErrSchemaViolation)
+	assert.Equal(t, "profiles", schemaErr.Namespace)
+	assert.NotEmpty(t, schemaErr.Details)
+
+	assert.ErrorIs(t, profiles.Validate([]byte(`not json`)), ErrSchemaViolation)
+}
+
+func TestPolicies(t *testing.T) {
+	key, _, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+	require.NoError(t, err)
+	writer, err := peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+	registry := NewRegistry()
+
+	profiles, _ := registry.Get("profiles")
+	assert.True(t, profiles.Allows(writer, writer.String(), acl.RoleNone))
+	assert.False(t, profiles.Allows(writer, "someone-else", acl.RoleWriter))
+	assert.True(t, profiles.Allows(writer, "someone-else", acl.RoleAdmin))
+
+	nodeStatus, _ := registry.Get("nodestatus")
+	assert.True(t, nodeStatus.Allows(writer, writer.String(), acl.RoleNone))
+	assert.False(t, nodeStatus.Allows(writer, "someone-else", acl.RoleWriter))
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	definition := `{"name": "weather", "policy": "admins", "cacheTTL": "5m", "schema": {"type": "object", "required": ["celsius"]}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "weather.json"), []byte(definition), 0600))
+
+	registry := NewRegistry()
+	require.NoError(t, registry.LoadDir(dir))
+	weather, ok := registry.Get("weather")
+	require.True(t, ok)
+	assert.Equal(t, PolicyAdmins, weather.Policy)
+	assert.Equal(t, 5*time.Minute, weather.FreshFor())
+	assert.Error(t, weather.Validate([]byte(`{}`)))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"name": "broken", "policy": "everyone", "schema": {}}`), 0600))
+	assert.ErrorIs(t, registry.LoadDir(dir), ErrInvalidNamespace)
+	require.NoError(t, os.Remove(filepath.Join(dir, "broken.json")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stale.json"), []byte(`{"name": "stale", "policy": "admins", "cacheTTL": "often", "schema": {"type": "object"}}`), 0600))
+	assert.ErrorIs(t, registry.LoadDir(dir), ErrInvalidNamespace)
+	assert.NoError(t, registry.LoadDir(filepath.Join(dir, "missing")))
+}
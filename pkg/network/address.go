@@ -149,21 +149,6 @@ func replaceIPComponent(maddr multiaddr.Multiaddr, newIP string) (multiaddr.Mult
 //	return false
 //}
 
-func GetBootNodesMultiAddress(bootstrapNodes []string) ([]multiaddr.Multiaddr, error) {
-	addrs := make([]multiaddr.Multiaddr, 0)
-	for _, peerAddr := range bootstrapNodes {
-		if peerAddr == "" {
-			continue
-		}
-		addr, err := multiaddr.NewMultiaddr(peerAddr)
-		if err != nil {
-			return nil, err
-		}
-		addrs = append(addrs, addr)
-	}
-	return addrs, nil
-}
-
 func getGCPExternalIP() (bool, string) {
 
 	// Create a new HTTP client with a specific timeout
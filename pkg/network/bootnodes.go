@@ -0,0 +1,425 @@
+package network
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/consensus"
+)
+
+const (
+	// BootNodeRefreshInterval is how often dnsaddr entries and the manifest are re-resolved
+	BootNodeRefreshInterval = time.Minute * 10
+	maxDnsaddrDepth         = 4
+	manifestFetchTimeout    = time.Second * 30
+	bootNodeDialTimeout     = time.Second * 30
+)
+
+// BootNodeManifest is a list of boot node multiaddrs signed by a trusted publisher.
+// Operators point their node at a manifest file or URL instead of a fixed list,
+// so the boot set can be rotated without touching every node's configuration.
+type BootNodeManifest struct {
+	Version   int       `json:"version"`
+	Issued    time.Time `json:"issued"`
+	Expires   time.Time `json:"expires,omitempty"`
+	BootNodes []string  `json:"bootnodes"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+func (m *BootNodeManifest) signedBytes() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
+
+// SignBootNodeManifest signs the manifest with the publisher's private key.
+func SignBootNodeManifest(privKey crypto.PrivKey, m *BootNodeManifest) error {
+	data, err := m.signedBytes()
+	if err != nil {
+		return err
+	}
+	signature, err := consensus.SignData(privKey, data)
+	if err != nil {
+		return err
+	}
+	m.Signature = hex.EncodeToString(signature)
+	return nil
+}
+
+// Verify checks the manifest signature against the publisher key and rejects
+// manifests that have expired.
+func (m *BootNodeManifest) Verify(publisherKey crypto.PubKey) error {
+	if err := m.verifySignature(publisherKey); err != nil {
+		return err
+	}
+	if m.expired() {
+		return fmt.Errorf("bootnode manifest expired at %s", m.Expires)
+	}
+	return nil
+}
+
+func (m *BootNodeManifest) expired() bool {
+	return !m.Expires.IsZero() && time.Now().After(m.Expires)
+}
+
+func (m *BootNodeManifest) verifySignature(publisherKey crypto.PubKey) error {
+	if m.Signature == "" {
+		return errors.New("bootnode manifest is not signed")
+	}
+	data, err := m.signedBytes()
+	if err != nil {
+		return err
+	}
+	valid, err := consensus.VerifySignature(publisherKey, data, m.Signature)
+	if err != nil {
+		return fmt.Errorf("bootnode manifest signature: %w", err)
+	}
+	if !valid {
+		return errors.New("bootnode manifest signature is invalid")
+	}
+	return nil
+}
+
+// LoadBootNodeManifest reads a manifest from a local file or an http(s) URL.
+func LoadBootNodeManifest(ctx context.Context, location string) (*BootNodeManifest, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		data, err = fetchManifest(ctx, location)
+	} else {
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest BootNodeManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid bootnode manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+func fetchManifest(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, manifestFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logrus.Error(err)
+		}
+	}(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching bootnode manifest: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// BootNodeResolver turns the configured boot node entries into dialable multiaddrs.
+// Entries may be plain multiaddrs or /dnsaddr/ names, and an optional signed
+// manifest contributes additional entries once it verifies against the publisher key.
+// A manifest with a lower version than the last accepted one is rejected, so an old
+// manifest cannot be replayed to steer the node to retired boot nodes.
+type BootNodeResolver struct {
+	DNS              *madns.Resolver
+	ManifestLocation string
+	PublisherKey     crypto.PubKey
+	// StateFile keeps the last accepted manifest across restarts, no state is kept
+	// when it is empty.
+	StateFile string
+
+	mu       sync.Mutex
+	accepted *BootNodeManifest
+}
+
+// NewBootNodeResolver creates a resolver using the system DNS. publisherKeyHex is the
+// hex-encoded, marshalled libp2p public key of the manifest publisher and is required
+// when a manifest location is set. The last accepted manifest is loaded from stateFile.
+func NewBootNodeResolver(manifestLocation, publisherKeyHex, stateFile string) (*BootNodeResolver, error) {
+	resolver := &BootNodeResolver{DNS: madns.DefaultResolver, ManifestLocation: manifestLocation, StateFile: stateFile}
+	if manifestLocation == "" {
+		return resolver, nil
+	}
+	if publisherKeyHex == "" {
+		return nil, errors.New("a bootnode manifest requires the publisher public key")
+	}
+	keyBytes, err := hex.DecodeString(publisherKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootnode manifest publisher key: %w", err)
+	}
+	resolver.PublisherKey, err = crypto.UnmarshalPublicKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootnode manifest publisher key: %w", err)
+	}
+	resolver.loadState()
+	return resolver, nil
+}
+
+// loadState restores the last accepted manifest. A state file that is unreadable or
+// not signed by the publisher is logged and ignored.
+func (r *BootNodeResolver) loadState() {
+	if r.StateFile == "" {
+		return
+	}
+	data, err := os.ReadFile(r.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	var manifest BootNodeManifest
+	if err == nil {
+		err = json.Unmarshal(data, &manifest)
+	}
+	if err == nil {
+		err = manifest.verifySignature(r.PublisherKey)
+	}
+	if err != nil {
+		logrus.Warnf("Ignoring the accepted bootnode manifest in %s: %v", r.StateFile, err)
+		return
+	}
+	r.accepted = &manifest
+}
+
+// accept records a verified manifest unless an accepted manifest has a higher version.
+// A higher version is persisted to the state file.
+func (r *BootNodeResolver) accept(manifest *BootNodeManifest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.accepted != nil && manifest.Version < r.accepted.Version {
+		return fmt.Errorf("bootnode manifest version %d is older than the accepted version %d", manifest.Version, r.accepted.Version)
+	}
+	if r.accepted != nil && manifest.Version == r.accepted.Version {
+		return nil
+	}
+	r.accepted = manifest
+	if r.StateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(manifest)
+	if err == nil {
+		tmp := r.StateFile + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, r.StateFile)
+		}
+	}
+	if err != nil {
+		logrus.Errorf("Failed to persist the bootnode manifest version %d: %v", manifest.Version, err)
+	}
+	return nil
+}
+
+// AcceptedManifestVersion returns the version of the last accepted manifest, 0 when
+// none was accepted.
+func (r *BootNodeResolver) AcceptedManifestVersion() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.accepted == nil {
+		return 0
+	}
+	return r.accepted.Version
+}
+
+// manifestEntries loads, verifies and accepts the manifest. When that fails the entries
+// of the last accepted manifest are used, so a manifest that is briefly unreachable
+// does not drop its boot nodes.
+func (r *BootNodeResolver) manifestEntries(ctx context.Context) []string {
+	manifest, err := LoadBootNodeManifest(ctx, r.ManifestLocation)
+	if err == nil {
+		err = manifest.Verify(r.PublisherKey)
+	}
+	if err == nil {
+		err = r.accept(manifest)
+	}
+	if err == nil {
+		return manifest.BootNodes
+	}
+	logrus.Errorf("Ignoring bootnode manifest %s: %v", r.ManifestLocation, err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.accepted == nil || r.accepted.expired() {
+		return nil
+	}
+	logrus.Infof("Using the bootnodes of the accepted manifest version %d", r.accepted.Version)
+	return r.accepted.BootNodes
+}
+
+// GetBootNodesMultiAddress resolves the boot node entries, expanding /dnsaddr/ names
+// with the system DNS.
+func GetBootNodesMultiAddress(bootstrapNodes []string) ([]multiaddr.Multiaddr, error) {
+	resolver := &BootNodeResolver{DNS: madns.DefaultResolver}
+	return resolver.GetBootNodesMultiAddress(context.Background(), bootstrapNodes)
+}
+
+// GetBootNodesMultiAddress resolves the boot node entries and, when configured, the
+// entries of the signed manifest. A manifest that cannot be loaded, verified or is
+// older than the accepted one is logged and ignored, and entries that are not valid
+// multiaddrs are skipped, so the node still starts with the remaining entries.
+func (r *BootNodeResolver) GetBootNodesMultiAddress(ctx context.Context, bootstrapNodes []string) ([]multiaddr.Multiaddr, error) {
+	entries := append([]string{}, bootstrapNodes...)
+	if r.ManifestLocation != "" {
+		entries = append(entries, r.manifestEntries(ctx)...)
+	}
+
+	addrs := make([]multiaddr.Multiaddr, 0)
+	seen := make(map[string]bool)
+	for _, peerAddr := range entries {
+		peerAddr = strings.TrimSpace(peerAddr)
+		if peerAddr == "" {
+			continue
+		}
+		addr, err := multiaddr.NewMultiaddr(peerAddr)
+		if err != nil {
+			logrus.Warnf("Skipping invalid bootnode %s: %v", peerAddr, err)
+			continue
+		}
+		resolved, err := r.resolveDnsaddr(ctx, addr, 0)
+		if err != nil {
+			logrus.Warnf("Failed to resolve bootnode %s: %v", peerAddr, err)
+			continue
+		}
+		for _, a := range resolved {
+			if !seen[a.String()] {
+				seen[a.String()] = true
+				addrs = append(addrs, a)
+			}
+		}
+	}
+	return addrs, nil
+}
+
+// resolveDnsaddr expands /dnsaddr/ entries recursively, leaving other addresses as they are.
+func (r *BootNodeResolver) resolveDnsaddr(ctx context.Context, addr multiaddr.Multiaddr, depth int) ([]multiaddr.Multiaddr, error) {
+	if _, err := addr.ValueForProtocol(multiaddr.P_DNSADDR); err != nil {
+		return []multiaddr.Multiaddr{addr}, nil
+	}
+	if depth >= maxDnsaddrDepth {
+		return nil, fmt.Errorf("dnsaddr recursion limit reached at %s", addr)
+	}
+	resolved, err := r.DNS.Resolve(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]multiaddr.Multiaddr, 0, len(resolved))
+	for _, a := range resolved {
+		nested, err := r.resolveDnsaddr(ctx, a, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, nested...)
+	}
+	return result, nil
+}
+
+// WatchBootNodes re-resolves the boot node entries every interval and hands addresses
+// that were not part of the previous result and addresses that are gone to onChange.
+func (r *BootNodeResolver) WatchBootNodes(ctx context.Context, bootstrapNodes []string, known []multiaddr.Multiaddr,
+	interval time.Duration, onChange func(added, removed []multiaddr.Multiaddr)) {
+	current := make(map[string]multiaddr.Multiaddr)
+	for _, a := range known {
+		current[a.String()] = a
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			addrs, err := r.GetBootNodesMultiAddress(ctx, bootstrapNodes)
+			if err != nil {
+				logrus.Errorf("Failed to refresh bootnodes: %v", err)
+				continue
+			}
+			next := make(map[string]multiaddr.Multiaddr)
+			var added, removed []multiaddr.Multiaddr
+			for _, a := range addrs {
+				next[a.String()] = a
+				if current[a.String()] == nil {
+					added = append(added, a)
+				}
+			}
+			for s, a := range current {
+				if next[s] == nil {
+					removed = append(removed, a)
+				}
+			}
+			current = next
+			if len(added) > 0 || len(removed) > 0 {
+				logrus.Infof("Bootnode set changed, %d bootnodes, %d new, %d removed", len(next), len(added), len(removed))
+				onChange(added, removed)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// AddBootNodes connects to boot nodes learned after startup and adds them to the DHT routing table.
+func AddBootNodes(ctx context.Context, host host.Host, kdht *dht.IpfsDHT, bootNodes []multiaddr.Multiaddr) {
+	var wg sync.WaitGroup
+	for _, addr := range bootNodes {
+		peerInfo, err := peer.AddrInfoFromP2pAddr(addr)
+		if err != nil {
+			logrus.Errorf("Invalid bootnode address %s: %v", addr, err)
+			continue
+		}
+		if peerInfo.ID == host.ID() {
+			continue
+		}
+		wg.Add(1)
+		go func(peerInfo peer.AddrInfo) {
+			defer wg.Done()
+			dialCtx, cancel := context.WithTimeout(ctx, bootNodeDialTimeout)
+			defer cancel()
+			if err := host.Connect(dialCtx, peerInfo); err != nil {
+				logrus.Errorf("Failed to connect to bootstrap peer %s: %v", peerInfo.ID, err)
+				return
+			}
+			if _, err := kdht.RoutingTable().TryAddPeer(peerInfo.ID, true, false); err != nil {
+				logrus.Warningf("Failed to add bootstrap peer %s to DHT: %v", peerInfo.ID, err)
+			}
+			logrus.Infof("Connected to new bootstrap peer %s", peerInfo.ID)
+		}(*peerInfo)
+	}
+	wg.Wait()
+}
+
+// RemoveBootNodes drops boot nodes that are no longer listed. They are removed from the
+// DHT routing table, their addresses are forgotten and their connections are closed.
+func RemoveBootNodes(host host.Host, kdht *dht.IpfsDHT, bootNodes []multiaddr.Multiaddr) {
+	for _, addr := range bootNodes {
+		peerInfo, err := peer.AddrInfoFromP2pAddr(addr)
+		if err != nil || peerInfo.ID == host.ID() {
+			continue
+		}
+		kdht.RoutingTable().RemovePeer(peerInfo.ID)
+		host.Peerstore().SetAddrs(peerInfo.ID, peerInfo.Addrs, 0)
+		if err := host.Network().ClosePeer(peerInfo.ID); err != nil {
+			logrus.Warnf("Failed to disconnect from removed bootstrap peer %s: %v", peerInfo.ID, err)
+		}
+		logrus.Infof("Removed bootstrap peer %s", peerInfo.ID)
+	}
+}
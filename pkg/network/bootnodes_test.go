@@ -0,0 +1,219 @@
+package network
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	bootPeerA = "16Uiu2HAmPxXXjR1XJEwckh6q1UStheMmGaGe8fyXdeRs3SejadSa"
+	bootPeerB = "16Uiu2HAmTHk1nxbU74Co5vfbxEv56HfvPDRcJCdvYNZkGAHHQyJs"
+)
+
+func testDNSResolver(t *testing.T) *madns.Resolver {
+	// A local stand-in for DNS with one level of dnsaddr nesting
+	mock := &madns.MockResolver{
+		TXT: map[string][]string{
+			"_dnsaddr.boot.masa.test": {
+				"dnsaddr=/dnsaddr/us.boot.masa.test",
+				"dnsaddr=/ip4/10.0.0.2/udp/4001/quic-v1/p2p/" + bootPeerB,
+			},
+			"_dnsaddr.us.boot.masa.test": {
+				"dnsaddr=/ip4/10.0.0.1/udp/4001/quic-v1/p2p/" + bootPeerA,
+			},
+		},
+	}
+	resolver, err := madns.NewResolver(madns.WithDefaultResolver(mock))
+	require.NoError(t, err)
+	return resolver
+}
+
+func TestGetBootNodesMultiAddressResolvesDnsaddr(t *testing.T) {
+	resolver := &BootNodeResolver{DNS: testDNSResolver(t)}
+
+	addrs, err := resolver.GetBootNodesMultiAddress(context.Background(), []string{
+		"/dnsaddr/boot.masa.test",
+		"/ip4/10.0.0.1/udp/4001/quic-v1/p2p/" + bootPeerA, // duplicate of a resolved entry
+		"",
+		"/ip4/not-an-address", // skipped
+	})
+	require.NoError(t, err)
+
+	var got []string
+	for _, a := range addrs {
+		got = append(got, a.String())
+	}
+	assert.ElementsMatch(t, []string{
+		"/ip4/10.0.0.1/udp/4001/quic-v1/p2p/" + bootPeerA,
+		"/ip4/10.0.0.2/udp/4001/quic-v1/p2p/" + bootPeerB,
+	}, got)
+}
+
+func TestBootNodeManifest(t *testing.T) {
+	privKey, pubKey, err := crypto.GenerateKeyPair(crypto.Secp256k1, 256)
+	require.NoError(t, err)
+	pubKeyBytes, err := crypto.MarshalPublicKey(pubKey)
+	require.NoError(t, err)
+
+	manifest := &BootNodeManifest{
+		Version:   1,
+		Issued:    time.Now(),
+		BootNodes: []string{"/dnsaddr/us.boot.masa.test"},
+	}
+	require.NoError(t, SignBootNodeManifest(privKey, manifest))
+	assert.NoError(t, manifest.Verify(pubKey))
+
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	manifestPath := filepath.Join(t.TempDir(), "bootnodes.json")
+	require.NoError(t, os.WriteFile(manifestPath, data, 0644))
+
+	t.Run("file manifest is verified and resolved", func(t *testing.T) {
+		resolver, err := NewBootNodeResolver(manifestPath, hex.EncodeToString(pubKeyBytes), "")
+		require.NoError(t, err)
+		resolver.DNS = testDNSResolver(t)
+
+		addrs, err := resolver.GetBootNodesMultiAddress(context.Background(), nil)
+		require.NoError(t, err)
+		require.Len(t, addrs, 1)
+		assert.Equal(t, "/ip4/10.0.0.1/udp/4001/quic-v1/p2p/"+bootPeerA, addrs[0].String())
+	})
+
+	t.Run("url manifest", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(data)
+		}))
+		defer server.Close()
+
+		loaded, err := LoadBootNodeManifest(context.Background(), server.URL)
+		require.NoError(t, err)
+		assert.NoError(t, loaded.Verify(pubKey))
+	})
+
+	t.Run("tampered manifest is ignored", func(t *testing.T) {
+		tampered := *manifest
+		tampered.BootNodes = []string{"/ip4/10.6.6.6/udp/4001/quic-v1/p2p/" + bootPeerB}
+		assert.Error(t, tampered.Verify(pubKey))
+
+		tamperedData, err := json.Marshal(tampered)
+		require.NoError(t, err)
+		tamperedPath := filepath.Join(t.TempDir(), "bootnodes.json")
+		require.NoError(t, os.WriteFile(tamperedPath, tamperedData, 0644))
+
+		resolver, err := NewBootNodeResolver(tamperedPath, hex.EncodeToString(pubKeyBytes), "")
+		require.NoError(t, err)
+		resolver.DNS = testDNSResolver(t)
+		addrs, err := resolver.GetBootNodesMultiAddress(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Empty(t, addrs)
+	})
+
+	t.Run("expired manifest", func(t *testing.T) {
+		expired := &BootNodeManifest{Version: 1, Issued: time.Now().Add(-time.Hour), Expires: time.Now().Add(-time.Minute)}
+		require.NoError(t, SignBootNodeManifest(privKey, expired))
+		assert.Error(t, expired.Verify(pubKey))
+	})
+
+	t.Run("publisher key is required", func(t *testing.T) {
+		_, err := NewBootNodeResolver(manifestPath, "", "")
+		assert.Error(t, err)
+	})
+}
+
+// writeManifest signs a manifest with the given version and boot nodes and writes it to path.
+func writeManifest(t *testing.T, privKey crypto.PrivKey, path string, version int, bootNodes ...string) {
+	manifest := &BootNodeManifest{Version: version, Issued: time.Now(), BootNodes: bootNodes}
+	require.NoError(t, SignBootNodeManifest(privKey, manifest))
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+}
+
+func TestBootNodeManifestRejectsOlderVersions(t *testing.T) {
+	privKey, pubKey, err := crypto.GenerateKeyPair(crypto.Secp256k1, 256)
+	require.NoError(t, err)
+	pubKeyBytes, err := crypto.MarshalPublicKey(pubKey)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "bootnodes.json")
+	statePath := filepath.Join(dir, "accepted.json")
+	addrA := "/ip4/10.0.0.1/udp/4001/quic-v1/p2p/" + bootPeerA
+	addrB := "/ip4/10.0.0.2/udp/4001/quic-v1/p2p/" + bootPeerB
+
+	resolve := func(resolver *BootNodeResolver) []string {
+		addrs, err := resolver.GetBootNodesMultiAddress(context.Background(), nil)
+		require.NoError(t, err)
+		var got []string
+		for _, a := range addrs {
+			got = append(got, a.String())
+		}
+		return got
+	}
+
+	writeManifest(t, privKey, manifestPath, 2, addrB)
+	resolver, err := NewBootNodeResolver(manifestPath, hex.EncodeToString(pubKeyBytes), statePath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{addrB}, resolve(resolver))
+	assert.Equal(t, 2, resolver.AcceptedManifestVersion())
+
+	// a replayed older manifest is ignored, the accepted one still applies
+	writeManifest(t, privKey, manifestPath, 1, addrA)
+	assert.Equal(t, []string{addrB}, resolve(resolver))
+
+	// the accepted version survives a restart
+	restarted, err := NewBootNodeResolver(manifestPath, hex.EncodeToString(pubKeyBytes), statePath)
+	require.NoError(t, err)
+	assert.Equal(t, 2, restarted.AcceptedManifestVersion())
+	assert.Equal(t, []string{addrB}, resolve(restarted))
+
+	writeManifest(t, privKey, manifestPath, 3, addrA)
+	assert.Equal(t, []string{addrA}, resolve(restarted))
+	assert.Equal(t, 3, restarted.AcceptedManifestVersion())
+}
+
+func TestWatchBootNodesReportsRemovedNodes(t *testing.T) {
+	privKey, pubKey, err := crypto.GenerateKeyPair(crypto.Secp256k1, 256)
+	require.NoError(t, err)
+	pubKeyBytes, err := crypto.MarshalPublicKey(pubKey)
+	require.NoError(t, err)
+	manifestPath := filepath.Join(t.TempDir(), "bootnodes.json")
+	addrA := "/ip4/10.0.0.1/udp/4001/quic-v1/p2p/" + bootPeerA
+	addrB := "/ip4/10.0.0.2/udp/4001/quic-v1/p2p/" + bootPeerB
+
+	writeManifest(t, privKey, manifestPath, 1, addrA, addrB)
+	resolver, err := NewBootNodeResolver(manifestPath, hex.EncodeToString(pubKeyBytes), "")
+	require.NoError(t, err)
+	known, err := resolver.GetBootNodesMultiAddress(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, known, 2)
+
+	writeManifest(t, privKey, manifestPath, 2, addrA)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan []multiaddr.Multiaddr, 1)
+	go resolver.WatchBootNodes(ctx, nil, known, time.Millisecond*10, func(added, removed []multiaddr.Multiaddr) {
+		assert.Empty(t, added)
+		changes <- removed
+	})
+
+	select {
+	case removed := <-changes:
+		require.Len(t, removed, 1)
+		assert.Equal(t, addrB, removed[0].String())
+	case <-time.After(time.Second * 5):
+		t.Fatal("the removed bootnode was not reported")
+	}
+}
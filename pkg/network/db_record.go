@@ -0,0 +1,309 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/consensus"
+	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
+)
+
+// DBTombstoneTTL is how long the tombstone of a deleted record is kept and republished.
+// It must outlast the time it takes every replica of the deleted record to be replaced.
+const DBTombstoneTTL = time.Hour * 24 * 7
+
+var (
+	ErrDBRecordExpired      = errors.New("db record has expired")
+	ErrDBRecordKeyMismatch  = errors.New("db record was signed for a different key")
+	ErrDBRecordInvalidSig   = errors.New("db record signature is invalid")
+	ErrDBRecordUnauthorized = errors.New("db record writer is not authorized")
+	ErrNoValidDBRecord      = errors.New("no valid db record to select")
+	ErrDBRecordWriter       = errors.New("db record writer does not match its public key")
+	ErrDBRecordFromFuture   = errors.New("db record is timestamped too far in the future")
+)
+
+// dbConflicts counts the selections between distinct valid versions of a key.
+var dbConflicts atomic.Uint64
+
+// DBRecord is the signed envelope stored under /db/ keys in the DHT. The signature
+// covers every field but itself, including the DHT key, so a record cannot be replayed
+// under another key. Every record is stamped with the hybrid logical clock timestamp
+// of its writer, and the last writer wins: the record with the highest timestamp is
+// the current version, ties between concurrent writers are broken by writer ID. Seq
+// counts the versions of the key. A record with Expires set is dropped once that time has passed, and a deleted key is
+// represented by a tombstone: a newer version with Deleted set and no value. The value
+// of a private record is a masacrypto.SealedBox that only its readers can open.
+type DBRecord struct {
+	Key       string `json:"key"`
+	Value     []byte `json:"value,omitempty"`
+	Private   bool   `json:"private,omitempty"`
+	PublicKey []byte `json:"publicKey"`
+	Seq       uint64 `json:"seq"`
+	Timestamp int64  `json:"timestamp"`
+	// HLC and Writer are missing from records written before they were introduced,
+	// their version is their timestamp.
+	HLC       *clock.Timestamp `json:"hlc,omitempty"`
+	Writer    string           `json:"writer,omitempty"`
+	Expires   int64            `json:"expires,omitempty"`
+	Deleted   bool             `json:"deleted,omitempty"`
+	Signature []byte           `json:"signature,omitempty"`
+}
+
+// NewDBRecord creates version seq of key, timestamped by the writer's hybrid logical
+// clock, and signs it with the writer's private key. A ttl of zero keeps the record
+// until it is overwritten or deleted.
+func NewDBRecord(hlc *clock.HLC, privKey crypto.PrivKey, key string, value []byte, seq uint64, ttl time.Duration) (*DBRecord, error) {
+	return newDBRecord(hlc, privKey, &DBRecord{Key: key, Value: value, Seq: seq}, ttl)
+}
+
+// NewPrivateDBRecord creates version seq of key with the value encrypted for the
+// writer and the given readers.
+func NewPrivateDBRecord(hlc *clock.HLC, privKey crypto.PrivKey, key string, value []byte, readers []crypto.PubKey, seq uint64, ttl time.Duration) (*DBRecord, error) {
+	readers = append([]crypto.PubKey{privKey.GetPublic()}, readers...)
+	box, err := masacrypto.Seal(value, []byte(key), readers)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := box.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return newDBRecord(hlc, privKey, &DBRecord{Key: key, Value: sealed, Private: true, Seq: seq}, ttl)
+}
+
+// NewDBTombstone creates version seq of key marking it as deleted.
+func NewDBTombstone(hlc *clock.HLC, privKey crypto.PrivKey, key string, seq uint64) (*DBRecord, error) {
+	return newDBRecord(hlc, privKey, &DBRecord{Key: key, Seq: seq, Deleted: true}, DBTombstoneTTL)
+}
+
+func newDBRecord(hlc *clock.HLC, privKey crypto.PrivKey, record *DBRecord, ttl time.Duration) (*DBRecord, error) {
+	pubKey, err := crypto.MarshalPublicKey(privKey.GetPublic())
+	if err != nil {
+		return nil, err
+	}
+	writer, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	version := hlc.Now()
+	now := version.Time()
+	record.PublicKey = pubKey
+	record.Timestamp = now.UnixNano()
+	record.HLC = &version
+	record.Writer = writer.String()
+	if ttl > 0 {
+		record.Expires = now.Add(ttl).UnixNano()
+	}
+	if err := record.Sign(privKey); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Expired reports whether the record has an expiry time that lies before now.
+func (r *DBRecord) Expired(now time.Time) bool {
+	return r.Expires != 0 && now.UnixNano() >= r.Expires
+}
+
+// Live reports whether the record holds a value that readers should see.
+func (r *DBRecord) Live(now time.Time) bool {
+	return !r.Deleted && !r.Expired(now)
+}
+
+// Version returns the hybrid logical clock timestamp of the record.
+func (r *DBRecord) Version() clock.Timestamp {
+	if r.HLC != nil {
+		return *r.HLC
+	}
+	return clock.Timestamp{Wall: r.Timestamp}
+}
+
+// Compare orders two versions of a key by timestamp, then by writer ID and finally
+// by signature, so every node settles on the same record. It returns -1, 0 or 1 if r
+// is older than, the same as or newer than o.
+func (r *DBRecord) Compare(o *DBRecord) int {
+	if c := r.Version().Compare(o.Version()); c != 0 {
+		return c
+	}
+	if r.Writer != o.Writer {
+		if r.Writer < o.Writer {
+			return -1
+		}
+		return 1
+	}
+	return bytes.Compare(r.Signature, o.Signature)
+}
+
+// NewerThan reports whether r replaces o under last-writer-wins.
+func (r *DBRecord) NewerThan(o *DBRecord) bool {
+	return r.Compare(o) > 0
+}
+
+// Open returns the value of the record, decrypting private records with the node key
+// of the reader. Nodes that are not readers of a private record get
+// masacrypto.ErrAccessDenied.
+func (r *DBRecord) Open(privKey crypto.PrivKey) ([]byte, error) {
+	if !r.Private {
+		return r.Value, nil
+	}
+	box, err := masacrypto.UnmarshalSealedBox(r.Value)
+	if err != nil {
+		return nil, err
+	}
+	return box.Open(privKey, []byte(r.Key))
+}
+
+// UnmarshalDBRecord decodes a record as stored in the DHT.
+func UnmarshalDBRecord(data []byte) (*DBRecord, error) {
+	var record DBRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("invalid db record: %w", err)
+	}
+	return &record, nil
+}
+
+// Marshal encodes the record for storage in the DHT.
+func (r *DBRecord) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func (r *DBRecord) signedBytes() ([]byte, error) {
+	unsigned := *r
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
+// Sign signs the record with privKey, which must match PublicKey.
+func (r *DBRecord) Sign(privKey crypto.PrivKey) error {
+	data, err := r.signedBytes()
+	if err != nil {
+		return err
+	}
+	r.Signature, err = consensus.SignData(privKey, data)
+	return err
+}
+
+// Verify checks the signature against the embedded public key and returns the writer.
+func (r *DBRecord) Verify() (peer.ID, error) {
+	pubKey, err := crypto.UnmarshalPublicKey(r.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid db record public key: %w", err)
+	}
+	writer, err := peer.IDFromPublicKey(pubKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := r.signedBytes()
+	if err != nil {
+		return "", err
+	}
+	valid, err := consensus.VerifySignature(pubKey, data, hex.EncodeToString(r.Signature))
+	if err != nil || !valid {
+		return "", ErrDBRecordInvalidSig
+	}
+	if r.Writer != "" && r.Writer != writer.String() {
+		return "", ErrDBRecordWriter
+	}
+	return writer, nil
+}
+
+// DBWriterAuthorizer decides whether writer may store records under key.
+type DBWriterAuthorizer func(writer peer.ID, key string) bool
+
+// DBValueChecker validates the value of a record stored under key.
+type DBValueChecker func(key string, value []byte) error
+
+// DBValidator is the DHT record validator of the /db namespace. A record is valid when
+// it is signed for the key it is stored under and Authorize accepts its writer; with
+// no Authorize set every record is rejected. When CheckValue is set, the values of all
+// records but tombstones and private records must pass it as well, the values of private
+// records are sealed and checked by their writer before they are encrypted.
+type DBValidator struct {
+	Authorize  DBWriterAuthorizer
+	CheckValue DBValueChecker
+	// Clock is the node clock that expiry and future timestamps are checked against.
+	Clock clock.Clock
+}
+
+// NewDBValidator creates a validator that admits the writers accepted by authorize.
+func NewDBValidator(clk clock.Clock, authorize DBWriterAuthorizer) *DBValidator {
+	return &DBValidator{Authorize: authorize, Clock: clk}
+}
+
+// Validate implements record.Validator.
+func (v *DBValidator) Validate(key string, value []byte) error {
+	_, err := v.validate(key, value)
+	return err
+}
+
+func (v *DBValidator) validate(key string, value []byte) (*DBRecord, error) {
+	record, err := UnmarshalDBRecord(value)
+	if err != nil {
+		return nil, err
+	}
+	if record.Key != key {
+		return nil, ErrDBRecordKeyMismatch
+	}
+	now := v.Clock.Now()
+	if record.Expired(now) {
+		return nil, ErrDBRecordExpired
+	}
+	if record.Version().Time().Sub(now) > clock.MaxHLCOffset {
+		return nil, ErrDBRecordFromFuture
+	}
+	writer, err := record.Verify()
+	if err != nil {
+		return nil, err
+	}
+	if v.Authorize == nil || !v.Authorize(writer, key) {
+		return nil, fmt.Errorf("%w: %s", ErrDBRecordUnauthorized, writer)
+	}
+	if v.CheckValue != nil && !record.Deleted && !record.Private {
+		if err := v.CheckValue(key, record.Value); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// Select implements record.Validator. It picks the newest valid record under
+// last-writer-wins, see DBRecord.Compare.
+func (v *DBValidator) Select(key string, values [][]byte) (int, error) {
+	best := -1
+	var bestRecord *DBRecord
+	distinct := false
+	for i, value := range values {
+		record, err := v.validate(key, value)
+		if err != nil {
+			continue
+		}
+		if bestRecord != nil && record.Compare(bestRecord) != 0 {
+			distinct = true
+		}
+		if bestRecord == nil || record.NewerThan(bestRecord) {
+			best, bestRecord = i, record
+		}
+	}
+	if best < 0 {
+		return 0, ErrNoValidDBRecord
+	}
+	if distinct {
+		dbConflicts.Add(1)
+	}
+	return best, nil
+}
+
+// DBConflicts returns the number of times Select chose between distinct valid
+// versions of a key, i.e. replicas or concurrent writers disagreed.
+func DBConflicts() uint64 {
+	return dbConflicts.Load()
+}
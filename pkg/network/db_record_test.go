@@ -0,0 +1,187 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
+)
+
+// testHLC timestamps the records of tests that do not move the clock.
+var testHLC = clock.NewHLC(clock.Wall())
+
+func newTestWriter(t *testing.T) (crypto.PrivKey, peer.ID) {
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(privKey)
+	require.NoError(t, err)
+	return privKey, id
+}
+
+func marshalRecord(t *testing.T, privKey crypto.PrivKey, key string, value string, seq uint64) []byte {
+	record, err := NewDBRecord(testHLC, privKey, key, []byte(value), seq, 0)
+	require.NoError(t, err)
+	data, err := record.Marshal()
+	require.NoError(t, err)
+	return data
+}
+
+func TestDBValidator(t *testing.T) {
+	writerKey, writer := newTestWriter(t)
+	otherKey, _ := newTestWriter(t)
+	validator := NewDBValidator(clock.Wall(), func(p peer.ID, _ string) bool { return p == writer })
+
+	assert.NoError(t, validator.Validate("/db/a", marshalRecord(t, writerKey, "/db/a", "v", 1)))
+
+	err := validator.Validate("/db/a", marshalRecord(t, otherKey, "/db/a", "v", 1))
+	assert.ErrorIs(t, err, ErrDBRecordUnauthorized)
+
+	err = validator.Validate("/db/b", marshalRecord(t, writerKey, "/db/a", "v", 1))
+	assert.ErrorIs(t, err, ErrDBRecordKeyMismatch)
+
+	record, err := NewDBRecord(testHLC, writerKey, "/db/a", []byte("v"), 1, 0)
+	require.NoError(t, err)
+	record.Value = []byte("tampered")
+	tampered, err := record.Marshal()
+	require.NoError(t, err)
+	assert.ErrorIs(t, validator.Validate("/db/a", tampered), ErrDBRecordInvalidSig)
+
+	assert.Error(t, validator.Validate("/db/a", []byte("raw value")))
+	assert.Error(t, NewDBValidator(clock.Wall(), nil).Validate("/db/a", marshalRecord(t, writerKey, "/db/a", "v", 1)))
+}
+
+func TestDBValidatorSelect(t *testing.T) {
+	writerKey, writer := newTestWriter(t)
+	otherKey, _ := newTestWriter(t)
+	validator := NewDBValidator(clock.Wall(), func(p peer.ID, _ string) bool { return p == writer })
+
+	// the last write wins, records are created in order
+	v1 := marshalRecord(t, writerKey, "/db/a", "v1", 1)
+	v2 := marshalRecord(t, writerKey, "/db/a", "v2", 2)
+	v3 := marshalRecord(t, writerKey, "/db/a", "v3", 3)
+	values := [][]byte{v2, marshalRecord(t, otherKey, "/db/a", "forged", 9), v3, v1}
+	conflicts := DBConflicts()
+	best, err := validator.Select("/db/a", values)
+	require.NoError(t, err)
+	assert.Equal(t, 2, best)
+	assert.Equal(t, conflicts+1, DBConflicts())
+
+	_, err = validator.Select("/db/a", [][]byte{values[1]})
+	assert.ErrorIs(t, err, ErrNoValidDBRecord)
+}
+
+func TestDBRecordLastWriterWins(t *testing.T) {
+	mock := clock.NewMock()
+	hlc := clock.NewHLC(mock)
+	aKey, a := newTestWriter(t)
+	bKey, b := newTestWriter(t)
+	validator := NewDBValidator(mock, func(peer.ID, string) bool { return true })
+
+	// a higher sequence number does not beat a later write
+	older, err := NewDBRecord(hlc, aKey, "/db/k", []byte("a"), 5, 0)
+	require.NoError(t, err)
+	newer, err := NewDBRecord(hlc, bKey, "/db/k", []byte("b"), 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, b.String(), newer.Writer)
+	assert.True(t, newer.NewerThan(older))
+	best, err := validator.Select("/db/k", [][]byte{mustMarshal(t, newer), mustMarshal(t, older)})
+	require.NoError(t, err)
+	assert.Equal(t, 0, best)
+
+	// concurrent writes with the same timestamp are ordered by writer ID
+	ts := hlc.Now()
+	concurrent := make([]*DBRecord, 2)
+	for i, key := range []crypto.PrivKey{aKey, bKey} {
+		concurrent[i], err = NewDBRecord(hlc, key, "/db/k", []byte("c"), 6, 0)
+		require.NoError(t, err)
+		concurrent[i].HLC = &ts
+		require.NoError(t, concurrent[i].Sign(key))
+	}
+	assert.Equal(t, a.String() > b.String(), concurrent[0].NewerThan(concurrent[1]))
+	assert.Equal(t, -concurrent[0].Compare(concurrent[1]), concurrent[1].Compare(concurrent[0]))
+
+	// the writer must match the key that signed the record
+	forged, err := NewDBRecord(hlc, aKey, "/db/k", []byte("x"), 7, 0)
+	require.NoError(t, err)
+	forged.Writer = b.String()
+	require.NoError(t, forged.Sign(aKey))
+	assert.ErrorIs(t, validator.Validate("/db/k", mustMarshal(t, forged)), ErrDBRecordWriter)
+
+	// records from a clock far ahead are rejected instead of winning every conflict
+	future, err := NewDBRecord(hlc, aKey, "/db/k", []byte("f"), 8, 0)
+	require.NoError(t, err)
+	future.HLC = &clock.Timestamp{Wall: mock.Now().Add(time.Hour).UnixNano()}
+	require.NoError(t, future.Sign(aKey))
+	assert.ErrorIs(t, validator.Validate("/db/k", mustMarshal(t, future)), ErrDBRecordFromFuture)
+}
+
+func TestDBRecordExpiryAndTombstones(t *testing.T) {
+	mock := clock.NewMock()
+	hlc := clock.NewHLC(mock)
+	writerKey, writer := newTestWriter(t)
+	validator := NewDBValidator(mock, func(p peer.ID, _ string) bool { return p == writer })
+
+	record, err := NewDBRecord(hlc, writerKey, "/db/a", []byte("v"), 1, time.Minute)
+	require.NoError(t, err)
+	data, err := record.Marshal()
+	require.NoError(t, err)
+	assert.True(t, record.Live(mock.Now()))
+	assert.NoError(t, validator.Validate("/db/a", data))
+
+	mock.Add(time.Minute)
+	assert.False(t, record.Live(mock.Now()))
+	assert.ErrorIs(t, validator.Validate("/db/a", data), ErrDBRecordExpired)
+
+	previous := marshalRecord(t, writerKey, "/db/a", "v", 1)
+	tombstone, err := NewDBTombstone(hlc, writerKey, "/db/a", 2)
+	require.NoError(t, err)
+	assert.False(t, tombstone.Live(mock.Now()))
+	assert.Empty(t, tombstone.Value)
+
+	// the tombstone replaces older versions
+	best, err := validator.Select("/db/a", [][]byte{previous, mustMarshal(t, tombstone)})
+	require.NoError(t, err)
+	assert.Equal(t, 1, best)
+
+	mock.Add(DBTombstoneTTL)
+	assert.True(t, tombstone.Expired(mock.Now()))
+}
+
+func mustMarshal(t *testing.T, record *DBRecord) []byte {
+	data, err := record.Marshal()
+	require.NoError(t, err)
+	return data
+}
+
+func TestPrivateDBRecord(t *testing.T) {
+	writerKey, writer := newTestWriter(t)
+	readerKey, _ := newTestWriter(t)
+	strangerKey, _ := newTestWriter(t)
+	validator := NewDBValidator(clock.Wall(), func(p peer.ID, _ string) bool { return p == writer })
+
+	record, err := NewPrivateDBRecord(testHLC, writerKey, "/db/a", []byte("secret"), []crypto.PubKey{readerKey.GetPublic()}, 1, 0)
+	require.NoError(t, err)
+	assert.True(t, record.Private)
+	assert.NotContains(t, string(record.Value), "secret")
+	assert.NoError(t, validator.Validate("/db/a", mustMarshal(t, record)))
+
+	for _, key := range []crypto.PrivKey{writerKey, readerKey} {
+		value, err := record.Open(key)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("secret"), value)
+	}
+	_, err = record.Open(strangerKey)
+	assert.ErrorIs(t, err, masacrypto.ErrAccessDenied)
+
+	// the sealed value of a private record is not checked against the schema
+	validator.CheckValue = func(string, []byte) error { return errors.New("schema violation") }
+	assert.NoError(t, validator.Validate("/db/a", mustMarshal(t, record)))
+	assert.Error(t, validator.Validate("/db/a", marshalRecord(t, writerKey, "/db/a", "v", 2)))
+}
@@ -0,0 +1,148 @@
+package network
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+// DBWatchBuffer is the number of updates buffered for a watcher. Updates for a watcher
+// that does not keep up are dropped.
+const DBWatchBuffer = 64
+
+// DBUpdate notifies watchers of a new version of a key, it carries the signed record.
+type DBUpdate struct {
+	// Key is the key without the /db/ prefix.
+	Key    string
+	Record *DBRecord
+}
+
+// DBWatcher delivers the changes of shared data to local watchers. Writers announce
+// their records on the data updates topic with Announce, the records received there
+// are validated like records stored in the DHT before they are delivered.
+type DBWatcher struct {
+	Validator *DBValidator
+	// HLC is the clock of the node, it is advanced past the records received.
+	HLC *clock.HLC
+	// Publish sends an announcement to the data updates topic, it is set once the node
+	// subscribed to the topic.
+	Publish func(data []byte) error
+
+	mu      sync.RWMutex
+	watches map[*DBWatch]struct{}
+}
+
+// DBWatch is the subscription of a watcher to the keys starting with a prefix.
+type DBWatch struct {
+	prefix  string
+	updates chan DBUpdate
+	dropped atomic.Uint64
+	watcher *DBWatcher
+	once    sync.Once
+}
+
+// NewDBWatcher creates a watcher hub that accepts the records validator accepts and
+// advances hlc past them.
+func NewDBWatcher(validator *DBValidator, hlc *clock.HLC) *DBWatcher {
+	return &DBWatcher{
+		Validator: validator,
+		HLC:       hlc,
+		watches:   make(map[*DBWatch]struct{}),
+	}
+}
+
+// Watch subscribes to the updates of the keys starting with prefix, an empty prefix
+// watches every key. The watch must be closed when it is no longer used.
+func (w *DBWatcher) Watch(prefix string) *DBWatch {
+	watch := &DBWatch{
+		prefix:  dbWatchKey(prefix),
+		updates: make(chan DBUpdate, DBWatchBuffer),
+		watcher: w,
+	}
+	w.mu.Lock()
+	w.watches[watch] = struct{}{}
+	w.mu.Unlock()
+	return watch
+}
+
+// Updates returns the channel the updates are delivered on. It is closed by Close.
+func (d *DBWatch) Updates() <-chan DBUpdate {
+	return d.updates
+}
+
+// Dropped returns the number of updates dropped because the watch did not keep up.
+func (d *DBWatch) Dropped() uint64 {
+	return d.dropped.Load()
+}
+
+// Close ends the watch.
+func (d *DBWatch) Close() {
+	d.once.Do(func() {
+		d.watcher.mu.Lock()
+		delete(d.watcher.watches, d)
+		d.watcher.mu.Unlock()
+		close(d.updates)
+	})
+}
+
+// Announce delivers a record written by this node to the local watchers and
+// publishes it on the data updates topic. A nil watcher ignores the record.
+func (w *DBWatcher) Announce(envelope []byte) {
+	if w == nil {
+		return
+	}
+	record, err := UnmarshalDBRecord(envelope)
+	if err != nil {
+		return
+	}
+	w.deliver(record)
+	if w.Publish != nil {
+		if err := w.Publish(envelope); err != nil {
+			logrus.Warnf("Failed to announce the update of %s: %v", record.Key, err)
+		}
+	}
+}
+
+// HandleMessage implements pubsub.SubscriptionHandler for the data updates topic.
+func (w *DBWatcher) HandleMessage(msg *pubsub.Message) {
+	record, err := UnmarshalDBRecord(msg.Data)
+	if err != nil {
+		logrus.Debugf("Ignoring invalid data update from %s: %v", msg.ReceivedFrom, err)
+		return
+	}
+	if w.Validator == nil || !strings.HasPrefix(record.Key, "/db/") {
+		return
+	}
+	if err := w.Validator.Validate(record.Key, msg.Data); err != nil {
+		logrus.Debugf("Ignoring data update of %s from %s: %v", record.Key, msg.ReceivedFrom, err)
+		return
+	}
+	_ = w.HLC.Update(record.Version())
+	w.deliver(record)
+}
+
+func (w *DBWatcher) deliver(record *DBRecord) {
+	update := DBUpdate{Key: dbWatchKey(record.Key), Record: record}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for watch := range w.watches {
+		if !strings.HasPrefix(update.Key, watch.prefix) {
+			continue
+		}
+		select {
+		case watch.updates <- update:
+		default:
+			watch.dropped.Add(1)
+		}
+	}
+}
+
+// dbWatchKey strips the /db/ prefix, so keys and prefixes may be given in either form.
+func dbWatchKey(key string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, "/"), "db/")
+}
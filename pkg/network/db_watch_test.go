@@ -0,0 +1,63 @@
+package network
+
+import (
+	"testing"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+func TestDBWatcher(t *testing.T) {
+	writerKey, writer := newTestWriter(t)
+	otherKey, _ := newTestWriter(t)
+	watcher := NewDBWatcher(NewDBValidator(clock.Wall(), func(p peer.ID, _ string) bool { return p == writer }), testHLC)
+	var published [][]byte
+	watcher.Publish = func(data []byte) error {
+		published = append(published, data)
+		return nil
+	}
+
+	all := watcher.Watch("")
+	twitter := watcher.Watch("/db/twitter/")
+	defer all.Close()
+
+	watcher.Announce(marshalRecord(t, writerKey, "/db/twitter/a", `"a"`, 1))
+	watcher.Announce(marshalRecord(t, writerKey, "/db/other", `"o"`, 1))
+	assert.Len(t, published, 2)
+	assert.Equal(t, "twitter/a", (<-all.Updates()).Key)
+	assert.Equal(t, "other", (<-all.Updates()).Key)
+	update := <-twitter.Updates()
+	assert.Equal(t, "twitter/a", update.Key)
+	assert.Equal(t, []byte(`"a"`), update.Record.Value)
+	assert.Empty(t, twitter.Updates())
+
+	// updates from the topic are validated like DHT records
+	message := func(data []byte) *pubsub.Message {
+		return &pubsub.Message{Message: &pb.Message{Data: data}}
+	}
+	watcher.HandleMessage(message(marshalRecord(t, otherKey, "/db/twitter/b", `"forged"`, 2)))
+	watcher.HandleMessage(message([]byte("garbage")))
+	assert.Empty(t, twitter.Updates())
+	watcher.HandleMessage(message(marshalRecord(t, writerKey, "/db/twitter/b", `"b"`, 1)))
+	assert.Equal(t, "twitter/b", (<-twitter.Updates()).Key)
+	<-all.Updates()
+
+	// slow watchers lose updates instead of blocking the others
+	for i := 0; i < DBWatchBuffer+3; i++ {
+		watcher.Announce(marshalRecord(t, writerKey, "/db/twitter/c", `"c"`, 1))
+	}
+	assert.Equal(t, uint64(3), twitter.Dropped())
+
+	twitter.Close()
+	twitter.Close()
+	for range twitter.Updates() {
+	}
+	watcher.Announce(marshalRecord(t, writerKey, "/db/twitter/d", `"d"`, 1))
+	_, ok := <-twitter.Updates()
+	require.False(t, ok)
+}
@@ -0,0 +1,234 @@
+package network
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	kbucket "github.com/libp2p/go-libp2p-kbucket"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+const maxDialFailures = 100
+
+// DialFailure is a failed outbound connection attempt made by this node.
+type DialFailure struct {
+	PeerID string    `json:"peerId"`
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+}
+
+// ConnectivityMonitor keeps the connectivity state libp2p does not retain on its own:
+// the latest reachability reported by AutoNAT and the most recent dial failures.
+type ConnectivityMonitor struct {
+	clock        clock.Clock
+	mu           sync.RWMutex
+	reachability network.Reachability
+	dialFailures []DialFailure
+}
+
+// NewConnectivityMonitor creates a monitor without reachability or dial failures that
+// timestamps failures with clk.
+func NewConnectivityMonitor(clk clock.Clock) *ConnectivityMonitor {
+	return &ConnectivityMonitor{clock: clk}
+}
+
+// WatchReachability records reachability changes of the host until the context is done.
+func (m *ConnectivityMonitor) WatchReachability(ctx context.Context, h host.Host) error {
+	sub, err := h.EventBus().Subscribe(new(event.EvtLocalReachabilityChanged))
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case evt, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				reachability := evt.(event.EvtLocalReachabilityChanged).Reachability
+				logrus.Infof("Reachability changed to %s", reachability)
+				m.mu.Lock()
+				m.reachability = reachability
+				m.mu.Unlock()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// MonitorDials returns h with the dials made through Connect and NewStream recorded
+// in the monitor when they fail. The DHT, pubsub and the discovery of a node share its
+// host, so all of their dials are recorded.
+func (m *ConnectivityMonitor) MonitorDials(h host.Host) host.Host {
+	return &dialMonitor{Host: h, monitor: m}
+}
+
+// RecordDialFailure adds a dial failure, keeping the latest maxDialFailures entries.
+func (m *ConnectivityMonitor) RecordDialFailure(p peer.ID, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dialFailures = append(m.dialFailures, DialFailure{PeerID: p.String(), Reason: err.Error(), Time: m.clock.Now()})
+	if len(m.dialFailures) > maxDialFailures {
+		m.dialFailures = m.dialFailures[len(m.dialFailures)-maxDialFailures:]
+	}
+}
+
+// Reachability returns the reachability last reported for the host.
+func (m *ConnectivityMonitor) Reachability() network.Reachability {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.reachability
+}
+
+// DialFailures returns the recorded dial failures, most recent first.
+func (m *ConnectivityMonitor) DialFailures() []DialFailure {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]DialFailure, len(m.dialFailures))
+	for i, f := range m.dialFailures {
+		result[len(result)-1-i] = f
+	}
+	return result
+}
+
+// RoutingTablePeer describes a peer in the DHT routing table.
+type RoutingTablePeer struct {
+	PeerID                        string    `json:"peerId"`
+	AddedAt                       time.Time `json:"addedAt"`
+	LastUsefulAt                  time.Time `json:"lastUsefulAt,omitempty"`
+	LastSuccessfulOutboundQueryAt time.Time `json:"lastSuccessfulOutboundQueryAt,omitempty"`
+}
+
+// RoutingTableBucket groups routing table peers by the length of the prefix their DHT
+// key shares with ours, which is how kademlia assigns peers to buckets.
+type RoutingTableBucket struct {
+	CommonPrefixLen int                `json:"commonPrefixLen"`
+	Peers           []RoutingTablePeer `json:"peers"`
+}
+
+// GetRoutingTableBuckets returns the routing table contents grouped by bucket.
+func GetRoutingTableBuckets(kdht *dht.IpfsDHT) []RoutingTableBucket {
+	localKey := kbucket.ConvertPeerID(kdht.PeerID())
+	byCpl := make(map[int][]RoutingTablePeer)
+	for _, info := range kdht.RoutingTable().GetPeerInfos() {
+		cpl := kbucket.CommonPrefixLen(localKey, kbucket.ConvertPeerID(info.Id))
+		byCpl[cpl] = append(byCpl[cpl], RoutingTablePeer{
+			PeerID:                        info.Id.String(),
+			AddedAt:                       info.AddedAt,
+			LastUsefulAt:                  info.LastUsefulAt,
+			LastSuccessfulOutboundQueryAt: info.LastSuccessfulOutboundQueryAt,
+		})
+	}
+	buckets := make([]RoutingTableBucket, 0, len(byCpl))
+	for cpl, peers := range byCpl {
+		buckets = append(buckets, RoutingTableBucket{CommonPrefixLen: cpl, Peers: peers})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].CommonPrefixLen < buckets[j].CommonPrefixLen
+	})
+	return buckets
+}
+
+// ConnectionInfo describes one open connection to a peer.
+type ConnectionInfo struct {
+	RemoteAddr string    `json:"remoteAddr"`
+	Direction  string    `json:"direction"`
+	Transport  string    `json:"transport"`
+	Opened     time.Time `json:"opened"`
+	Transient  bool      `json:"transient"`
+}
+
+// PeerDiagnostics is what the peerstore and the swarm know about a connected peer.
+type PeerDiagnostics struct {
+	PeerID       string           `json:"peerId"`
+	AgentVersion string           `json:"agentVersion,omitempty"`
+	Protocols    []string         `json:"protocols"`
+	Latency      string           `json:"latency,omitempty"`
+	InRouting    bool             `json:"inRoutingTable"`
+	Connections  []ConnectionInfo `json:"connections"`
+}
+
+// GetPeerDiagnostics returns protocol, agent, latency and connection details for every connected peer.
+func GetPeerDiagnostics(h host.Host, kdht *dht.IpfsDHT) []PeerDiagnostics {
+	peers := h.Network().Peers()
+	result := make([]PeerDiagnostics, 0, len(peers))
+	for _, p := range peers {
+		pd := PeerDiagnostics{PeerID: p.String(), Protocols: []string{}}
+		if agent, err := h.Peerstore().Get(p, "AgentVersion"); err == nil {
+			pd.AgentVersion, _ = agent.(string)
+		}
+		if protocols, err := h.Peerstore().GetProtocols(p); err == nil {
+			for _, proto := range protocols {
+				pd.Protocols = append(pd.Protocols, string(proto))
+			}
+			sort.Strings(pd.Protocols)
+		}
+		if latency := h.Peerstore().LatencyEWMA(p); latency > 0 {
+			pd.Latency = latency.String()
+		}
+		if kdht != nil {
+			pd.InRouting = kdht.RoutingTable().Find(p) != ""
+		}
+		for _, conn := range h.Network().ConnsToPeer(p) {
+			stat := conn.Stat()
+			pd.Connections = append(pd.Connections, ConnectionInfo{
+				RemoteAddr: conn.RemoteMultiaddr().String(),
+				Direction:  stat.Direction.String(),
+				Transport:  conn.ConnState().Transport,
+				Opened:     stat.Opened,
+				Transient:  stat.Transient,
+			})
+		}
+		result = append(result, pd)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].PeerID < result[j].PeerID })
+	return result
+}
+
+// DHTMode returns "client" or "server" depending on the mode the DHT currently runs in.
+func DHTMode(kdht *dht.IpfsDHT) string {
+	if kdht.Mode() == dht.ModeServer {
+		return "server"
+	}
+	return "client"
+}
+
+// dialMonitor records the dials of the host that fail.
+type dialMonitor struct {
+	host.Host
+	monitor *ConnectivityMonitor
+}
+
+func (h *dialMonitor) Connect(ctx context.Context, pi peer.AddrInfo) error {
+	err := h.Host.Connect(ctx, pi)
+	h.recordFailure(pi.ID, err)
+	return err
+}
+
+func (h *dialMonitor) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	h.recordFailure(p, err)
+	return s, err
+}
+
+// recordFailure records err unless the peer is connected, which means the dial
+// succeeded and a later step such as protocol negotiation failed.
+func (h *dialMonitor) recordFailure(p peer.ID, err error) {
+	if err == nil || h.Network().Connectedness(p) == network.Connected {
+		return
+	}
+	h.monitor.RecordDialFailure(p, err)
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+func TestConnectivityMonitorRecordsFailedDials(t *testing.T) {
+	net := mocknet.New()
+	defer net.Close()
+	local, err := net.GenPeer()
+	require.NoError(t, err)
+	linked, err := net.GenPeer()
+	require.NoError(t, err)
+	unlinked, err := net.GenPeer()
+	require.NoError(t, err)
+	_, err = net.LinkPeers(local.ID(), linked.ID())
+	require.NoError(t, err)
+	monitor := NewConnectivityMonitor(clock.Wall())
+	h := monitor.MonitorDials(local)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	require.NoError(t, h.Connect(ctx, linked.Peerstore().PeerInfo(linked.ID())))
+	assert.Empty(t, monitor.DialFailures())
+
+	// a failed protocol negotiation with a connected peer is not a dial failure
+	_, err = h.NewStream(ctx, linked.ID(), "/masa/missing")
+	assert.Error(t, err)
+	assert.Empty(t, monitor.DialFailures())
+
+	err = h.Connect(ctx, unlinked.Peerstore().PeerInfo(unlinked.ID()))
+	require.Error(t, err)
+	_, err = h.NewStream(ctx, unlinked.ID(), "/masa/missing")
+	require.Error(t, err)
+	failures := monitor.DialFailures()
+	require.Len(t, failures, 2)
+	for _, failure := range failures {
+		assert.Equal(t, unlinked.ID().String(), failure.PeerID)
+		assert.NotEmpty(t, failure.Reason)
+	}
+}
+
+func TestConnectivityMonitorKeepsLatestFailures(t *testing.T) {
+	mock := clock.NewMock()
+	monitor := NewConnectivityMonitor(mock)
+	for i := 0; i < maxDialFailures+10; i++ {
+		monitor.RecordDialFailure(peer.ID(rune('a'+i%26)), errors.New("unreachable"))
+	}
+	monitor.RecordDialFailure("latest", errors.New("unreachable"))
+
+	failures := monitor.DialFailures()
+	assert.Len(t, failures, maxDialFailures)
+	assert.Equal(t, peer.ID("latest").String(), failures[0].PeerID)
+	assert.Equal(t, mock.Now(), failures[0].Time)
+}
+
+func TestConnectivityMonitorWatchesReachability(t *testing.T) {
+	net := mocknet.New()
+	defer net.Close()
+	h, err := net.GenPeer()
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	monitor := NewConnectivityMonitor(clock.Wall())
+	require.NoError(t, monitor.WatchReachability(ctx, h))
+	assert.Equal(t, network.ReachabilityUnknown, monitor.Reachability())
+
+	emitter, err := h.EventBus().Emitter(new(event.EvtLocalReachabilityChanged))
+	require.NoError(t, err)
+	defer emitter.Close()
+	require.NoError(t, emitter.Emit(event.EvtLocalReachabilityChanged{Reachability: network.ReachabilityPublic}))
+	assert.Eventually(t, func() bool {
+		return monitor.Reachability() == network.ReachabilityPublic
+	}, time.Second*5, time.Millisecond*10)
+}
@@ -2,34 +2,45 @@ package network
 
 import (
 	"context"
-	"github.com/libp2p/go-libp2p/core/peer"
-	"github.com/multiformats/go-multiaddr"
+	"math/rand"
 	"time"
 
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/discovery/backoff"
 	"github.com/libp2p/go-libp2p/p2p/discovery/routing"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	discoveryMinBackoff   = time.Second * 10
+	discoveryMaxBackoff   = time.Minute * 5
+	advertiseRetryDelay   = time.Second * 30
+	findPeersTimeout      = time.Minute
+	discoveryDialTimeout  = time.Second * 30
+	discoveryBackoffBase  = 2.0
+	discoveryBackoffUnits = time.Second * 10
+)
+
+// Discover advertises this node under the given protocol and keeps looking for other
+// peers advertising the same protocol. Advertisements are renewed shortly before the
+// TTL returned by the DHT expires, and peer lookups back off exponentially while they
+// turn up no new peers, resetting as soon as a lookup connects to someone new.
 func Discover(ctx context.Context, host host.Host, dht *dht.IpfsDHT, protocol protocol.ID) {
 	protocolString := string(protocol)
 	logrus.Infof("Discovering peers for protocol: %s", protocolString)
 	routingDiscovery := routing.NewRoutingDiscovery(dht)
 
-	// Advertise this node right away, then it will re-advertise with each ticker interval
-	logrus.Infof("Attempting to advertise protocol: %s", protocolString)
-	_, err := routingDiscovery.Advertise(ctx, protocolString)
-	if err != nil {
-		logrus.Warnf("Failed to advertise protocol: %v", err)
-	} else {
-		logrus.Infof("Successfully advertised protocol")
-	}
+	search := newPeerSearch(rand.NewSource(time.Now().UnixNano()))
 
-	ticker := time.NewTicker(time.Second * 10) // 120
-	defer ticker.Stop()
+	// Advertise and search right away, the timers are rescheduled after each run
+	advertiseTimer := time.NewTimer(0)
+	defer advertiseTimer.Stop()
+	findTimer := time.NewTimer(0)
+	defer findTimer.Stop()
 
 	for {
 		select {
@@ -39,61 +50,86 @@ func Discover(ctx context.Context, host host.Host, dht *dht.IpfsDHT, protocol pr
 			}
 			logrus.Info("Stopping peer discovery")
 			return
-		case <-ticker.C:
+		case <-advertiseTimer.C:
+			advertiseTimer.Reset(advertise(ctx, routingDiscovery, protocolString))
+		case <-findTimer.C:
 			logrus.Debug("Searching for other peers...")
+			delay := search.next(findPeers(ctx, host, routingDiscovery, protocolString))
+			logrus.Debugf("Next peer search in %s", delay)
+			findTimer.Reset(delay)
+		}
+	}
+}
 
-			routingDiscovery := routing.NewRoutingDiscovery(dht)
+// peerSearch schedules the peer lookups of Discover.
+type peerSearch struct {
+	backoff backoff.BackoffStrategy
+}
 
-			// Advertise this node
-			logrus.Debugf("Attempting to advertise protocol: %s", protocolString)
-			_, err := routingDiscovery.Advertise(ctx, protocolString)
-			if err != nil {
-				logrus.Warnf("Failed to advertise protocol: %v", err)
-			} else {
-				logrus.Infof("Successfully advertised protocol")
-			}
+func newPeerSearch(rng rand.Source) *peerSearch {
+	return &peerSearch{backoff: backoff.NewExponentialBackoff(discoveryMinBackoff, discoveryMaxBackoff, backoff.FullJitter,
+		discoveryBackoffUnits, discoveryBackoffBase, 0, rng)()}
+}
 
-			// Use the routing discovery to find peers.
-			peerChan, err := routingDiscovery.FindPeers(ctx, protocolString)
-			if err != nil {
-				logrus.Errorf("Failed to find peers: %v", err)
-			} else {
-				logrus.Debug("Successfully started finding peers")
-			}
-			select {
-			case availPeer, ok := <-peerChan:
-				if !ok {
-					logrus.Info("Peer channel closed, restarting discovery")
-					break
-				}
-				availPeerAddrInfo := peer.AddrInfo{
-					ID:    availPeer.ID,
-					Addrs: []multiaddr.Multiaddr{},
-				}
-				hostAddrInfo := peer.AddrInfo{
-					ID:    host.ID(),
-					Addrs: []multiaddr.Multiaddr{},
-				}
-				if availPeerAddrInfo.ID == hostAddrInfo.ID {
-					logrus.Debugf("Skipping connect to self: %s", availPeer.String())
-					continue
-				}
-				logrus.Infof("Available Peer: %s", availPeer.String())
+// next returns the delay before the next lookup given the number of peers the last
+// lookup connected to. The delay grows while lookups find no one new.
+func (s *peerSearch) next(connected int) time.Duration {
+	if connected > 0 {
+		s.backoff.Reset()
+	}
+	return s.backoff.Delay()
+}
 
-				if host.Network().Connectedness(availPeer.ID) != network.Connected {
-					//err := host.Connect(ctx, availPeer)
-					_, err := host.Network().DialPeer(ctx, availPeer.ID)
-					if err != nil {
-						logrus.Warningf("Failed to connect to peer %s: %v", availPeer.ID.String(), err)
-						continue
-					}
-					logrus.Infof("Connected to peer %s", availPeer.ID.String())
-					//logrus.Infof("Connected to peer %s", conn.RemoteMultiaddr().String())
-				}
-			case <-ctx.Done():
-				logrus.Info("Stopping peer discovery")
-				return
-			}
+// advertise announces the protocol on the DHT and returns how long to wait before the
+// next announcement. The advertisement is renewed once 7/8 of its TTL has elapsed.
+func advertise(ctx context.Context, routingDiscovery *routing.RoutingDiscovery, protocolString string) time.Duration {
+	logrus.Debugf("Attempting to advertise protocol: %s", protocolString)
+	ttl, err := routingDiscovery.Advertise(ctx, protocolString)
+	if err != nil {
+		logrus.Warnf("Failed to advertise protocol: %v", err)
+		return advertiseRetryDelay
+	}
+	logrus.Infof("Successfully advertised protocol, ttl: %s", ttl)
+	return ttl - ttl/8
+}
+
+// findPeers drains the full result set of a DHT lookup for the protocol, connecting to
+// every peer that is not already connected. It returns the number of new connections.
+func findPeers(ctx context.Context, host host.Host, routingDiscovery *routing.RoutingDiscovery, protocolString string) int {
+	findCtx, cancel := context.WithTimeout(ctx, findPeersTimeout)
+	defer cancel()
+
+	peerChan, err := routingDiscovery.FindPeers(findCtx, protocolString)
+	if err != nil {
+		logrus.Errorf("Failed to find peers: %v", err)
+		return 0
+	}
+
+	found, connected := 0, 0
+	for availPeer := range peerChan {
+		if availPeer.ID == host.ID() {
+			logrus.Debugf("Skipping connect to self: %s", availPeer.String())
+			continue
+		}
+		found++
+		if host.Network().Connectedness(availPeer.ID) == network.Connected {
+			continue
 		}
+		logrus.Infof("Available Peer: %s", availPeer.String())
+		if err := connectPeer(ctx, host, availPeer); err != nil {
+			logrus.Warningf("Failed to connect to peer %s: %v", availPeer.ID.String(), err)
+			continue
+		}
+		connected++
+		logrus.Infof("Connected to peer %s", availPeer.ID.String())
 	}
+	logrus.Debugf("Peer search finished, found: %d, newly connected: %d", found, connected)
+	return connected
+}
+
+func connectPeer(ctx context.Context, host host.Host, addrInfo peer.AddrInfo) error {
+	dialCtx, cancel := context.WithTimeout(ctx, discoveryDialTimeout)
+	defer cancel()
+	// without addresses, Connect dials the addresses in the peerstore
+	return host.Connect(dialCtx, addrInfo)
 }
@@ -0,0 +1,74 @@
+package network
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/discovery/routing"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerSearchBackoff(t *testing.T) {
+	search := newPeerSearch(rand.NewSource(1))
+	assert.Equal(t, discoveryMinBackoff, search.next(0))
+
+	// lookups that connect to no one back off up to the maximum
+	var longest time.Duration
+	for i := 0; i < 20; i++ {
+		delay := search.next(0)
+		assert.GreaterOrEqual(t, delay, discoveryMinBackoff)
+		assert.LessOrEqual(t, delay, discoveryMaxBackoff)
+		longest = max(longest, delay)
+	}
+	assert.Greater(t, longest, discoveryMinBackoff*4)
+
+	// a lookup that connects to a new peer starts over
+	assert.Equal(t, discoveryMinBackoff, search.next(1))
+}
+
+func TestFindPeers(t *testing.T) {
+	net, err := mocknet.FullMeshLinked(3)
+	require.NoError(t, err)
+	defer net.Close()
+	hosts := net.Hosts()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+	dhts := make([]*dht.IpfsDHT, len(hosts))
+	// host 2 advertises as a DHT client, so host 1 only reaches it through the lookup
+	for i, h := range hosts {
+		mode := dht.ModeServer
+		if i == 2 {
+			mode = dht.ModeClient
+		}
+		dhts[i], err = dht.New(ctx, h, dht.Mode(mode), dht.ProtocolPrefix("/masa"))
+		require.NoError(t, err)
+		defer dhts[i].Close()
+	}
+	_, err = net.ConnectPeers(hosts[0].ID(), hosts[1].ID())
+	require.NoError(t, err)
+	_, err = net.ConnectPeers(hosts[0].ID(), hosts[2].ID())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return dhts[1].RoutingTable().Size() > 0 && dhts[2].RoutingTable().Size() > 0
+	}, time.Second*10, time.Millisecond*50)
+
+	const ns = "/masa/test"
+	require.Eventually(t, func() bool {
+		_, err := routing.NewRoutingDiscovery(dhts[2]).Advertise(ctx, ns)
+		return err == nil
+	}, time.Second*10, time.Millisecond*50)
+
+	// storing the provider record connected the advertiser to host 1
+	require.NoError(t, net.DisconnectPeers(hosts[1].ID(), hosts[2].ID()))
+	require.NotEqual(t, network.Connected, hosts[1].Network().Connectedness(hosts[2].ID()))
+	discovery := routing.NewRoutingDiscovery(dhts[1])
+	assert.Equal(t, 1, findPeers(ctx, hosts[1], discovery, ns))
+	assert.Equal(t, network.Connected, hosts[1].Network().Connectedness(hosts[2].ID()))
+	assert.Equal(t, 0, findPeers(ctx, hosts[1], discovery, ns))
+}
@@ -0,0 +1,41 @@
+package network_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/testharness"
+)
+
+func TestSignedDBRecordReplicates(t *testing.T) {
+	h := testharness.New(t, testharness.Options{Nodes: 3})
+	h.WaitForRoutingTables()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testharness.ConvergeTimeout)
+	defer cancel()
+
+	writer := h.Node(1)
+	key := "/db/" + writer.Host.ID().String()
+	record, err := network.NewDBRecord(writer.HLC, writer.KeyManager.Libp2pPrivKey, key, []byte("status"), 1, 0)
+	require.NoError(t, err)
+	envelope, err := record.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, writer.DHT.PutValue(ctx, key, envelope))
+
+	stored, err := h.Node(2).DHT.GetValue(ctx, key)
+	require.NoError(t, err)
+	got, err := network.UnmarshalDBRecord(stored)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("status"), got.Value)
+
+	// node 2 may not write under node 1's key
+	forged, err := network.NewDBRecord(h.Node(2).HLC, h.Node(2).KeyManager.Libp2pPrivKey, key, []byte("forged"), 2, 0)
+	require.NoError(t, err)
+	forgedEnvelope, err := forged.Marshal()
+	require.NoError(t, err)
+	assert.ErrorIs(t, h.Node(2).DHT.PutValue(ctx, key, forgedEnvelope), network.ErrDBRecordUnauthorized)
+}
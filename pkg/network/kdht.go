@@ -2,8 +2,11 @@ package network
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	dht "github.com/libp2p/go-libp2p-kad-dht"
@@ -14,6 +17,7 @@ import (
 	"github.com/multiformats/go-multiaddr"
 	"github.com/sirupsen/logrus"
 
+	"github.com/masa-finance/masa-oracle/pkg/clock"
 	"github.com/masa-finance/masa-oracle/pkg/pubsub"
 )
 
@@ -24,17 +28,15 @@ const (
 	PeerRemoved = "PeerRemoved"
 )
 
-type dbValidator struct{}
-
-func (dbValidator) Validate(_ string, _ []byte) error        { return nil }
-func (dbValidator) Select(_ string, _ [][]byte) (int, error) { return 0, nil }
-
 func WithDht(ctx context.Context, host host.Host, bootstrapNodes []multiaddr.Multiaddr,
-	protocolId, prefix protocol.ID, peerChan chan PeerEvent, isStaked bool) (*dht.IpfsDHT, error) {
+	protocolId, prefix protocol.ID, peerChan chan PeerEvent, isStaked bool, opts ...dht.Option) (*dht.IpfsDHT, error) {
 	options := make([]dht.Option, 0)
 	options = append(options, dht.Mode(dht.ModeAutoServer))
 	options = append(options, dht.ProtocolPrefix(prefix))
-	options = append(options, dht.NamespacedValidator("db", dbValidator{}))
+	// rejects every /db record unless the caller passes a validator with an authorizer
+	options = append(options, dht.NamespacedValidator("db", NewDBValidator(clock.Wall(), nil)))
+	// later options take precedence, so callers can override the defaults above
+	options = append(options, opts...)
 
 	kademliaDHT, err := dht.New(ctx, host, options...)
 	if err != nil {
@@ -69,6 +71,7 @@ func WithDht(ctx context.Context, host host.Host, bootstrapNodes []multiaddr.Mul
 
 	var wg sync.WaitGroup
 	peerConnectionCount := 0
+	var pskMismatchCount, bootstrapPeerCount atomic.Int32
 
 	for _, peerAddr := range bootstrapNodes {
 		peerInfo, err := peer.AddrInfoFromP2pAddr(peerAddr)
@@ -79,6 +82,7 @@ func WithDht(ctx context.Context, host host.Host, bootstrapNodes []multiaddr.Mul
 			logrus.Info("DHT Skipping connect to self")
 			continue
 		}
+		bootstrapPeerCount.Add(1)
 		// Add the bootstrap node to the DHT
 		added, err := kademliaDHT.RoutingTable().TryAddPeer(peerInfo.ID, true, false)
 		if err != nil {
@@ -97,6 +101,9 @@ func WithDht(ctx context.Context, host host.Host, bootstrapNodes []multiaddr.Mul
 
 			defer wg.Done()
 			if err := host.Connect(ctxWithTimeout, *peerInfo); err != nil {
+				if errors.Is(err, ErrPrivateNetworkMismatch) {
+					pskMismatchCount.Add(1)
+				}
 				logrus.Errorf("Failed to connect to bootstrap peer %s: %v", peerInfo.ID, err)
 				counter++
 				if counter >= maxRetries {
@@ -126,6 +133,9 @@ func WithDht(ctx context.Context, host host.Host, bootstrapNodes []multiaddr.Mul
 		}()
 	}
 	wg.Wait()
+	if bootstrapPeerCount.Load() > 0 && pskMismatchCount.Load() == bootstrapPeerCount.Load() {
+		return nil, fmt.Errorf("none of the boot nodes accepted the private network key: %w", ErrPrivateNetworkMismatch)
+	}
 	if len(bootstrapNodes) > 0 && peerConnectionCount == 0 {
 		log.Println("Unable to connect to a boot node at this time. Waiting...")
 	}
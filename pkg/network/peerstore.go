@@ -0,0 +1,101 @@
+package network
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoreds"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	peerstoreDir = "peerstore"
+	// KnownPeerAddrTTL is how long the addresses of a staked peer are remembered after
+	// the last contact, so a restarted node can reach it again without a boot node.
+	KnownPeerAddrTTL = time.Hour * 24 * 7
+	redialTimeout    = time.Second * 15
+)
+
+// NewPersistentPeerstore opens a leveldb-backed peerstore under masaDir. Addresses,
+// keys and protocols of known peers survive a restart of the node. Closing the
+// peerstore, which the host does when it is closed, also closes the datastore.
+func NewPersistentPeerstore(ctx context.Context, masaDir string) (peerstore.Peerstore, error) {
+	store, err := leveldb.NewDatastore(filepath.Join(masaDir, peerstoreDir), nil)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := pstoreds.NewPeerstore(ctx, store, pstoreds.DefaultOpts())
+	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	return &persistentPeerstore{Peerstore: ps, CertifiedAddrBook: ps, store: store}, nil
+}
+
+// persistentPeerstore closes the datastore of the peerstore with it. It keeps the
+// certified address book of the peerstore visible to identify.
+type persistentPeerstore struct {
+	peerstore.Peerstore
+	peerstore.CertifiedAddrBook
+	store *leveldb.Datastore
+}
+
+func (ps *persistentPeerstore) Close() error {
+	err := ps.Peerstore.Close()
+	if storeErr := ps.store.Close(); err == nil {
+		err = storeErr
+	}
+	return err
+}
+
+// RememberPeerAddrs records the addresses of a peer with KnownPeerAddrTTL so that
+// they outlive the connection-scoped TTLs that identify assigns.
+func RememberPeerAddrs(host host.Host, peerID peer.ID, addrs ...multiaddr.Multiaddr) {
+	if peerID == host.ID() || len(addrs) == 0 {
+		return
+	}
+	host.Peerstore().AddAddrs(peerID, addrs, KnownPeerAddrTTL)
+}
+
+// RedialKnownPeers dials every peer in the peerstore that has known addresses and for
+// which include returns true. It blocks until all dials finished and returns the number
+// of peers that are connected afterwards.
+func RedialKnownPeers(ctx context.Context, host host.Host, include func(peer.ID) bool) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	connected := 0
+
+	for _, p := range host.Peerstore().PeersWithAddrs() {
+		if p == host.ID() || !include(p) {
+			continue
+		}
+		if host.Network().Connectedness(p) == network.Connected {
+			connected++
+			continue
+		}
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			dialCtx, cancel := context.WithTimeout(ctx, redialTimeout)
+			defer cancel()
+			if err := host.Connect(dialCtx, host.Peerstore().PeerInfo(p)); err != nil {
+				logrus.Debugf("Failed to redial known peer %s: %v", p, err)
+				return
+			}
+			logrus.Infof("Reconnected to known peer %s", p)
+			mu.Lock()
+			connected++
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+	return connected
+}
@@ -0,0 +1,62 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentPeerstore(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	known := peer.ID("known")
+	addr := multiaddr.StringCast("/ip4/10.0.0.1/tcp/4001")
+
+	ps, err := NewPersistentPeerstore(ctx, dir)
+	require.NoError(t, err)
+	ps.AddAddrs(known, []multiaddr.Multiaddr{addr}, KnownPeerAddrTTL)
+	require.NoError(t, ps.Close())
+
+	// the datastore is released on close, so the next run can open it again
+	ps, err = NewPersistentPeerstore(ctx, dir)
+	require.NoError(t, err)
+	defer ps.Close()
+	assert.Equal(t, []multiaddr.Multiaddr{addr}, ps.Addrs(known))
+}
+
+func TestRedialKnownPeers(t *testing.T) {
+	net := mocknet.New()
+	defer net.Close()
+	local, err := net.GenPeer()
+	require.NoError(t, err)
+	staked, err := net.GenPeer()
+	require.NoError(t, err)
+	unstaked, err := net.GenPeer()
+	require.NoError(t, err)
+	unreachable, err := net.GenPeer()
+	require.NoError(t, err)
+	_, err = net.LinkPeers(local.ID(), staked.ID())
+	require.NoError(t, err)
+	_, err = net.LinkPeers(local.ID(), unstaked.ID())
+	require.NoError(t, err)
+	for _, h := range []peer.ID{staked.ID(), unstaked.ID(), unreachable.ID()} {
+		RememberPeerAddrs(local, h, net.Host(h).Addrs()...)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	include := func(p peer.ID) bool { return p != unstaked.ID() }
+	assert.Equal(t, 1, RedialKnownPeers(ctx, local, include))
+	assert.Equal(t, network.Connected, local.Network().Connectedness(staked.ID()))
+	assert.NotEqual(t, network.Connected, local.Network().Connectedness(unstaked.ID()))
+
+	// peers that are still connected count without a new dial
+	assert.Equal(t, 1, RedialKnownPeers(ctx, local, include))
+}
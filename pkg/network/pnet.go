@@ -0,0 +1,190 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/core/transport"
+	libp2pnet "github.com/libp2p/go-libp2p/p2p/net/pnet"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const (
+	swarmKeyHeader = "/key/swarm/psk/1.0.0/\n/base16/\n"
+	// pnetMagic is exchanged first on a connection protected by the pre-shared key, a
+	// peer with another key decrypts it to garbage.
+	pnetMagic = "/masa/pnet/1.0.0\n"
+	// PrivateNetworkHandshakeTimeout bounds the exchange of pnetMagic on dials.
+	PrivateNetworkHandshakeTimeout = time.Second * 15
+)
+
+// ErrPrivateNetworkMismatch is reported when a peer fails the private network handshake,
+// which happens when the two sides were started with different pre-shared keys.
+var ErrPrivateNetworkMismatch = errors.New("peer is not part of this private network or uses a different pre-shared key")
+
+// LoadSwarmKey reads a pre-shared key in the libp2p v1 swarm key format.
+func LoadSwarmKey(path string) (pnet.PSK, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("private network key: %w", err)
+	}
+	defer f.Close()
+	psk, err := pnet.DecodeV1PSK(f)
+	if err != nil {
+		return nil, fmt.Errorf("private network key %s: %w", path, err)
+	}
+	return psk, nil
+}
+
+// GenerateSwarmKey writes a new random pre-shared key to path. It refuses to overwrite
+// an existing key, since that would cut the node off from its private network.
+func GenerateSwarmKey(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("private network key already exists: %s", path)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(swarmKeyHeader+hex.EncodeToString(key)+"\n"), 0600)
+}
+
+// MismatchHandler is told about the peers that failed the private network handshake.
+// The peer ID of inbound connections is not known yet, only their address.
+type MismatchHandler func(remote multiaddr.Multiaddr, dir network.Direction)
+
+// PrivateNetworkTransport is the TCP transport of a node in private network mode. It
+// replaces libp2p.PrivateNetwork: connections are protected with psk the same way, then
+// both sides exchange a known message through the protection, so a peer with another
+// key is told apart from other handshake failures. Dials to such a peer fail with
+// ErrPrivateNetworkMismatch, and onMismatch, which may be nil, is told about the peers
+// on both sides. Relayed connections run over these protected connections.
+func PrivateNetworkTransport(psk pnet.PSK, onMismatch MismatchHandler) libp2p.Option {
+	return libp2p.Transport(func(upgrader transport.Upgrader, rcmgr network.ResourceManager) (*tcp.TcpTransport, error) {
+		return tcp.NewTCPTransport(&pnetUpgrader{Upgrader: upgrader, psk: psk, onMismatch: onMismatch}, rcmgr)
+	})
+}
+
+// pnetUpgrader protects the connections of a transport before they are upgraded.
+type pnetUpgrader struct {
+	transport.Upgrader
+	psk        pnet.PSK
+	onMismatch MismatchHandler
+}
+
+func (u *pnetUpgrader) UpgradeListener(t transport.Transport, list manet.Listener) transport.Listener {
+	return u.Upgrader.UpgradeListener(t, &pnetListener{Listener: list, upgrader: u})
+}
+
+func (u *pnetUpgrader) Upgrade(ctx context.Context, t transport.Transport, maconn manet.Conn, dir network.Direction, p peer.ID, scope network.ConnManagementScope) (transport.CapableConn, error) {
+	conn, err := u.protect(maconn, dir)
+	if err != nil {
+		_ = maconn.Close()
+		return nil, err
+	}
+	// dials exchange the message right away, so the mismatch is the error of the dial
+	deadline := time.Now().Add(PrivateNetworkHandshakeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = maconn.SetDeadline(deadline)
+	err = conn.handshake()
+	_ = maconn.SetDeadline(time.Time{})
+	if err != nil {
+		_ = maconn.Close()
+		return nil, err
+	}
+	return u.Upgrader.Upgrade(ctx, t, conn, dir, p, scope)
+}
+
+func (u *pnetUpgrader) protect(maconn manet.Conn, dir network.Direction) (*pnetConn, error) {
+	protected, err := libp2pnet.NewProtectedConn(u.psk, maconn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup private network protector: %w", err)
+	}
+	return &pnetConn{Conn: maconn, protected: protected, dir: dir, onMismatch: u.onMismatch}, nil
+}
+
+// pnetListener protects accepted connections. They exchange the message on first use,
+// within the timeout the upgrader applies to the negotiation of inbound connections.
+type pnetListener struct {
+	manet.Listener
+	upgrader *pnetUpgrader
+}
+
+func (l *pnetListener) Accept() (manet.Conn, error) {
+	maconn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := l.upgrader.protect(maconn, network.DirInbound)
+	if err != nil {
+		_ = maconn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// pnetConn reads and writes through the protection once the peer has proven it uses
+// the same key.
+type pnetConn struct {
+	manet.Conn
+	protected  net.Conn
+	dir        network.Direction
+	onMismatch MismatchHandler
+
+	once sync.Once
+	err  error
+}
+
+func (c *pnetConn) Read(b []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+	return c.protected.Read(b)
+}
+
+func (c *pnetConn) Write(b []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+	return c.protected.Write(b)
+}
+
+func (c *pnetConn) handshake() error {
+	c.once.Do(func() {
+		c.err = c.exchange()
+		if errors.Is(c.err, ErrPrivateNetworkMismatch) && c.onMismatch != nil {
+			c.onMismatch(c.RemoteMultiaddr(), c.dir)
+		}
+	})
+	return c.err
+}
+
+func (c *pnetConn) exchange() error {
+	if _, err := c.protected.Write([]byte(pnetMagic)); err != nil {
+		return fmt.Errorf("private network handshake: %w", err)
+	}
+	magic := make([]byte, len(pnetMagic))
+	if _, err := io.ReadFull(c.protected, magic); err != nil {
+		return fmt.Errorf("private network handshake: %w", err)
+	}
+	if string(magic) != pnetMagic {
+		return fmt.Errorf("%w: %s", ErrPrivateNetworkMismatch, c.RemoteMultiaddr())
+	}
+	return nil
+}
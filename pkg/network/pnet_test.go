@@ -0,0 +1,82 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwarmKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "swarm.key")
+
+	require.NoError(t, GenerateSwarmKey(keyFile))
+	psk, err := LoadSwarmKey(keyFile)
+	require.NoError(t, err)
+	assert.Len(t, psk, 32)
+
+	// An existing key must never be replaced
+	assert.Error(t, GenerateSwarmKey(keyFile))
+	again, err := LoadSwarmKey(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, psk, again)
+
+	_, err = LoadSwarmKey(filepath.Join(t.TempDir(), "missing.key"))
+	assert.Error(t, err)
+}
+
+func newPrivateNetworkHost(t *testing.T, psk pnet.PSK, mismatches chan<- network.Direction) host.Host {
+	h, err := libp2p.New(
+		libp2p.NoTransports,
+		PrivateNetworkTransport(psk, func(_ multiaddr.Multiaddr, dir network.Direction) {
+			mismatches <- dir
+		}),
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = h.Close() })
+	return h
+}
+
+func TestPrivateNetworkMismatch(t *testing.T) {
+	key, other := make(pnet.PSK, 32), make(pnet.PSK, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	_, err = rand.Read(other)
+	require.NoError(t, err)
+	mismatches := make(chan network.Direction, 8)
+	member := newPrivateNetworkHost(t, key, mismatches)
+	peer := newPrivateNetworkHost(t, key, mismatches)
+	outsider := newPrivateNetworkHost(t, other, mismatches)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	require.NoError(t, member.Connect(ctx, peer.Peerstore().PeerInfo(peer.ID())))
+	assert.Empty(t, mismatches)
+
+	// both sides of a connection between different keys report the mismatch
+	err = member.Connect(ctx, outsider.Peerstore().PeerInfo(outsider.ID()))
+	assert.ErrorIs(t, err, ErrPrivateNetworkMismatch)
+	reported := []network.Direction{<-mismatches, <-mismatches}
+	assert.ElementsMatch(t, []network.Direction{network.DirOutbound, network.DirInbound}, reported)
+
+	// a node whose boot nodes all use another key does not start
+	addrs, err := peerAddrs(outsider)
+	require.NoError(t, err)
+	_, err = WithDht(ctx, newPrivateNetworkHost(t, key, mismatches), addrs, "/masa/test", "/masa", make(chan PeerEvent, 16), false)
+	assert.ErrorIs(t, err, ErrPrivateNetworkMismatch)
+}
+
+func peerAddrs(h host.Host) ([]multiaddr.Multiaddr, error) {
+	return peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()})
+}
@@ -3,7 +3,9 @@ package masa
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
 	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
@@ -21,14 +24,28 @@ import (
 	"github.com/multiformats/go-multiaddr"
 	"github.com/sirupsen/logrus"
 
+	"github.com/masa-finance/masa-oracle/pkg/acl"
 	"github.com/masa-finance/masa-oracle/pkg/ad"
+	"github.com/masa-finance/masa-oracle/pkg/clock"
 	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/health"
 	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
+	"github.com/masa-finance/masa-oracle/pkg/namespace"
 	myNetwork "github.com/masa-finance/masa-oracle/pkg/network"
 	"github.com/masa-finance/masa-oracle/pkg/nodestatus"
 	pubsub2 "github.com/masa-finance/masa-oracle/pkg/pubsub"
 )
 
+const (
+	aclFile       = "acl.json"
+	namespacesDir = "namespaces"
+	// bootnodeManifestFile keeps the last accepted bootnode manifest
+	bootnodeManifestFile = "bootnode_manifest.json"
+	// knownPeerRedialTimeout bounds how long Start waits for known peers before it
+	// contacts the boot nodes.
+	knownPeerRedialTimeout = time.Second * 5
+)
+
 type OracleNode struct {
 	Host                           host.Host
 	PrivKey                        *ecdsa.PrivateKey
@@ -45,6 +62,18 @@ type OracleNode struct {
 	StartTime                      time.Time
 	AdSubscriptionHandler          *ad.SubscriptionHandler
 	NodeStatusSubscriptionsHandler *nodestatus.SubscriptionHandler
+	Config                         *config.AppConfig
+	Clock                          clock.Clock
+	// HLC orders the versions of the records written by the node, it reads the node clock.
+	HLC         *clock.HLC
+	ACL         *acl.Registry
+	Namespaces  *namespace.Registry
+	KeyManager  *masacrypto.KeyManager
+	DataUpdates *myNetwork.DBWatcher
+	// Health holds the checks of the liveness, readiness and startup probes.
+	Health *health.Registry
+	// Connectivity records the reachability of the node and the dials of Host that fail.
+	Connectivity *myNetwork.ConnectivityMonitor
 }
 
 func (node *OracleNode) GetMultiAddrs() multiaddr.Multiaddr {
@@ -55,9 +84,12 @@ func (node *OracleNode) GetMultiAddrs() multiaddr.Multiaddr {
 	return node.priorityAddrs
 }
 
+// NewOracleNode creates a node listening on the configured transports, using the
+// process-wide configuration and key manager.
 func NewOracleNode(ctx context.Context, isStaked bool) (*OracleNode, error) {
 	// Start with the default scaling limits.
 	cfg := config.GetInstance()
+	keyManager := masacrypto.KeyManagerInstance()
 	scalingLimits := rcmgr.DefaultLimits
 	concreteLimits := scalingLimits.AutoScale()
 	limiter := rcmgr.NewFixedLimiter(concreteLimits)
@@ -67,10 +99,16 @@ func NewOracleNode(ctx context.Context, isStaked bool) (*OracleNode, error) {
 		return nil, err
 	}
 
+	peerStore, err := myNetwork.NewPersistentPeerstore(ctx, cfg.MasaDir)
+	if err != nil {
+		return nil, err
+	}
+
 	var addrStr []string
 	libp2pOptions := []libp2p.Option{
-		libp2p.Identity(masacrypto.KeyManagerInstance().Libp2pPrivKey),
+		libp2p.Identity(keyManager.Libp2pPrivKey),
 		libp2p.ResourceManager(resourceManager),
+		libp2p.Peerstore(peerStore),
 		libp2p.Ping(false), // disable built-in ping
 		libp2p.EnableNATService(),
 		libp2p.NATPortMap(),
@@ -80,14 +118,34 @@ func NewOracleNode(ctx context.Context, isStaked bool) (*OracleNode, error) {
 	securityOptions := []libp2p.Option{
 		libp2p.Security(noise.ID, noise.New),
 	}
-	if cfg.UDP {
+	tcpTransport := libp2p.Transport(tcp.NewTCPTransport)
+	udp := cfg.UDP
+	if cfg.PrivateNetwork {
+		// QUIC does its own encryption and cannot be wrapped by the pre-shared key
+		if !cfg.TCP {
+			return nil, fmt.Errorf("private network mode requires the TCP transport, start the node with --tcp=true")
+		}
+		if udp {
+			logrus.Warn("Private network mode does not support the QUIC transport, --udp is ignored and only TCP is used")
+			udp = false
+		}
+		psk, err := myNetwork.LoadSwarmKey(cfg.SwarmKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w, generate one with 'masa-node psk generate'", err)
+		}
+		logrus.Infof("Private network mode enabled with key %s", cfg.SwarmKeyFile)
+		tcpTransport = myNetwork.PrivateNetworkTransport(psk, func(remote multiaddr.Multiaddr, dir network.Direction) {
+			logrus.Warnf("Peer at %s (%s) uses a different private network key", remote, dir)
+		})
+	}
+	if udp {
 		addrStr = append(addrStr, fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1", cfg.PortNbr))
 		libp2pOptions = append(libp2pOptions, libp2p.Transport(quic.NewTransport))
 	}
 	if cfg.TCP {
 		securityOptions = append(securityOptions, libp2p.Security(libp2ptls.ID, libp2ptls.New))
 		addrStr = append(addrStr, fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", cfg.PortNbr))
-		libp2pOptions = append(libp2pOptions, libp2p.Transport(tcp.NewTCPTransport))
+		libp2pOptions = append(libp2pOptions, tcpTransport)
 		libp2pOptions = append(libp2pOptions, libp2p.Muxer("/yamux/1.0.0", yamux.DefaultTransport))
 	}
 	libp2pOptions = append(libp2pOptions, libp2p.ChainOptions(securityOptions...))
@@ -95,31 +153,85 @@ func NewOracleNode(ctx context.Context, isStaked bool) (*OracleNode, error) {
 
 	hst, err := libp2p.New(libp2pOptions...)
 	if err != nil {
+		_ = peerStore.Close()
 		return nil, err
 	}
+	return NewOracleNodeWithHost(ctx, hst, cfg, clock.Wall(), keyManager, isStaked)
+}
 
+// NewOracleNodeWithHost creates a node on top of an existing host with its own
+// configuration and keys, which lets several nodes run in one process.
+func NewOracleNodeWithHost(ctx context.Context, hst host.Host, cfg *config.AppConfig, clk clock.Clock, keyManager *masacrypto.KeyManager, isStaked bool) (*OracleNode, error) {
+	connectivity := myNetwork.NewConnectivityMonitor(clk)
+	hst = connectivity.MonitorDials(hst)
 	subscriptionManager, err := pubsub2.NewPubSubManager(ctx, hst)
 	if err != nil {
 		return nil, err
 	}
 
-	return &OracleNode{
+	node := &OracleNode{
 		Host:          hst,
-		PrivKey:       masacrypto.KeyManagerInstance().EcdsaPrivKey,
+		PrivKey:       keyManager.EcdsaPrivKey,
 		Protocol:      config.ProtocolWithVersion(config.OracleProtocol),
 		multiAddrs:    myNetwork.GetMultiAddressesForHostQuiet(hst),
 		Context:       ctx,
 		PeerChan:      make(chan myNetwork.PeerEvent),
-		NodeTracker:   pubsub2.NewNodeEventTracker(config.Version, cfg.Environment),
+		NodeTracker:   pubsub2.NewNodeEventTracker(config.Version, cfg.Environment, cfg.MasaDir, clk),
 		PubSubManager: subscriptionManager,
 		IsStaked:      isStaked,
-	}, nil
+		Config:        cfg,
+		Clock:         clk,
+		HLC:           clock.NewHLC(clk),
+		KeyManager:    keyManager,
+		Connectivity:  connectivity,
+	}
+	node.ACL, err = acl.NewRegistry(filepath.Join(cfg.MasaDir, aclFile), node.IsACLRoot, clk)
+	if err != nil {
+		return nil, err
+	}
+	node.Namespaces = namespace.NewRegistry()
+	if err := node.Namespaces.LoadDir(filepath.Join(cfg.MasaDir, namespacesDir)); err != nil {
+		return nil, err
+	}
+	node.DataUpdates = myNetwork.NewDBWatcher(&myNetwork.DBValidator{Authorize: node.IsDBWriter, CheckValue: node.CheckDBValue, Clock: clk}, node.HLC)
+	node.Health = health.NewRegistry()
+	node.Health.Register("dht", node.checkDHT, health.Readiness, health.Startup)
+	node.Health.Register("pubsub", node.checkPubSub, health.Liveness, health.Readiness, health.Startup)
+	return node, nil
+}
+
+// checkDHT passes once the routing table holds the configured number of peers.
+func (node *OracleNode) checkDHT(context.Context) (string, error) {
+	if node.DHT == nil {
+		return "", errors.New("the DHT is not started")
+	}
+	peers := node.DHT.RoutingTable().Size()
+	if peers < node.Config.MinPeers {
+		return "", fmt.Errorf("%d of %d peers in the routing table", peers, node.Config.MinPeers)
+	}
+	return fmt.Sprintf("%d peers in the routing table", peers), nil
+}
+
+// checkPubSub passes while the node is subscribed to the topics joined by
+// SubscribeToTopics.
+func (node *OracleNode) checkPubSub(context.Context) (string, error) {
+	topics := make([]string, len(nodeTopics))
+	for i, topic := range nodeTopics {
+		topics[i] = config.TopicWithVersion(topic)
+	}
+	return node.PubSubManager.CheckTopics(topics...)
 }
 
 func (node *OracleNode) Start() (err error) {
 	logrus.Infof("Starting node with ID: %s", node.GetMultiAddrs().String())
 
-	bootNodeAddrs, err := myNetwork.GetBootNodesMultiAddress(config.GetInstance().Bootnodes)
+	cfg := node.Config
+	bootNodeResolver, err := myNetwork.NewBootNodeResolver(cfg.BootnodeManifest, cfg.BootnodeManifestKey,
+		filepath.Join(cfg.MasaDir, bootnodeManifestFile))
+	if err != nil {
+		return err
+	}
+	bootNodeAddrs, err := bootNodeResolver.GetBootNodesMultiAddress(node.Context, cfg.Bootnodes)
 	if err != nil {
 		return err
 	}
@@ -131,37 +243,64 @@ func (node *OracleNode) Start() (err error) {
 		node.Host.SetStreamHandler(config.ProtocolWithVersion(config.NodeGossipTopic), node.GossipNodeData)
 	}
 	node.Host.Network().Notify(node.NodeTracker)
+	if err := node.Connectivity.WatchReachability(node.Context, node.Host); err != nil {
+		logrus.Warnf("Unable to watch reachability changes: %v", err)
+	}
 
 	go node.ListenToNodeTracker()
 	go node.handleDiscoveredPeers()
 
-	node.DHT, err = myNetwork.WithDht(node.Context, node.Host, bootNodeAddrs, node.Protocol, config.MasaPrefix, node.PeerChan, node.IsStaked)
-	if err != nil {
-		return err
+	// Reconnect to staked peers remembered from a previous run before reaching out to the
+	// boot nodes, unreachable peers hold up the start for at most knownPeerRedialTimeout
+	isStaked := func(p peer.ID) bool { return node.NodeTracker.IsStaked(p.String()) }
+	redialCtx, cancelRedial := context.WithTimeout(node.Context, knownPeerRedialTimeout)
+	if redialed := myNetwork.RedialKnownPeers(redialCtx, node.Host, isStaked); redialed > 0 {
+		logrus.Infof("Reconnected to %d known staked peers", redialed)
+	}
+	cancelRedial()
+
+	dhtOptions := []dht.Option{dht.NamespacedValidator("db", node.DataUpdates.Validator)}
+	switch cfg.DhtMode {
+	case "server":
+		dhtOptions = append(dhtOptions, dht.Mode(dht.ModeServer))
+	case "client":
+		dhtOptions = append(dhtOptions, dht.Mode(dht.ModeClient))
 	}
-	err = myNetwork.WithMDNS(node.Host, config.Rendezvous, node.PeerChan)
+	node.DHT, err = myNetwork.WithDht(node.Context, node.Host, bootNodeAddrs, node.Protocol, config.MasaPrefix, node.PeerChan, node.IsStaked, dhtOptions...)
 	if err != nil {
 		return err
 	}
+	if cfg.Mdns {
+		err = myNetwork.WithMDNS(node.Host, config.Rendezvous, node.PeerChan)
+		if err != nil {
+			return err
+		}
+	}
 
 	go myNetwork.Discover(node.Context, node.Host, node.DHT, node.Protocol)
+	go node.ACL.RepublishLoop(node.Context, node.Host.ID(), acl.RepublishInterval)
+	go bootNodeResolver.WatchBootNodes(node.Context, cfg.Bootnodes, bootNodeAddrs, myNetwork.BootNodeRefreshInterval,
+		func(added, removed []multiaddr.Multiaddr) {
+			myNetwork.RemoveBootNodes(node.Host, node.DHT, removed)
+			myNetwork.AddBootNodes(node.Context, node.Host, node.DHT, added)
+		})
 	// if this is the original boot node then add it to the node tracker
-	if config.GetInstance().HasBootnodes() {
+	if cfg.HasBootnodes() {
 		nodeData := node.NodeTracker.GetNodeData(node.Host.ID().String())
 		if nodeData == nil {
-			publicKeyHex := masacrypto.KeyManagerInstance().EthAddress
-			nodeData = pubsub2.NewNodeData(node.GetMultiAddrs(), node.Host.ID(), publicKeyHex, pubsub2.ActivityJoined)
+			publicKeyHex := node.KeyManager.EthAddress
+			nodeData = pubsub2.NewNodeData(node.GetMultiAddrs(), node.Host.ID(), publicKeyHex, pubsub2.ActivityJoined, node.Clock.Now())
 			nodeData.IsStaked = node.IsStaked
 			nodeData.SelfIdentified = true
 		}
-		nodeData.Joined()
+		nodeData.Joined(node.Clock.Now())
 		node.NodeTracker.HandleNodeData(*nodeData)
 	}
 	// call SubscribeToTopics on startup
 	if err := SubscribeToTopics(node); err != nil {
 		return err
 	}
-	node.StartTime = time.Now()
+	node.StartTime = node.Clock.Now()
 
 	return nil
 }
@@ -204,8 +343,11 @@ func (node *OracleNode) handleStream(stream network.Stream) {
 		return
 	}
 	multiAddr := stream.Conn().RemoteMultiaddr()
-	newNodeData := pubsub2.NewNodeData(multiAddr, remotePeer, nodeData.EthAddress, pubsub2.ActivityJoined)
+	newNodeData := pubsub2.NewNodeData(multiAddr, remotePeer, nodeData.EthAddress, pubsub2.ActivityJoined, node.Clock.Now())
 	newNodeData.IsStaked = nodeData.IsStaked
+	if nodeData.IsStaked {
+		myNetwork.RememberPeerAddrs(node.Host, remotePeer, multiAddr)
+	}
 	err = node.NodeTracker.AddOrUpdateNodeData(newNodeData, false)
 	if err != nil {
 		logrus.Error(err)
@@ -214,6 +356,48 @@ func (node *OracleNode) handleStream(stream network.Stream) {
 	logrus.Info("handleStream -> Received data from:", remotePeer.String())
 }
 
+// IsACLRoot reports whether p is a root admin of the database ACL: the configured
+// allowed peer or one of the configured ACL admins.
+func (node *OracleNode) IsACLRoot(p peer.ID) bool {
+	if node.Config.AllowedPeerId != "" && p.String() == node.Config.AllowedPeerId {
+		return true
+	}
+	for _, admin := range node.Config.AclAdmins {
+		if p.String() == admin {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDBWriter reports whether writer may store a record under the given /db key. Keys
+// in a namespace follow the namespace policy. For keys outside of any namespace, peers
+// with the writer or admin role in the ACL may write any key, every other node only its
+// own /db/<peer id>.
+func (node *OracleNode) IsDBWriter(writer peer.ID, key string) bool {
+	ns, id, err := node.Namespaces.Lookup(strings.TrimPrefix(key, "/db/"))
+	if err != nil {
+		return false
+	}
+	if ns != nil {
+		return ns.Allows(writer, id, node.ACL.Role(writer))
+	}
+	if node.ACL.CanWrite(writer) {
+		return true
+	}
+	return key == "/db/"+writer.String()
+}
+
+// CheckDBValue validates the value stored under the given /db key against the schema
+// of the key's namespace. Values of keys outside of any namespace are not checked.
+func (node *OracleNode) CheckDBValue(key string, value []byte) error {
+	ns, _, err := node.Namespaces.Lookup(strings.TrimPrefix(key, "/db/"))
+	if err != nil || ns == nil {
+		return err
+	}
+	return ns.Validate(value)
+}
+
 func (node *OracleNode) IsPublisher() bool {
 	// Node is a publisher if it has a non-empty signature
 	return node.Signature != ""
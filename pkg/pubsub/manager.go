@@ -3,14 +3,15 @@ package pubsub
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/sirupsen/logrus"
-
-	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
 )
 
 // SubscriptionHandler defines the interface for handling pubsub messages.
@@ -20,7 +21,9 @@ type SubscriptionHandler interface {
 }
 
 type Manager struct {
-	ctx                context.Context
+	ctx context.Context
+	// mu guards the topics, subscriptions and handlers maps
+	mu                 sync.RWMutex
 	topics             map[string]*pubsub.Topic
 	subscriptions      map[string]*pubsub.Subscription
 	handlers           map[string]SubscriptionHandler
@@ -41,7 +44,7 @@ func NewPubSubManager(ctx context.Context, host host.Host) (*Manager, error) { /
 		handlers:           make(map[string]SubscriptionHandler),
 		gossipSub:          gossipSub,
 		host:               host,
-		PublicKeyPublisher: NewPublicKeyPublisher(nil, masacrypto.KeyManagerInstance().Libp2pPubKey), // Initialize PublicKeyPublisher here
+		PublicKeyPublisher: NewPublicKeyPublisher(nil, host.Peerstore().PubKey(host.ID())), // Initialize PublicKeyPublisher here
 	}
 
 	manager.PublicKeyPublisher.pubSubManager = manager // Ensure the publisher has a reference back to the manager
@@ -58,7 +61,9 @@ func (sm *Manager) createTopic(topicName string) (*pubsub.Topic, error) {
 	if err != nil {
 		return nil, err
 	}
+	sm.mu.Lock()
 	sm.topics[topicName] = topic
+	sm.mu.Unlock()
 	return topic, nil
 }
 
@@ -71,13 +76,18 @@ func (sm *Manager) AddSubscription(topicName string, handler SubscriptionHandler
 	if err != nil {
 		return err
 	}
+	sm.mu.Lock()
 	sm.subscriptions[topicName] = sub
 	sm.handlers[topicName] = handler
+	sm.mu.Unlock()
 
 	go func() {
 		for {
 			msg, err := sub.Next(sm.ctx)
 			if err != nil {
+				if sm.ctx.Err() != nil || errors.Is(err, pubsub.ErrSubscriptionCancelled) {
+					return
+				}
 				logrus.Errorf("Error reading from topic: %v", err)
 				continue
 			}
@@ -94,6 +104,8 @@ func (sm *Manager) AddSubscription(topicName string, handler SubscriptionHandler
 }
 
 func (sm *Manager) RemoveSubscription(topic string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 	sub, ok := sm.subscriptions[topic]
 	if !ok {
 		return fmt.Errorf("no subscription for topic %s", topic)
@@ -108,6 +120,8 @@ func (sm *Manager) RemoveSubscription(topic string) error {
 }
 
 func (sm *Manager) GetSubscription(topic string) (*pubsub.Subscription, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
 	sub, ok := sm.subscriptions[topic]
 	if !ok {
 		return nil, fmt.Errorf("no subscription for topic %s", topic)
@@ -116,7 +130,9 @@ func (sm *Manager) GetSubscription(topic string) (*pubsub.Subscription, error) {
 }
 
 func (sm *Manager) Publish(topic string, data []byte) error {
+	sm.mu.RLock()
 	t, ok := sm.topics[topic]
+	sm.mu.RUnlock()
 	if !ok {
 		return fmt.Errorf("no topic named %s", topic)
 	}
@@ -124,6 +140,8 @@ func (sm *Manager) Publish(topic string, data []byte) error {
 }
 
 func (sm *Manager) GetHandler(topic string) (SubscriptionHandler, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
 	handler, ok := sm.handlers[topic]
 	if !ok {
 		return nil, fmt.Errorf("no handler for topic %s", topic)
@@ -150,6 +168,8 @@ func StreamConsoleTo(ctx context.Context, topic *pubsub.Topic) {
 
 // GetTopicNames returns a slice of the names of all topics currently managed.
 func (sm *Manager) GetTopicNames() []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
 	var topicNames []string
 	for name := range sm.topics {
 		topicNames = append(topicNames, name)
@@ -162,7 +182,9 @@ func (sm *Manager) PublishMessage(topicName, message string) error {
 	data := []byte(message)
 
 	// Check if the topic exists
+	sm.mu.RLock()
 	t, ok := sm.topics[topicName]
+	sm.mu.RUnlock()
 	if !ok {
 		// Optionally, create the topic if it doesn't exist
 		var err error
@@ -181,13 +203,18 @@ func (sm *Manager) Subscribe(topicName string, handler SubscriptionHandler) erro
 	if err != nil {
 		return err
 	}
+	sm.mu.Lock()
 	sm.subscriptions[topicName] = sub
 	sm.handlers[topicName] = handler
+	sm.mu.Unlock()
 
 	go func() {
 		for {
 			msg, err := sub.Next(sm.ctx)
 			if err != nil {
+				if sm.ctx.Err() != nil || errors.Is(err, pubsub.ErrSubscriptionCancelled) {
+					return
+				}
 				logrus.Errorf("Error reading from topic: %v", err)
 				continue
 			}
@@ -203,3 +230,22 @@ func (sm *Manager) Subscribe(topicName string, handler SubscriptionHandler) erro
 	return nil
 
 }
+
+// CheckTopics reports whether the manager is running and subscribed to the topics.
+func (sm *Manager) CheckTopics(topics ...string) (string, error) {
+	if err := sm.ctx.Err(); err != nil {
+		return "", fmt.Errorf("pubsub stopped: %w", err)
+	}
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	var missing []string
+	for _, topic := range topics {
+		if _, ok := sm.subscriptions[topic]; !ok {
+			missing = append(missing, topic)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("not subscribed to %s", strings.Join(missing, ", "))
+	}
+	return fmt.Sprintf("subscribed to %d topics", len(sm.subscriptions)), nil
+}
@@ -61,7 +61,7 @@ type NodeData struct {
 	IsWriterNode         bool            `json:"isWriterNode"`
 }
 
-func NewNodeData(addr multiaddr.Multiaddr, peerId peer.ID, publicKey string, activity int) *NodeData {
+func NewNodeData(addr multiaddr.Multiaddr, peerId peer.ID, publicKey string, activity int, now time.Time) *NodeData {
 	multiaddrs := make([]JSONMultiaddr, 0)
 	multiaddrs = append(multiaddrs, JSONMultiaddr{addr})
 	wn, _ := strconv.ParseBool(viper.GetString("WRITER_NODE"))
@@ -69,7 +69,7 @@ func NewNodeData(addr multiaddr.Multiaddr, peerId peer.ID, publicKey string, act
 	return &NodeData{
 		PeerId:            peerId,
 		Multiaddrs:        multiaddrs,
-		LastUpdated:       time.Now(),
+		LastUpdated:       now,
 		CurrentUptime:     0,
 		AccumulatedUptime: 0,
 		EthAddress:        publicKey,
@@ -83,8 +83,7 @@ func (n *NodeData) Address() string {
 	return fmt.Sprintf("%s/p2p/%s", n.Multiaddrs[0].String(), n.PeerId.String())
 }
 
-func (n *NodeData) Joined() {
-	now := time.Now()
+func (n *NodeData) Joined(now time.Time) {
 	n.FirstJoined = now.Add(-n.AccumulatedUptime)
 	n.LastJoined = now
 	n.LastUpdated = now
@@ -97,7 +96,7 @@ func (n *NodeData) Joined() {
 	}
 }
 
-func (n *NodeData) Left() {
+func (n *NodeData) Left(now time.Time) {
 	if n.Activity == ActivityLeft {
 		if n.IsStaked {
 			logrus.Warnf("Node %s is already marked as left", n.Address())
@@ -106,10 +105,9 @@ func (n *NodeData) Left() {
 		}
 		return
 	}
-	now := time.Now()
 	n.LastLeft = now
 	n.LastUpdated = now
-	n.AccumulatedUptime += n.GetCurrentUptime()
+	n.AccumulatedUptime += n.GetCurrentUptime(now)
 	n.CurrentUptime = 0
 	n.Activity = ActivityLeft
 	n.IsActive = false
@@ -120,37 +118,41 @@ func (n *NodeData) Left() {
 	}
 }
 
-func (n *NodeData) GetCurrentUptime() time.Duration {
+func (n *NodeData) GetCurrentUptime(now time.Time) time.Duration {
 	var dur time.Duration
 	// If the node is currently active, return the time since the last joined time
 	if n.Activity == ActivityJoined {
-		dur = time.Since(n.LastJoined)
+		dur = now.Sub(n.LastJoined)
 	} else if n.Activity == ActivityLeft {
 		dur = 0
 	}
 	return dur
 }
 
-func (n *NodeData) GetAccumulatedUptime() time.Duration {
-	return n.AccumulatedUptime + n.GetCurrentUptime()
+func (n *NodeData) GetAccumulatedUptime(now time.Time) time.Duration {
+	return n.AccumulatedUptime + n.GetCurrentUptime(now)
 }
 
 // UpdateAccumulatedUptime updates the accumulated uptime of the node in the cases where there is a discrepancy between
 // the last left and last joined times that came in from the gossip sub events
-func (n *NodeData) UpdateAccumulatedUptime() {
+func (n *NodeData) UpdateAccumulatedUptime(now time.Time) {
 	if n.Activity == ActivityLeft {
 		n.AccumulatedUptime += n.LastLeft.Sub(n.LastJoined)
 	} else {
-		n.AccumulatedUptime += time.Since(n.LastJoined)
+		n.AccumulatedUptime += now.Sub(n.LastJoined)
 	}
 }
 
 func GetSelfNodeDataJson(host host.Host, isStaked bool) []byte {
+	ethAddress, err := masacrypto.Libp2pPubKeyToEthAddress(host.Peerstore().PubKey(host.ID()))
+	if err != nil {
+		logrus.Error("Error getting eth address:", err)
+	}
 	// Create and populate NodeData
 	nodeData := NodeData{
 		PeerId:     host.ID(),
 		IsStaked:   isStaked,
-		EthAddress: masacrypto.KeyManagerInstance().EthAddress,
+		EthAddress: ethAddress,
 	}
 
 	// Convert NodeData to JSON
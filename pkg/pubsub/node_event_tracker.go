@@ -14,14 +14,17 @@ import (
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/sirupsen/logrus"
 
-	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/clock"
 	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
 )
 
 type NodeEventTracker struct {
+	// Clock is the time source of the join and leave times and uptimes.
+	Clock         clock.Clock
 	NodeDataChan  chan *NodeData
 	nodeData      *SafeMap
 	nodeDataFile  string
+	dataDir       string
 	ConnectBuffer map[string]ConnectBufferEntry
 }
 
@@ -30,11 +33,16 @@ type ConnectBufferEntry struct {
 	ConnectTime time.Time
 }
 
-func NewNodeEventTracker(version, environment string) *NodeEventTracker {
+// NewNodeEventTracker creates a tracker that persists the node registry as
+// <version>_<environment>_node_data.json in dataDir, or the working directory if dataDir
+// is empty. Times are read from clk.
+func NewNodeEventTracker(version, environment, dataDir string, clk clock.Clock) *NodeEventTracker {
 	net := &NodeEventTracker{
+		Clock:         clk,
 		nodeData:      NewSafeMap(),
 		NodeDataChan:  make(chan *NodeData),
-		nodeDataFile:  fmt.Sprintf("%s_%s_node_data.json", version, environment),
+		nodeDataFile:  NodeDataFileName(version, environment),
+		dataDir:       dataDir,
 		ConnectBuffer: make(map[string]ConnectBufferEntry),
 	}
 	err := net.LoadNodeData()
@@ -45,6 +53,12 @@ func NewNodeEventTracker(version, environment string) *NodeEventTracker {
 	return net
 }
 
+// NodeDataFileName returns the name of the file the node registry of the given
+// version and environment is persisted in.
+func NodeDataFileName(version, environment string) string {
+	return fmt.Sprintf("%s_%s_node_data.json", version, environment)
+}
+
 func (net *NodeEventTracker) Listen(n network.Network, a ma.Multiaddr) {
 	// This method is called when the node starts listening on a multiaddr
 	logrus.WithFields(logrus.Fields{
@@ -72,9 +86,9 @@ func (net *NodeEventTracker) Connected(n network.Network, c network.Conn) {
 	} else {
 		if nodeData.IsActive {
 			// Node appears already connected, buffer this connect event
-			net.ConnectBuffer[peerID] = ConnectBufferEntry{NodeData: nodeData, ConnectTime: time.Now()}
+			net.ConnectBuffer[peerID] = ConnectBufferEntry{NodeData: nodeData, ConnectTime: net.Clock.Now()}
 		} else {
-			nodeData.Joined()
+			nodeData.Joined(net.Clock.Now())
 			err := net.AddOrUpdateNodeData(nodeData, true)
 			if err != nil {
 				logrus.Error(err)
@@ -104,14 +118,14 @@ func (net *NodeEventTracker) Disconnected(n network.Network, c network.Conn) {
 	}
 	buffered := net.ConnectBuffer[peerID]
 	if buffered.NodeData != nil {
-		buffered.NodeData.Left()
+		buffered.NodeData.Left(net.Clock.Now())
 		delete(net.ConnectBuffer, peerID)
 		// net.nodeData.Delete(peerID)
 		// Now process the buffered connect
-		buffered.NodeData.Joined()
+		buffered.NodeData.Joined(net.Clock.Now())
 		net.NodeDataChan <- buffered.NodeData
 	} else {
-		nodeData.Left()
+		nodeData.Left(net.Clock.Now())
 		net.NodeDataChan <- nodeData
 	}
 	logrus.WithFields(logrus.Fields{
@@ -168,13 +182,13 @@ func (net *NodeEventTracker) HandleNodeData(data NodeData) {
 	if !data.LastJoined.IsZero() &&
 		data.LastJoined.Before(existingData.LastJoined) &&
 		data.LastJoined.After(existingData.LastLeft) &&
-		time.Since(data.LastJoined) < maxDifference {
+		net.Clock.Since(data.LastJoined) < maxDifference {
 		existingData.LastJoined = data.LastJoined
 	}
 	if !data.LastLeft.IsZero() &&
 		data.LastLeft.After(existingData.LastLeft) &&
 		data.LastLeft.Before(existingData.LastJoined) &&
-		time.Since(data.LastLeft) < maxDifference {
+		net.Clock.Since(data.LastLeft) < maxDifference {
 		existingData.LastLeft = data.LastLeft
 	}
 
@@ -203,7 +217,7 @@ func (net *NodeEventTracker) GetNodeData(peerID string) *NodeData {
 
 func (net *NodeEventTracker) GetAllNodeData() []NodeData {
 	logrus.Debug("Getting all node data")
-	return net.nodeData.GetStakedNodesSlice()
+	return net.nodeData.GetStakedNodesSlice(net.Clock.Now())
 }
 
 func (net *NodeEventTracker) GetUpdatedNodes(since time.Time) []NodeData {
@@ -221,15 +235,17 @@ func (net *NodeEventTracker) GetUpdatedNodes(since time.Time) []NodeData {
 	return updatedNodeData
 }
 
+// NodeDataFilePath returns the file the node registry is persisted to.
+func (net *NodeEventTracker) NodeDataFilePath() string {
+	if net.dataDir == "" {
+		return net.nodeDataFile
+	}
+	return filepath.Join(net.dataDir, net.nodeDataFile)
+}
+
 func (net *NodeEventTracker) DumpNodeData() {
 	// Write the JSON data to a file
-	var filePath string
-	dataDir := config.GetInstance().MasaDir
-	if dataDir == "" {
-		filePath = net.nodeDataFile
-	} else {
-		filePath = filepath.Join(dataDir, net.nodeDataFile)
-	}
+	filePath := net.NodeDataFilePath()
 	logrus.Infof("writing node data to file: %s", filePath)
 	err := net.nodeData.DumpNodeData(filePath)
 	if err != nil {
@@ -239,13 +255,7 @@ func (net *NodeEventTracker) DumpNodeData() {
 
 func (net *NodeEventTracker) LoadNodeData() error {
 	// Read the JSON data from a file
-	var filePath string
-	dataDir := config.GetInstance().MasaDir
-	if dataDir == "" {
-		filePath = net.nodeDataFile
-	} else {
-		filePath = filepath.Join(dataDir, net.nodeDataFile)
-	}
+	filePath := net.NodeDataFilePath()
 	// Check if the file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		logrus.Warn(fmt.Sprintf("file does not exist: %s", filePath))
@@ -296,7 +306,7 @@ func (net *NodeEventTracker) AddOrUpdateNodeData(nodeData *NodeData, forceGossip
 	if !exists {
 		nodeData.SelfIdentified = true
 		net.nodeData.Set(nodeData.PeerId.String(), nodeData)
-		nodeData.Joined()
+		nodeData.Joined(net.Clock.Now())
 		net.NodeDataChan <- nodeData
 	} else {
 		if !nd.SelfIdentified {
@@ -333,12 +343,12 @@ func (net *NodeEventTracker) AddOrUpdateNodeData(nodeData *NodeData, forceGossip
 
 func (net *NodeEventTracker) ClearExpiredBufferEntries() {
 	for {
-		time.Sleep(30 * time.Second) // E.g., every 5 seconds
-		now := time.Now()
+		net.Clock.Sleep(30 * time.Second) // E.g., every 5 seconds
+		now := net.Clock.Now()
 		for peerID, entry := range net.ConnectBuffer {
 			if now.Sub(entry.ConnectTime) > time.Minute*1 {
 				// Buffer period expired without a disconnect, process connect
-				entry.NodeData.Joined()
+				entry.NodeData.Joined(now)
 				net.NodeDataChan <- entry.NodeData
 				delete(net.ConnectBuffer, peerID)
 				// net.nodeData.Delete(peerID)
@@ -45,14 +45,14 @@ func (sm *SafeMap) Len() int {
 	return len(sm.items)
 }
 
-func (sm *SafeMap) GetStakedNodesSlice() []NodeData {
+func (sm *SafeMap) GetStakedNodesSlice(now time.Time) []NodeData {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 	result := make([]NodeData, 0)
 	for _, nodeData := range sm.items {
 		nd := *nodeData
-		nd.CurrentUptime = nodeData.GetCurrentUptime()
-		nd.AccumulatedUptime = nodeData.GetAccumulatedUptime()
+		nd.CurrentUptime = nodeData.GetCurrentUptime(now)
+		nd.AccumulatedUptime = nodeData.GetAccumulatedUptime(now)
 		nd.CurrentUptimeStr = PrettyDuration(nd.CurrentUptime)
 		nd.AccumulatedUptimeStr = PrettyDuration(nd.AccumulatedUptime)
 		result = append(result, nd)
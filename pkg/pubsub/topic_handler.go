@@ -2,19 +2,61 @@ package pubsub
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"time"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/sirupsen/logrus"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+const (
+	// TopicStreamBuffer is the number of messages buffered for a stream. A stream that
+	// falls further behind is disconnected, so it cannot hold up the other streams.
+	TopicStreamBuffer = 64
+	// MaxTopicStreams bounds the number of concurrent streams of a topic.
+	MaxTopicStreams = 64
 )
 
-// TopicHandler is responsible for handling messages from subscribed topics.
+var (
+	ErrSlowConsumer   = errors.New("stream disconnected, it did not keep up with the topic")
+	ErrTooManyStreams = errors.New("too many streams of the topic")
+)
+
+// TopicMessage is a message received on a topic.
+type TopicMessage struct {
+	Topic string
+	// From is the peer that published the message, which may not be the peer that
+	// forwarded it.
+	From     peer.ID
+	Data     []byte
+	Received time.Time
+}
+
+// TopicHandler is responsible for handling messages from subscribed topics. The
+// messages are delivered to the streams of the topic.
 type TopicHandler struct {
 	Subscription *pubsub.Subscription
+	// Clock stamps the received messages.
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	streams map[*TopicStream]struct{}
 }
 
-// NewTopicHandler creates a new TopicHandler with necessary initializations.
-func NewTopicHandler() *TopicHandler {
-	return &TopicHandler{}
+// TopicStream is a subscription of a local client to the messages of a topic.
+type TopicStream struct {
+	messages chan TopicMessage
+	handler  *TopicHandler
+	err      error
+}
+
+// NewTopicHandler creates a new TopicHandler stamping messages with the node clock.
+func NewTopicHandler(clk clock.Clock) *TopicHandler {
+	return &TopicHandler{Clock: clk, streams: make(map[*TopicStream]struct{})}
 }
 
 // StartListening starts listening to messages on the subscribed topic.
@@ -33,5 +75,69 @@ func (h *TopicHandler) StartListening() {
 
 // HandleMessage processes messages received on the subscribed topics.
 func (h *TopicHandler) HandleMessage(msg *pubsub.Message) {
-	logrus.Infof("Received message on topic: %s", string(msg.Data))
+	logrus.Debugf("Received message on topic %s from %s", msg.GetTopic(), msg.GetFrom())
+	message := TopicMessage{
+		Topic:    msg.GetTopic(),
+		From:     msg.GetFrom(),
+		Data:     msg.Data,
+		Received: h.Clock.Now(),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for stream := range h.streams {
+		select {
+		case stream.messages <- message:
+		default:
+			stream.closeLocked(ErrSlowConsumer)
+		}
+	}
+}
+
+// Stream subscribes to the messages of the topic. The stream must be closed when it is
+// no longer used.
+func (h *TopicHandler) Stream() (*TopicStream, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.streams == nil {
+		h.streams = make(map[*TopicStream]struct{})
+	}
+	if len(h.streams) >= MaxTopicStreams {
+		return nil, ErrTooManyStreams
+	}
+	stream := &TopicStream{
+		messages: make(chan TopicMessage, TopicStreamBuffer),
+		handler:  h,
+	}
+	h.streams[stream] = struct{}{}
+	return stream, nil
+}
+
+// Messages returns the channel the messages are delivered on. It is closed when the
+// stream is closed or disconnected.
+func (s *TopicStream) Messages() <-chan TopicMessage {
+	return s.messages
+}
+
+// Err returns why the stream was disconnected, ErrSlowConsumer for streams that did
+// not keep up, or nil.
+func (s *TopicStream) Err() error {
+	s.handler.mu.Lock()
+	defer s.handler.mu.Unlock()
+	return s.err
+}
+
+// Close ends the stream.
+func (s *TopicStream) Close() {
+	s.handler.mu.Lock()
+	defer s.handler.mu.Unlock()
+	s.closeLocked(nil)
+}
+
+func (s *TopicStream) closeLocked(err error) {
+	if _, ok := s.handler.streams[s]; !ok {
+		return
+	}
+	delete(s.handler.streams, s)
+	s.err = err
+	close(s.messages)
 }
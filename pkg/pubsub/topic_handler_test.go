@@ -0,0 +1,60 @@
+package pubsub
+
+import (
+	"testing"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+)
+
+func TestTopicStreams(t *testing.T) {
+	handler := NewTopicHandler(clock.Wall())
+	sender, err := peer.Decode("16Uiu2HAmPxXXjR1XJEwckh6q1UStheMmGaGe8fyXdeRs3SejadSa")
+	require.NoError(t, err)
+	topic := "chat"
+	message := func(data string) *pubsub.Message {
+		return &pubsub.Message{Message: &pb.Message{Data: []byte(data), From: []byte(sender), Topic: &topic}}
+	}
+
+	fast, err := handler.Stream()
+	require.NoError(t, err)
+	defer fast.Close()
+	slow, err := handler.Stream()
+	require.NoError(t, err)
+
+	handler.HandleMessage(message("hello"))
+	received := <-fast.Messages()
+	assert.Equal(t, "chat", received.Topic)
+	assert.Equal(t, sender, received.From)
+	assert.Equal(t, []byte("hello"), received.Data)
+	assert.False(t, received.Received.IsZero())
+
+	// a stream that falls behind is disconnected, the others keep receiving
+	for i := 0; i < TopicStreamBuffer; i++ {
+		handler.HandleMessage(message("more"))
+		<-fast.Messages()
+	}
+	for range slow.Messages() {
+	}
+	assert.ErrorIs(t, slow.Err(), ErrSlowConsumer)
+	handler.HandleMessage(message("still there"))
+	assert.Equal(t, []byte("still there"), (<-fast.Messages()).Data)
+
+	slow.Close()
+	fast.Close()
+	_, ok := <-fast.Messages()
+	assert.False(t, ok)
+	assert.NoError(t, fast.Err())
+
+	for i := 0; i < MaxTopicStreams; i++ {
+		_, err = handler.Stream()
+		require.NoError(t, err)
+	}
+	_, err = handler.Stream()
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+}
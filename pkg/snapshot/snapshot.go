@@ -0,0 +1,539 @@
+// Package snapshot exports the resolver cache and the node registry of a node to a
+// JSONL file and imports them into another node.
+//
+// A snapshot starts with a header line, followed by one line per cache entry and one
+// line per registry node, and ends with a footer line. The footer holds the number of
+// entries and the SHA-256 digest of all preceding lines, and, if the snapshot is
+// signed, the public key of the signing node and its signature over the digest.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/consensus"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/pubsub"
+)
+
+// FormatVersion is the version of the snapshot format written by Export.
+const FormatVersion = 1
+
+const (
+	lineHeader = "header"
+	lineRecord = "record"
+	lineNode   = "node"
+	lineFooter = "footer"
+
+	// maxLineSize bounds a line of a snapshot, it holds the largest cache entries.
+	maxLineSize = 16 << 20
+)
+
+var (
+	ErrInvalidSnapshot    = errors.New("invalid snapshot")
+	ErrUnsupportedVersion = errors.New("unsupported snapshot version")
+	ErrInvalidSignature   = errors.New("invalid snapshot signature")
+	ErrUnsigned           = errors.New("snapshot is not signed")
+)
+
+// Mode selects how Import combines a snapshot with the local data.
+type Mode string
+
+const (
+	// ModeMerge keeps the local data and adds newer entries from the snapshot.
+	ModeMerge Mode = "merge"
+	// ModeReplace makes the local data equal to the snapshot.
+	ModeReplace Mode = "replace"
+)
+
+// Header is the first line of a snapshot.
+type Header struct {
+	Format      int       `json:"format"`
+	Created     time.Time `json:"created"`
+	NodeVersion string    `json:"nodeVersion,omitempty"`
+	Environment string    `json:"environment,omitempty"`
+}
+
+// Footer is the last line of a snapshot.
+type Footer struct {
+	Records   int    `json:"records"`
+	Nodes     int    `json:"nodes"`
+	SHA256    string `json:"sha256"`
+	PublicKey []byte `json:"publicKey,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Signer returns the peer ID of the node that signed the snapshot, or an empty ID for
+// unsigned snapshots. The signature is checked by Verify.
+func (f *Footer) Signer() (peer.ID, error) {
+	if len(f.PublicKey) == 0 {
+		return "", nil
+	}
+	pubKey, err := crypto.UnmarshalPublicKey(f.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return peer.IDFromPublicKey(pubKey)
+}
+
+type line struct {
+	Type string `json:"type"`
+	*Header
+	*Footer
+	Key   string           `json:"key,omitempty"`
+	Value []byte           `json:"value,omitempty"`
+	Node  *pubsub.NodeData `json:"node,omitempty"`
+}
+
+// ExportOptions describes the node a snapshot is taken from.
+type ExportOptions struct {
+	NodeVersion string
+	Environment string
+	// Signer signs the snapshot when set.
+	Signer crypto.PrivKey
+	// Clock is the clock of the node, the snapshot is dated by it.
+	Clock clock.Clock
+}
+
+// Export writes every entry of the cache except the change log of the republisher,
+// and every node of the registry, to w.
+func Export(ctx context.Context, w io.Writer, store ds.Datastore, nodes map[string]*pubsub.NodeData, opts ExportOptions) (*Footer, error) {
+	digest := sha256.New()
+	out := bufio.NewWriter(io.MultiWriter(w, digest))
+	write := func(l line) error {
+		data, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(append(data, '\n'))
+		return err
+	}
+
+	header := &Header{
+		Format:      FormatVersion,
+		Created:     opts.Clock.Now().UTC(),
+		NodeVersion: opts.NodeVersion,
+		Environment: opts.Environment,
+	}
+	if err := write(line{Type: lineHeader, Header: header}); err != nil {
+		return nil, err
+	}
+
+	footer := &Footer{}
+	results, err := store.Query(ctx, query.Query{Orders: []query.Order{query.OrderByKey{}}})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if db.IsChangeLogKey(result.Key) {
+			continue
+		}
+		if err := write(line{Type: lineRecord, Key: result.Key, Value: result.Value}); err != nil {
+			return nil, err
+		}
+		footer.Records++
+	}
+	for _, id := range sortedIDs(nodes) {
+		if err := write(line{Type: lineNode, Node: nodes[id]}); err != nil {
+			return nil, err
+		}
+		footer.Nodes++
+	}
+	if err := out.Flush(); err != nil {
+		return nil, err
+	}
+
+	sum := digest.Sum(nil)
+	footer.SHA256 = hex.EncodeToString(sum)
+	if opts.Signer != nil {
+		pubKey, err := crypto.MarshalPublicKey(opts.Signer.GetPublic())
+		if err != nil {
+			return nil, err
+		}
+		signature, err := consensus.SignData(opts.Signer, sum)
+		if err != nil {
+			return nil, err
+		}
+		footer.PublicKey = pubKey
+		footer.Signature = hex.EncodeToString(signature)
+	}
+	data, err := json.Marshal(line{Type: lineFooter, Footer: footer})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return nil, err
+	}
+	return footer, nil
+}
+
+// Verify reads a snapshot and checks its format, its digest and, if it is signed, its
+// signature.
+func Verify(r io.Reader) (*Header, *Footer, error) {
+	var header *Header
+	var footer *Footer
+	records, nodes := 0, 0
+	digest := sha256.New()
+	err := readLines(r, func(raw []byte, l *line) error {
+		if footer != nil {
+			return fmt.Errorf("%w: data after the footer", ErrInvalidSnapshot)
+		}
+		if header == nil && l.Type != lineHeader {
+			return fmt.Errorf("%w: missing header", ErrInvalidSnapshot)
+		}
+		switch l.Type {
+		case lineHeader:
+			if header != nil || l.Header == nil {
+				return fmt.Errorf("%w: unexpected header", ErrInvalidSnapshot)
+			}
+			if l.Header.Format != FormatVersion {
+				return fmt.Errorf("%w: %d", ErrUnsupportedVersion, l.Header.Format)
+			}
+			header = l.Header
+		case lineRecord:
+			records++
+		case lineNode:
+			nodes++
+		case lineFooter:
+			if l.Footer == nil {
+				return fmt.Errorf("%w: empty footer", ErrInvalidSnapshot)
+			}
+			footer = l.Footer
+			return nil
+		default:
+			return fmt.Errorf("%w: unknown line type %q", ErrInvalidSnapshot, l.Type)
+		}
+		digest.Write(raw)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if footer == nil {
+		return nil, nil, fmt.Errorf("%w: missing footer, the snapshot is truncated", ErrInvalidSnapshot)
+	}
+	if footer.Records != records || footer.Nodes != nodes {
+		return nil, nil, fmt.Errorf("%w: expected %d records and %d nodes, found %d and %d",
+			ErrInvalidSnapshot, footer.Records, footer.Nodes, records, nodes)
+	}
+	sum := digest.Sum(nil)
+	if hex.EncodeToString(sum) != footer.SHA256 {
+		return nil, nil, fmt.Errorf("%w: digest mismatch", ErrInvalidSnapshot)
+	}
+	if len(footer.PublicKey) > 0 {
+		pubKey, err := crypto.UnmarshalPublicKey(footer.PublicKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		valid, err := consensus.VerifySignature(pubKey, sum, footer.Signature)
+		if err != nil || !valid {
+			return nil, nil, ErrInvalidSignature
+		}
+	}
+	return header, footer, nil
+}
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	Mode Mode
+	// RequireSigned rejects unsigned snapshots.
+	RequireSigned bool
+}
+
+// Counts is the number of entries Import changed, by kind of change.
+type Counts struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+}
+
+// Conflict is an entry of the snapshot that was not imported.
+type Conflict struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// Report describes the outcome of Import.
+type Report struct {
+	Header  *Header `json:"header"`
+	Signer  peer.ID `json:"signer,omitempty"`
+	Records Counts  `json:"records"`
+	Nodes   Counts  `json:"nodes"`
+	// Conflicts are entries whose local version was kept in merge mode.
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+	// Invalid are entries that failed verification and were skipped.
+	Invalid []Conflict `json:"invalid,omitempty"`
+}
+
+// Import verifies the snapshot read from r and applies it to the cache and the node
+// registry. Records are only imported with a valid signature of their writer. In merge
+// mode the local version of an entry is kept unless the snapshot has a newer one, in
+// replace mode entries missing from the snapshot are removed. Imported records are
+// marked dirty, so the node publishes them to the DHT when it starts.
+func Import(ctx context.Context, r io.ReadSeeker, cache *db.ResolverCache, nodes map[string]*pubsub.NodeData, opts ImportOptions) (*Report, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeMerge
+	}
+	if opts.Mode != ModeMerge && opts.Mode != ModeReplace {
+		return nil, fmt.Errorf("unknown import mode %q, use %s or %s", opts.Mode, ModeMerge, ModeReplace)
+	}
+	header, footer, err := Verify(r)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Header: header}
+	if report.Signer, err = footer.Signer(); err != nil {
+		return nil, err
+	}
+	if opts.RequireSigned && report.Signer == "" {
+		return nil, ErrUnsigned
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	store := cache.Datastore()
+	republisher := db.NewRepublisher(cache, nil)
+	// in replace mode the local entries that are not in the snapshot are removed
+	var local, localNodes map[string]bool
+	if opts.Mode == ModeReplace {
+		if local, err = localKeys(ctx, store); err != nil {
+			return nil, err
+		}
+		localNodes = make(map[string]bool, len(nodes))
+		for id := range nodes {
+			localNodes[id] = true
+		}
+	}
+
+	err = readLines(r, func(_ []byte, l *line) error {
+		switch l.Type {
+		case lineRecord:
+			delete(local, l.Key)
+			return importRecord(ctx, store, republisher, l.Key, l.Value, opts.Mode, report)
+		case lineNode:
+			if l.Node != nil {
+				delete(localNodes, l.Node.PeerId.String())
+			}
+			importNode(nodes, l.Node, opts.Mode, report)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for key := range local {
+		if err := store.Delete(ctx, ds.NewKey(key)); err != nil {
+			return report, err
+		}
+		if !db.IsChangeLogKey(key) {
+			report.Records.Removed++
+		}
+	}
+	for id := range localNodes {
+		delete(nodes, id)
+		report.Nodes.Removed++
+	}
+	return report, store.Sync(ctx, ds.NewKey("/"))
+}
+
+func importRecord(ctx context.Context, store ds.Datastore, republisher *db.Republisher, key string, value []byte, mode Mode, report *Report) error {
+	if db.IsChangeLogKey(key) {
+		report.Invalid = append(report.Invalid, Conflict{Key: key, Reason: "change log entries are not imported"})
+		return nil
+	}
+	// keys starting with a dot hold node data such as blob blocks, not signed records
+	isRecord := !strings.HasPrefix(key, "/.")
+	var record *network.DBRecord
+	if isRecord {
+		var err error
+		if record, err = verifyRecord(key, value); err != nil {
+			report.Invalid = append(report.Invalid, Conflict{Key: key, Reason: err.Error()})
+			return nil
+		}
+	}
+
+	dsKey := ds.NewKey(key)
+	current, err := store.Get(ctx, dsKey)
+	switch {
+	case errors.Is(err, ds.ErrNotFound):
+		report.Records.Added++
+	case err != nil:
+		return err
+	case bytes.Equal(current, value):
+		report.Records.Unchanged++
+		return nil
+	case mode == ModeReplace:
+		report.Records.Updated++
+	default:
+		if reason := keepLocal(current, record); reason != "" {
+			report.Conflicts = append(report.Conflicts, Conflict{Key: key, Reason: reason})
+			return nil
+		}
+		report.Records.Updated++
+	}
+
+	if err := store.Put(ctx, dsKey, value); err != nil {
+		return err
+	}
+	if isRecord && mode == ModeMerge {
+		republisher.MarkDirty(ctx, strings.TrimPrefix(key, "/"))
+	}
+	return nil
+}
+
+// keepLocal returns why the local value of an entry wins over the snapshot in merge
+// mode, or an empty string if the snapshot has the newer version.
+func keepLocal(current []byte, record *network.DBRecord) string {
+	if record == nil {
+		return "local value differs from the snapshot"
+	}
+	local, err := network.UnmarshalDBRecord(current)
+	if err != nil {
+		// the local value is not a valid record, the signed one replaces it
+		return ""
+	}
+	if !record.NewerThan(local) {
+		return fmt.Sprintf("local version %s is not older than snapshot version %s", local.Version(), record.Version())
+	}
+	return ""
+}
+
+func verifyRecord(key string, value []byte) (*network.DBRecord, error) {
+	record, err := network.UnmarshalDBRecord(value)
+	if err != nil {
+		return nil, err
+	}
+	if record.Key != "/db"+key {
+		return nil, fmt.Errorf("record is signed for %s", record.Key)
+	}
+	if _, err := record.Verify(); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func importNode(nodes map[string]*pubsub.NodeData, node *pubsub.NodeData, mode Mode, report *Report) {
+	if node == nil || node.PeerId == "" {
+		report.Invalid = append(report.Invalid, Conflict{Key: "node", Reason: "node without peer ID"})
+		return
+	}
+	id := node.PeerId.String()
+	current, ok := nodes[id]
+	switch {
+	case !ok:
+		report.Nodes.Added++
+	case node.LastUpdated.Equal(current.LastUpdated):
+		report.Nodes.Unchanged++
+		return
+	case mode == ModeMerge && node.LastUpdated.Before(current.LastUpdated):
+		report.Conflicts = append(report.Conflicts, Conflict{
+			Key:    "node " + id,
+			Reason: fmt.Sprintf("local entry updated %s is newer", current.LastUpdated.Format(time.RFC3339)),
+		})
+		return
+	default:
+		report.Nodes.Updated++
+	}
+	nodes[id] = node
+}
+
+func sortedIDs(nodes map[string]*pubsub.NodeData) []string {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func localKeys(ctx context.Context, store ds.Datastore) (map[string]bool, error) {
+	results, err := store.Query(ctx, query.Query{KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+	keys := make(map[string]bool)
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		keys[result.Key] = true
+	}
+	return keys, nil
+}
+
+func readLines(r io.Reader, fn func(raw []byte, l *line) error) error {
+	reader := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		raw, err := reader.ReadBytes('\n')
+		if len(raw) > maxLineSize {
+			return fmt.Errorf("%w: line %d is too long", ErrInvalidSnapshot, n)
+		}
+		if len(bytes.TrimSpace(raw)) > 0 {
+			var l line
+			if jerr := json.Unmarshal(raw, &l); jerr != nil {
+				return fmt.Errorf("%w: line %d: %v", ErrInvalidSnapshot, n, jerr)
+			}
+			if ferr := fn(raw, &l); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// LoadRegistry reads the node registry persisted by the node event tracker. A missing
+// file is an empty registry.
+func LoadRegistry(path string) (map[string]*pubsub.NodeData, error) {
+	nodes := make(map[string]*pubsub.NodeData)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nodes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return nil, fmt.Errorf("could not unmarshal node data %s: %w", path, err)
+	}
+	return nodes, nil
+}
+
+// SaveRegistry writes the node registry in the format of the node event tracker.
+func SaveRegistry(path string, nodes map[string]*pubsub.NodeData) error {
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		return fmt.Errorf("could not marshal node data: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,129 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/pubsub"
+	"github.com/masa-finance/masa-oracle/pkg/storage"
+)
+
+// testHLC orders the records written by the tests.
+var testHLC = clock.NewHLC(clock.Wall())
+
+func newTestCache(t *testing.T) *db.ResolverCache {
+	store, err := storage.Open(storage.BackendMemory, "")
+	require.NoError(t, err)
+	return db.NewResolverCache(store, clock.Wall())
+}
+
+func putRecord(t *testing.T, c *db.ResolverCache, privKey crypto.PrivKey, key, value string, seq uint64) {
+	record, err := network.NewDBRecord(testHLC, privKey, "/db/"+key, []byte(value), seq, 0)
+	require.NoError(t, err)
+	data, err := record.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, c.Datastore().Put(context.Background(), ds.NewKey(key), data))
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+	nodeID, err := peer.IDFromPrivateKey(privKey)
+	require.NoError(t, err)
+	now := time.Now().UTC()
+
+	// the last write wins: the target has an older a, a newer b and a key missing
+	// from the snapshot
+	target := newTestCache(t)
+	putRecord(t, target, privKey, "twitter/a", `"a1"`, 1)
+
+	source := newTestCache(t)
+	putRecord(t, source, privKey, "twitter/a", `"a2"`, 2)
+	putRecord(t, source, privKey, "twitter/b", `"b1"`, 1)
+	putRecord(t, source, privKey, "twitter/c", `"c1"`, 1)
+	require.NoError(t, source.Datastore().Put(ctx, ds.NewKey("/.changelog/dirty/twitter/a"), []byte("1")))
+	nodes := map[string]*pubsub.NodeData{
+		nodeID.String(): {PeerId: nodeID, LastUpdated: now, IsActive: true},
+	}
+
+	var buf bytes.Buffer
+	footer, err := Export(ctx, &buf, source.Datastore(), nodes, ExportOptions{NodeVersion: "v1", Signer: privKey, Clock: clock.Wall()})
+	require.NoError(t, err)
+	assert.Equal(t, 3, footer.Records)
+	assert.Equal(t, 1, footer.Nodes)
+	assert.NotContains(t, buf.String(), ".changelog")
+
+	putRecord(t, target, privKey, "twitter/b", `"b2"`, 2)
+	putRecord(t, target, privKey, "twitter/local", `"l"`, 1)
+	targetNodes := map[string]*pubsub.NodeData{
+		nodeID.String(): {PeerId: nodeID, LastUpdated: now.Add(time.Minute)},
+	}
+
+	report, err := Import(ctx, bytes.NewReader(buf.Bytes()), target, targetNodes, ImportOptions{Mode: ModeMerge, RequireSigned: true})
+	require.NoError(t, err)
+	assert.Equal(t, nodeID, report.Signer)
+	assert.Equal(t, Counts{Added: 1, Updated: 1}, report.Records)
+	assert.Len(t, report.Conflicts, 2, "the newer local b and the newer local node are kept")
+	assert.True(t, targetNodes[nodeID.String()].LastUpdated.After(now))
+	value, err := target.Get(ctx, "twitter/a")
+	require.NoError(t, err)
+	record, err := network.UnmarshalDBRecord(value)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), record.Seq)
+	has, err := target.Datastore().Has(ctx, ds.NewKey("/.changelog/dirty/twitter/a"))
+	require.NoError(t, err)
+	assert.True(t, has, "imported records are published by the node")
+
+	report, err = Import(ctx, bytes.NewReader(buf.Bytes()), target, targetNodes, ImportOptions{Mode: ModeReplace})
+	require.NoError(t, err)
+	assert.Equal(t, Counts{Updated: 1, Unchanged: 2, Removed: 1}, report.Records)
+	assert.Equal(t, Counts{Updated: 1}, report.Nodes)
+	assert.Empty(t, report.Conflicts)
+	_, err = target.Get(ctx, "twitter/local")
+	assert.ErrorIs(t, err, ds.ErrNotFound)
+	assert.Equal(t, now, targetNodes[nodeID.String()].LastUpdated)
+
+	tampered := bytes.Replace(buf.Bytes(), []byte(`"v1"`), []byte(`"v2"`), 1)
+	_, err = Import(ctx, bytes.NewReader(tampered), target, targetNodes, ImportOptions{})
+	assert.ErrorIs(t, err, ErrInvalidSnapshot)
+	_, _, err = Verify(bytes.NewReader(buf.Bytes()[:buf.Len()/2]))
+	assert.ErrorIs(t, err, ErrInvalidSnapshot)
+}
+
+func TestImportRejectsForgedRecords(t *testing.T) {
+	ctx := context.Background()
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, 2048)
+	require.NoError(t, err)
+
+	source := newTestCache(t)
+	putRecord(t, source, privKey, "twitter/a", `"a"`, 1)
+	// a record copied to another key fails verification
+	value, err := source.Get(ctx, "twitter/a")
+	require.NoError(t, err)
+	require.NoError(t, source.Datastore().Put(ctx, ds.NewKey("twitter/b"), value))
+
+	var buf bytes.Buffer
+	_, err = Export(ctx, &buf, source.Datastore(), nil, ExportOptions{Clock: clock.Wall()})
+	require.NoError(t, err)
+
+	target := newTestCache(t)
+	_, err = Import(ctx, bytes.NewReader(buf.Bytes()), target, map[string]*pubsub.NodeData{}, ImportOptions{RequireSigned: true})
+	assert.ErrorIs(t, err, ErrUnsigned)
+	report, err := Import(ctx, bytes.NewReader(buf.Bytes()), target, map[string]*pubsub.NodeData{}, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Records.Added)
+	require.Len(t, report.Invalid, 1)
+	assert.Equal(t, "/twitter/b", report.Invalid[0].Key)
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"encoding/base32"
+	"strings"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+)
+
+// flatfs stores every key in its own file, it only accepts flat keys of upper case
+// letters and digits. Keys are therefore stored base32 encoded and queries are
+// evaluated after decoding them, which makes prefix and range queries a full scan.
+// Keys are limited to about 150 bytes by the maximum length of a file name.
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func openFlatFS(path string) (ds.Batching, error) {
+	store, err := flatfs.CreateOrOpen(path, flatfs.NextToLast(2), false)
+	if err != nil {
+		return nil, err
+	}
+	return &encodedDatastore{child: store}, nil
+}
+
+func encodeKey(key ds.Key) ds.Key {
+	return ds.RawKey("/" + keyEncoding.EncodeToString(key.Bytes()))
+}
+
+func decodeKey(key string) (ds.Key, error) {
+	raw, err := keyEncoding.DecodeString(strings.TrimPrefix(key, "/"))
+	if err != nil {
+		return ds.Key{}, err
+	}
+	return ds.RawKey(string(raw)), nil
+}
+
+// encodedDatastore stores the keys of a datastore base32 encoded in its child.
+type encodedDatastore struct {
+	child ds.Batching
+}
+
+func (d *encodedDatastore) Get(ctx context.Context, key ds.Key) ([]byte, error) {
+	return d.child.Get(ctx, encodeKey(key))
+}
+
+func (d *encodedDatastore) Has(ctx context.Context, key ds.Key) (bool, error) {
+	return d.child.Has(ctx, encodeKey(key))
+}
+
+func (d *encodedDatastore) GetSize(ctx context.Context, key ds.Key) (int, error) {
+	return d.child.GetSize(ctx, encodeKey(key))
+}
+
+func (d *encodedDatastore) Put(ctx context.Context, key ds.Key, value []byte) error {
+	return d.child.Put(ctx, encodeKey(key), value)
+}
+
+func (d *encodedDatastore) Delete(ctx context.Context, key ds.Key) error {
+	return d.child.Delete(ctx, encodeKey(key))
+}
+
+// Sync flushes the whole child, encoded keys do not share the prefixes of their keys.
+func (d *encodedDatastore) Sync(ctx context.Context, _ ds.Key) error {
+	return d.child.Sync(ctx, ds.NewKey("/"))
+}
+
+func (d *encodedDatastore) Close() error {
+	return d.child.Close()
+}
+
+func (d *encodedDatastore) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	results, err := d.child.Query(ctx, query.Query{KeysOnly: q.KeysOnly, ReturnsSizes: q.ReturnsSizes})
+	if err != nil {
+		return nil, err
+	}
+	decoded := query.ResultsFromIterator(q, query.Iterator{
+		Next: func() (query.Result, bool) {
+			result, ok := results.NextSync()
+			if !ok || result.Error != nil {
+				return result, ok
+			}
+			key, err := decodeKey(result.Key)
+			if err != nil {
+				return query.Result{Error: err}, true
+			}
+			result.Key = key.String()
+			return result, true
+		},
+		Close: results.Close,
+	})
+	return query.NaiveQueryApply(q, decoded), nil
+}
+
+func (d *encodedDatastore) Batch(ctx context.Context) (ds.Batch, error) {
+	batch, err := d.child.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &encodedBatch{child: batch}, nil
+}
+
+type encodedBatch struct {
+	child ds.Batch
+}
+
+func (b *encodedBatch) Put(ctx context.Context, key ds.Key, value []byte) error {
+	return b.child.Put(ctx, encodeKey(key), value)
+}
+
+func (b *encodedBatch) Delete(ctx context.Context, key ds.Key) error {
+	return b.child.Delete(ctx, encodeKey(key))
+}
+
+func (b *encodedBatch) Commit(ctx context.Context) error {
+	return b.child.Commit(ctx)
+}
@@ -0,0 +1,134 @@
+// Package storage opens the datastores that back the resolver cache.
+//
+// Backends are selected by name: leveldb (the default), badger, flatfs, and memory,
+// which keeps everything in memory and is meant for tests. Migrate copies the entries
+// of one datastore into another, so a node can switch backends offline.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+	badger "github.com/ipfs/go-ds-badger"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+)
+
+const (
+	BackendLevelDB = "leveldb"
+	BackendBadger  = "badger"
+	BackendFlatFS  = "flatfs"
+	BackendMemory  = "memory"
+)
+
+// ErrUnknownBackend is returned by Open for backends that are not registered.
+var ErrUnknownBackend = errors.New("unknown storage backend")
+
+// Opener opens or creates the datastore at path.
+type Opener func(path string) (ds.Batching, error)
+
+var (
+	mu       sync.RWMutex
+	backends = map[string]Opener{
+		BackendLevelDB: openLevelDB,
+		BackendBadger:  openBadger,
+		BackendFlatFS:  openFlatFS,
+		BackendMemory:  openMemory,
+	}
+)
+
+// Register adds a backend or replaces the one with the same name.
+func Register(name string, open Opener) {
+	mu.Lock()
+	defer mu.Unlock()
+	backends[name] = open
+}
+
+// Backends returns the names of the registered backends.
+func Backends() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens the datastore of the given backend at path. An empty backend selects leveldb.
+func Open(backend, path string) (ds.Batching, error) {
+	if backend == "" {
+		backend = BackendLevelDB
+	}
+	mu.RLock()
+	open, ok := backends[strings.ToLower(backend)]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q, use one of %s", ErrUnknownBackend, backend, strings.Join(Backends(), ", "))
+	}
+	store, err := open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s datastore at %s: %w", backend, path, err)
+	}
+	return store, nil
+}
+
+// Migrate copies every entry of from into to and returns the number of entries copied.
+func Migrate(ctx context.Context, from, to ds.Batching) (int, error) {
+	results, err := from.Query(ctx, query.Query{})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	const batchSize = 1000
+	batch, err := to.Batch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for result := range results.Next() {
+		if result.Error != nil {
+			return count, result.Error
+		}
+		if err := batch.Put(ctx, ds.NewKey(result.Key), result.Value); err != nil {
+			return count, err
+		}
+		count++
+		if count%batchSize == 0 {
+			if err := batch.Commit(ctx); err != nil {
+				return count, err
+			}
+			if batch, err = to.Batch(ctx); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := batch.Commit(ctx); err != nil {
+		return count, err
+	}
+	return count, to.Sync(ctx, ds.NewKey("/"))
+}
+
+func openLevelDB(path string) (ds.Batching, error) {
+	return leveldb.NewDatastore(path, nil)
+}
+
+func openBadger(path string) (ds.Batching, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	return badger.NewDatastore(path, &badger.DefaultOptions)
+}
+
+func openMemory(string) (ds.Batching, error) {
+	return dssync.MutexWrap(ds.NewMapDatastore()), nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackends(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range Backends() {
+		t.Run(backend, func(t *testing.T) {
+			store, err := Open(backend, filepath.Join(t.TempDir(), backend))
+			require.NoError(t, err)
+			defer store.Close()
+
+			require.NoError(t, store.Put(ctx, ds.NewKey("twitter/q2"), []byte("2")))
+			require.NoError(t, store.Put(ctx, ds.NewKey("twitter/q1"), []byte("1")))
+			require.NoError(t, store.Put(ctx, ds.NewKey("nodestatus/a"), []byte("a")))
+
+			value, err := store.Get(ctx, ds.NewKey("twitter/q1"))
+			require.NoError(t, err)
+			assert.Equal(t, []byte("1"), value)
+
+			results, err := store.Query(ctx, query.Query{Prefix: "/twitter", Orders: []query.Order{query.OrderByKey{}}})
+			require.NoError(t, err)
+			entries, err := results.Rest()
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			assert.Equal(t, "/twitter/q1", entries[0].Key)
+			assert.Equal(t, "/twitter/q2", entries[1].Key)
+
+			require.NoError(t, store.Delete(ctx, ds.NewKey("twitter/q1")))
+			_, err = store.Get(ctx, ds.NewKey("twitter/q1"))
+			assert.ErrorIs(t, err, ds.ErrNotFound)
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	from, err := Open(BackendLevelDB, filepath.Join(t.TempDir(), "from"))
+	require.NoError(t, err)
+	defer from.Close()
+	to, err := Open(BackendFlatFS, filepath.Join(t.TempDir(), "to"))
+	require.NoError(t, err)
+	defer to.Close()
+
+	for _, key := range []string{"a", "twitter/q1", "nodestatus/b"} {
+		require.NoError(t, from.Put(ctx, ds.NewKey(key), []byte(key)))
+	}
+	count, err := Migrate(ctx, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	value, err := to.Get(ctx, ds.NewKey("twitter/q1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("twitter/q1"), value)
+
+	_, err = Open("rocksdb", t.TempDir())
+	assert.ErrorIs(t, err, ErrUnknownBackend)
+}
@@ -12,6 +12,16 @@ import (
 	pubsub2 "github.com/masa-finance/masa-oracle/pkg/pubsub"
 )
 
+// nodeTopics are the topics every node subscribes to.
+var nodeTopics = []string{
+	config.NodeGossipTopic,
+	config.AdTopic,
+	config.NodeStatusTopic,
+	config.PublicKeyTopic,
+	config.AclTopic,
+	config.DataUpdatesTopic,
+}
+
 // SubscribeToTopics handles the subscription to various topics for an OracleNode.
 // It subscribes the node to the NodeGossipTopic, AdTopic, and PublicKeyTopic.
 // Each subscription is managed through the node's PubSubManager, which orchestrates the message passing for these topics.
@@ -41,5 +51,23 @@ func SubscribeToTopics(node *OracleNode) error {
 		return err
 	}
 
+	// Subscribe to AclTopic to receive the grants and revocations of the database ACL.
+	aclTopic := config.TopicWithVersion(config.AclTopic)
+	if err := node.PubSubManager.AddSubscription(aclTopic, node.ACL); err != nil {
+		return err
+	}
+	node.ACL.Publish = func(data []byte) error {
+		return node.PubSubManager.Publish(aclTopic, data)
+	}
+
+	// Subscribe to DataUpdatesTopic to learn about new versions of the shared data.
+	dataUpdatesTopic := config.TopicWithVersion(config.DataUpdatesTopic)
+	if err := node.PubSubManager.AddSubscription(dataUpdatesTopic, node.DataUpdates); err != nil {
+		return err
+	}
+	node.DataUpdates.Publish = func(data []byte) error {
+		return node.PubSubManager.Publish(dataUpdatesTopic, data)
+	}
+
 	return nil
 }
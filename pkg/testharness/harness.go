@@ -0,0 +1,240 @@
+// Package testharness runs several OracleNodes in one process on top of a libp2p
+// mocknet. Every node gets its own configuration, keys and data directory, links
+// between nodes can be cut and restored, and the node clock can be advanced, so
+// gossip, node data sync and join/leave tracking can be exercised in plain go tests.
+package testharness
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	bclock "github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/multiformats/go-multiaddr"
+
+	masa "github.com/masa-finance/masa-oracle/pkg"
+	"github.com/masa-finance/masa-oracle/pkg/clock"
+	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/db"
+	"github.com/masa-finance/masa-oracle/pkg/masacrypto"
+	"github.com/masa-finance/masa-oracle/pkg/storage"
+)
+
+const nodePort = 4001
+
+// ConvergeTimeout bounds how long tests wait for the nodes to agree, PollInterval is how
+// often they check.
+const (
+	ConvergeTimeout = time.Second * 45
+	PollInterval    = time.Millisecond * 250
+)
+
+// Options controls how the nodes of a harness are created.
+type Options struct {
+	// Nodes is the number of nodes to start. Node 0 is the boot node of all others.
+	Nodes int
+	// Unstaked lists the indexes of nodes that start unstaked, all others are staked.
+	Unstaked []int
+	// Configure is called with every node config before the node is created.
+	Configure func(index int, cfg *config.AppConfig)
+	// MemoryCache gives every node a database on its own in-memory resolver cache,
+	// see DB.
+	MemoryCache bool
+}
+
+// Harness is a set of OracleNodes connected through a mocknet.
+type Harness struct {
+	t      testing.TB
+	Net    mocknet.Mocknet
+	Clock  *bclock.Mock
+	Nodes  []*masa.OracleNode
+	DBs    []*db.Database
+	cancel []context.CancelFunc
+}
+
+// New starts opts.Nodes nodes and stops them again when the test finishes.
+func New(t testing.TB, opts Options) *Harness {
+	t.Helper()
+	if opts.Nodes < 1 {
+		t.Fatal("testharness: at least one node is required")
+	}
+	h := &Harness{t: t, Net: mocknet.New(), Clock: clock.NewMock()}
+	t.Cleanup(h.Close)
+
+	unstaked := make(map[int]bool)
+	for _, i := range opts.Unstaked {
+		unstaked[i] = true
+	}
+
+	var bootnode string
+	for i := 0; i < opts.Nodes; i++ {
+		node, err := h.addNode(i, bootnode, !unstaked[i], opts.Configure)
+		if err != nil {
+			t.Fatalf("testharness: creating node %d: %v", i, err)
+		}
+		if i == 0 {
+			bootnode = fmt.Sprintf("%s/p2p/%s", node.Host.Addrs()[0], node.Host.ID())
+		}
+	}
+	if err := h.Net.LinkAll(); err != nil {
+		t.Fatalf("testharness: linking nodes: %v", err)
+	}
+	for i, node := range h.Nodes {
+		if err := node.Start(); err != nil {
+			t.Fatalf("testharness: starting node %d: %v", i, err)
+		}
+	}
+	if opts.MemoryCache {
+		for i, node := range h.Nodes {
+			store, err := storage.Open(storage.BackendMemory, "")
+			if err != nil {
+				t.Fatalf("testharness: opening the cache of node %d: %v", i, err)
+			}
+			database := db.New(node, db.NewResolverCache(store, node.Clock))
+			database.Start(node.Context)
+			h.DBs = append(h.DBs, database)
+		}
+	}
+	return h
+}
+
+func (h *Harness) addNode(index int, bootnode string, isStaked bool, configure func(int, *config.AppConfig)) (*masa.OracleNode, error) {
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Secp256k1, -1)
+	if err != nil {
+		return nil, err
+	}
+	keyManager, err := masacrypto.NewKeyManager(privKey)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/10.0.%d.%d/tcp/%d", index/250, index%250+1, nodePort))
+	if err != nil {
+		return nil, err
+	}
+	hst, err := h.Net.AddPeer(privKey, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config.AppConfig{
+		Environment: "test",
+		MasaDir:     h.t.TempDir(),
+		Bootnodes:   []string{bootnode},
+		TCP:         true,
+		PortNbr:     nodePort,
+		Mdns:        false,
+		DhtMode:     "server",
+	}
+	if configure != nil {
+		configure(index, cfg)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	node, err := masa.NewOracleNodeWithHost(ctx, hst, cfg, h.Clock, keyManager, isStaked)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	h.Nodes = append(h.Nodes, node)
+	h.cancel = append(h.cancel, cancel)
+	return node, nil
+}
+
+// Node returns the node with the given index.
+func (h *Harness) Node(index int) *masa.OracleNode {
+	return h.Nodes[index]
+}
+
+// DB returns the database of the node with the given index, it requires the
+// MemoryCache option.
+func (h *Harness) DB(index int) *db.Database {
+	return h.DBs[index]
+}
+
+// ID returns the peer ID of the node with the given index.
+func (h *Harness) ID(index int) peer.ID {
+	return h.Nodes[index].Host.ID()
+}
+
+// WaitForRoutingTables waits until every running node has a peer in its DHT routing
+// table, so records can be stored and found.
+func (h *Harness) WaitForRoutingTables() {
+	h.t.Helper()
+	deadline := time.Now().Add(ConvergeTimeout)
+	for {
+		filled := true
+		for i, node := range h.Nodes {
+			if node.Context.Err() == nil && node.DHT.RoutingTable().Size() == 0 {
+				filled = false
+				if time.Now().After(deadline) {
+					h.t.Fatalf("testharness: the routing table of node %d is still empty", i)
+				}
+			}
+		}
+		if filled {
+			return
+		}
+		time.Sleep(PollInterval)
+	}
+}
+
+// Partition cuts every link between the two groups of nodes and closes the open
+// connections, as if the network had split between them.
+func (h *Harness) Partition(groupA, groupB []int) {
+	h.t.Helper()
+	for _, a := range groupA {
+		for _, b := range groupB {
+			if err := h.Net.DisconnectPeers(h.ID(a), h.ID(b)); err != nil {
+				h.t.Fatalf("testharness: disconnecting %d and %d: %v", a, b, err)
+			}
+			if err := h.Net.UnlinkPeers(h.ID(a), h.ID(b)); err != nil {
+				h.t.Fatalf("testharness: unlinking %d and %d: %v", a, b, err)
+			}
+		}
+	}
+}
+
+// Heal restores the links between the two groups of nodes and reconnects them.
+func (h *Harness) Heal(groupA, groupB []int) {
+	h.t.Helper()
+	for _, a := range groupA {
+		for _, b := range groupB {
+			if len(h.Net.LinksBetweenPeers(h.ID(a), h.ID(b))) == 0 {
+				if _, err := h.Net.LinkPeers(h.ID(a), h.ID(b)); err != nil {
+					h.t.Fatalf("testharness: linking %d and %d: %v", a, b, err)
+				}
+			}
+			if _, err := h.Net.ConnectPeers(h.ID(a), h.ID(b)); err != nil {
+				h.t.Fatalf("testharness: connecting %d and %d: %v", a, b, err)
+			}
+		}
+	}
+}
+
+// StopNode shuts a node down, its peers see it disconnect.
+func (h *Harness) StopNode(index int) {
+	h.t.Helper()
+	if err := h.Nodes[index].Host.Close(); err != nil {
+		h.t.Errorf("testharness: closing node %d: %v", index, err)
+	}
+	h.cancel[index]()
+}
+
+// AdvanceTime moves the node clock forward, firing the timers that fall within d.
+func (h *Harness) AdvanceTime(d time.Duration) {
+	h.Clock.Add(d)
+}
+
+// Close stops all nodes.
+func (h *Harness) Close() {
+	if err := h.Net.Close(); err != nil {
+		h.t.Logf("testharness: closing mocknet: %v", err)
+	}
+	for _, cancel := range h.cancel {
+		cancel()
+	}
+}
@@ -0,0 +1,107 @@
+package testharness
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	myNetwork "github.com/masa-finance/masa-oracle/pkg/network"
+	"github.com/masa-finance/masa-oracle/pkg/pubsub"
+)
+
+// isActiveOn reports whether node observer has peer subject in its registry as active.
+func (h *Harness) isActiveOn(observer, subject int) bool {
+	nd := h.Node(observer).NodeTracker.GetNodeData(h.ID(subject).String())
+	return nd != nil && nd.IsActive
+}
+
+func TestJoinPropagatesToAllNodes(t *testing.T) {
+	h := New(t, Options{Nodes: 4})
+
+	assert.Eventually(t, func() bool {
+		for observer := range h.Nodes {
+			for subject := 1; subject < len(h.Nodes); subject++ {
+				if observer != subject && !h.isActiveOn(observer, subject) {
+					return false
+				}
+			}
+		}
+		return true
+	}, ConvergeTimeout, PollInterval, "every node should learn about every joined node")
+}
+
+func TestLeavePropagatesToAllNodes(t *testing.T) {
+	h := New(t, Options{Nodes: 3})
+	require.Eventually(t, func() bool {
+		return h.isActiveOn(0, 2) && h.isActiveOn(1, 2)
+	}, ConvergeTimeout, PollInterval, "node 2 should join")
+
+	h.StopNode(2)
+
+	assert.Eventually(t, func() bool {
+		return !h.isActiveOn(0, 2) && !h.isActiveOn(1, 2)
+	}, ConvergeTimeout, PollInterval, "node 2 should be marked as left")
+}
+
+func TestRegistryConvergesAfterPartitionHeals(t *testing.T) {
+	h := New(t, Options{Nodes: 4, Unstaked: []int{3}})
+	require.Eventually(t, func() bool {
+		return h.isActiveOn(1, 2) && h.isActiveOn(2, 1)
+	}, ConvergeTimeout, PollInterval, "nodes 1 and 2 should see each other")
+
+	h.Partition([]int{0, 1, 3}, []int{2})
+	require.Eventually(t, func() bool {
+		return !h.isActiveOn(0, 2) && !h.isActiveOn(1, 2)
+	}, ConvergeTimeout, PollInterval, "the majority should see node 2 leave")
+
+	// advancing the harness clock moves the clock of every node
+	h.AdvanceTime(time.Minute * 10)
+	assert.WithinDuration(t, time.Now().Add(time.Minute*10), h.Node(1).Clock.Now(), time.Minute)
+	h.Heal([]int{0, 1, 3}, []int{2})
+
+	assert.Eventually(t, func() bool {
+		return h.isActiveOn(0, 2) && h.isActiveOn(1, 2) && h.isActiveOn(2, 1)
+	}, ConvergeTimeout, PollInterval, "the registries should converge after the partition heals")
+	assert.True(t, h.Node(1).NodeTracker.IsStaked(h.ID(2).String()))
+	assert.False(t, h.Node(1).NodeTracker.IsStaked(h.ID(3).String()))
+}
+
+func TestKnownPeersAreRedialedBeforeBootNodes(t *testing.T) {
+	h := New(t, Options{Nodes: 2})
+	bootnode := h.Node(1).Config.Bootnodes[0]
+
+	// node 2 restarts knowing node 1 as a staked peer
+	node, err := h.addNode(2, bootnode, true, nil)
+	require.NoError(t, err)
+	known := h.ID(1)
+	myNetwork.RememberPeerAddrs(node.Host, known, h.Node(1).Host.Addrs()...)
+	nd := pubsub.NewNodeData(h.Node(1).GetMultiAddrs(), known, "", pubsub.ActivityJoined, h.Clock.Now())
+	nd.IsStaked = true
+	node.NodeTracker.HandleNodeData(*nd)
+	for _, other := range []int{0, 1} {
+		_, err := h.Net.LinkPeers(h.ID(2), h.ID(other))
+		require.NoError(t, err)
+	}
+
+	var mu sync.Mutex
+	var order []peer.ID
+	node.Host.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, c.RemotePeer())
+		},
+	})
+	require.NoError(t, node.Start())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, order)
+	assert.Equal(t, known, order[0], "the known peer should be connected before the boot node")
+	assert.Contains(t, order, h.ID(0))
+}
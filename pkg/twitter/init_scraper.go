@@ -1,16 +1,41 @@
 package twitter
 
 import (
+	"context"
+	"errors"
 	"path/filepath"
+	"time"
 
 	twitterscraper "github.com/n0madic/twitter-scraper"
 	"github.com/sirupsen/logrus"
 
+	"github.com/masa-finance/masa-oracle/pkg/clock"
 	"github.com/masa-finance/masa-oracle/pkg/config"
+	"github.com/masa-finance/masa-oracle/pkg/health"
+)
+
+const (
+	// LoginCheckInterval is how often the health check asks Twitter whether the scraper
+	// is still logged in.
+	LoginCheckInterval = time.Minute * 5
+	// LoginMinBackoff and LoginMaxBackoff bound the delay between login attempts.
+	LoginMinBackoff = time.Second * 30
+	LoginMaxBackoff = time.Minute * 30
 )
 
 // InitializeScraper sets up the Twitter scraper with necessary configurations including login.
 func InitializeScraper() *twitterscraper.Scraper {
+	scraper, err := NewScraper()
+	if err != nil {
+		logrus.WithError(err).Fatal("Login failed")
+		return nil
+	}
+	return scraper
+}
+
+// NewScraper creates a scraper logged in with the saved cookies or the configured
+// credentials.
+func NewScraper() (*twitterscraper.Scraper, error) {
 	scraper := twitterscraper.New()
 
 	appConfig := config.GetInstance()
@@ -20,7 +45,7 @@ func InitializeScraper() *twitterscraper.Scraper {
 		logrus.Debug("Cookies loaded successfully.")
 		if IsLoggedIn(scraper) {
 			logrus.Debug("Already logged in via cookies.")
-			return scraper
+			return scraper, nil
 		}
 	}
 
@@ -36,8 +61,7 @@ func InitializeScraper() *twitterscraper.Scraper {
 	}
 
 	if err != nil {
-		logrus.WithError(err).Fatal("Login failed")
-		return nil
+		return nil, err
 	}
 
 	if err := SaveCookies(scraper, cookieFilePath); err != nil {
@@ -45,5 +69,16 @@ func InitializeScraper() *twitterscraper.Scraper {
 	}
 
 	logrus.Debug("Login successful")
-	return scraper
+	return scraper, nil
+}
+
+// HealthCheck reports whether the scraper is logged in. Every check is a request to
+// Twitter, so the outcome is kept for LoginCheckInterval of clk.
+func HealthCheck(scraper *twitterscraper.Scraper, clk clock.Clock) health.Check {
+	return health.Cached(clk, func(context.Context) (string, error) {
+		if !IsLoggedIn(scraper) {
+			return "", errors.New("the scraper is logged out")
+		}
+		return "logged in", nil
+	}, LoginCheckInterval)
 }